@@ -2,51 +2,179 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/go-portfolio/order-pipeline/internal/auth"
 	"github.com/go-portfolio/order-pipeline/internal/config"
+	"github.com/go-portfolio/order-pipeline/internal/health"
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+	"github.com/go-portfolio/order-pipeline/internal/metrics"
 	"github.com/go-portfolio/order-pipeline/internal/server"
+	"github.com/go-portfolio/order-pipeline/internal/tlsutil"
+	"github.com/go-portfolio/order-pipeline/internal/tracing"
 	pb "github.com/go-portfolio/order-pipeline/proto"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
 func main() {
-	// Загружаем конфигурацию приложения
-	appCfg := config.LoadConfig()
+	// Конфигурация: значения по умолчанию < файл --config < окружение < флаги
+	var appCfg config.Cache
+	config.MustLoad("ordercache", &appCfg)
+
+	// Логи в формате LOG_FORMAT с уровнем LOG_LEVEL; уровень меняется через /loglevel
+	logLevel, err := logging.Setup(logging.Config{Service: "ordercache", Format: appCfg.Log.Format, Level: appCfg.Log.Level})
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+
+	// Трассировка запросов gRPC и команд Redis
+	shutdownTracing, err := tracing.Setup(tracing.Config{
+		ServiceName: "ordercache",
+		Exporter:    appCfg.Trace.Exporter,
+		File:        appCfg.Trace.File,
+	})
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("flush traces", logging.KeyError, err)
+		}
+	}()
 
 	// Подключаемся к Redis
 	rdb := redis.NewClient(&redis.Options{
-		Addr: appCfg.RedisAddr, // Redis: redis:6379
+		Addr:     appCfg.Redis.Addr, // Redis: redis:6379
+		Password: appCfg.Redis.Password,
 	})
 
+	defer rdb.Close()
+
+	// Метрики сервиса отдаются на /metrics служебного HTTP-сервера
+	reg := metrics.NewRegistry()
+	m := metrics.New(reg)
+	rdb.AddHook(tracing.RedisHook())
+	rdb.AddHook(m.RedisHook())
+
+	// Контекст отменяется по SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Проверим подключение к Redis (ping с контекстом)
-	ctx := context.Background()
 	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatalf("cannot connect to Redis at %s: %v", appCfg.RedisAddr, err)
+		logging.Fatal("cannot connect to Redis", "addr", appCfg.Redis.Addr, logging.KeyError, err)
 	}
 
 	// Создаём TCP listener для gRPC сервера на отдельном порту
-	lis, err := net.Listen("tcp", appCfg.CacheServiceAddr) // gRPC: 0.0.0.0:50052
+	lis, err := net.Listen("tcp", appCfg.Addr) // gRPC: 0.0.0.0:50052
 	if err != nil {
-		log.Fatalf("cannot listen on %s: %v", appCfg.CacheServiceAddr, err)
+		logging.Fatal("listen", "addr", appCfg.Addr, logging.KeyError, err)
 	}
 
 	// Создаём gRPC сервер
-	s := grpc.NewServer()
+	// личность клиента из сертификата (mTLS) доступна обработчикам и попадает в логи
+	unary := []grpc.UnaryServerInterceptor{logging.UnaryServerInterceptor(), tlsutil.UnaryServerInterceptor(), m.UnaryServerInterceptor()}
+	stream := []grpc.StreamServerInterceptor{logging.StreamServerInterceptor(), tlsutil.StreamServerInterceptor(), m.StreamServerInterceptor()}
+
+	// проверка API-ключа или JWT идёт после метрик, чтобы отказы считались по кодам
+	var authz *auth.Authorizer
+	if appCfg.Auth.Enabled {
+		authCfg, err := auth.FromConfig(&appCfg.Auth)
+		if err != nil {
+			logging.Fatal("invalid config", logging.KeyError, err)
+		}
+		authz = auth.NewAuthorizer(authCfg)
+		unary = append(unary, authz.UnaryServerInterceptor())
+		stream = append(stream, authz.StreamServerInterceptor())
+		slog.Info("auth enabled", "api_keys", appCfg.Auth.APIKeysFile != "", "jwks", appCfg.Auth.JWT.JWKSFile)
+	}
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+
+	// TLS включается путями к сертификату и ключу, mTLS — ещё и CA клиентов;
+	// новые файлы после ротации подхватываются без перезапуска
+	if appCfg.TLS.Enabled() {
+		certs, err := tlsutil.NewServer(tlsutil.Config{
+			CertFile:     appCfg.TLS.CertFile,
+			KeyFile:      appCfg.TLS.KeyFile,
+			ClientCAFile: appCfg.TLS.ClientCAFile,
+		})
+		if err != nil {
+			logging.Fatal("invalid config", logging.KeyError, err)
+		}
+		opts = append(opts, grpc.Creds(certs.Credentials()))
+		go certs.Run(ctx, appCfg.TLS.ReloadInterval)
+		slog.Info("TLS enabled", "mtls", appCfg.TLS.ClientCAFile != "", "not_after", certs.NotAfter())
+	}
+	s := grpc.NewServer(opts...)
 
 	// Регистрируем сервис CacheService
 	pb.RegisterCacheServiceServer(s, server.NewCacheServer(rdb))
 
+	// SIGHUP или изменение файла --config меняют уровень логов и правила доступа к методам без перезапуска
+	reloader := config.NewReloader(&appCfg, os.Args[1:], os.LookupEnv, func(cfg *config.Cache) error {
+		level, err := logging.ParseLevel(cfg.Log.Level)
+		if err != nil {
+			return err
+		}
+		logLevel.Set(level)
+		if authz != nil {
+			authz.SetRules(auth.ParseRules(cfg.Auth.Rules))
+		}
+		return nil
+	})
+	go reloader.Run(ctx, appCfg.ReloadInterval)
+
 	// Включаем reflection
 	reflection.Register(s)
 
-	log.Printf("CacheService listening on %s", appCfg.CacheServiceAddr)
+	// grpc.health.v1: SERVING, пока отвечает Redis; с начала остановки — NOT_SERVING
+	ready := health.NewMonitor(appCfg.Health.Interval, appCfg.Health.Timeout, health.RedisPing(rdb))
+	healthSrv := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(s, healthSrv)
+	health.ServeGRPC(ready, healthSrv, pb.CacheService_ServiceDesc.ServiceName)
+	go ready.Run(ctx)
+
+	slog.Info("CacheService listening", "addr", appCfg.Addr)
+
+	// Служебный HTTP: /metrics, /loglevel и /readyz
+	admin := server.NewAdminServer(appCfg.AdminAddr, reg)
+	admin.Handle("/loglevel", logging.LevelHandler(logLevel))
+	admin.Handle("/readyz", ready.Handler())
+
+	// служебный сервер работает до выхода из main, чтобы пробы видели остановку
+	adminCtx, stopAdmin := context.WithCancel(context.Background())
+	defer stopAdmin()
+	go func() {
+		if err := admin.Run(adminCtx); err != nil {
+			logging.Fatal("admin server", logging.KeyError, err)
+		}
+	}()
 
 	// Запускаем gRPC сервер
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("gRPC serve failed: %v", err)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.Serve(lis) }()
+
+	select {
+	case err := <-serveErr:
+		logging.Fatal("gRPC serve failed", logging.KeyError, err)
+	case <-ctx.Done():
 	}
+	ready.Shutdown()
+
+	// Даём активным запросам завершиться, затем закрываем соединения
+	slog.Info("shutting down, draining", "timeout", appCfg.ShutdownTimeout.String())
+	server.GracefulStop(s, appCfg.ShutdownTimeout)
 }
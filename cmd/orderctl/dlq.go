@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/dlq"
+	"github.com/go-portfolio/order-pipeline/internal/server"
+	"github.com/segmentio/kafka-go"
+)
+
+// replayBatchSize — сколько сообщений переотправляется одним WriteMessages
+const replayBatchSize = 100
+
+// dlqFlags — флаги, общие для всех подкоманд dlq
+type dlqFlags struct {
+	brokers  string
+	dlqTopic string
+	jsonOut  bool
+}
+
+func (f *dlqFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.brokers, "brokers", envOr("KAFKA_BROKERS", "localhost:9092"), "Kafka brokers, comma separated")
+	fs.StringVar(&f.dlqTopic, "dlq-topic", envOr("DLQ_TOPIC", "orders-dlq"), "DLQ topic")
+	fs.BoolVar(&f.jsonOut, "json", false, "print JSON lines instead of a table")
+}
+
+func (f *dlqFlags) inspector() *dlq.Inspector {
+	return dlq.NewInspector(strings.Split(f.brokers, ","), f.dlqTopic)
+}
+
+func runDLQ(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("dlq: subcommand required (list, show, replay, purge)")
+	}
+
+	switch args[0] {
+	case "list":
+		return dlqList(ctx, args[1:])
+	case "show":
+		return dlqShow(ctx, args[1:])
+	case "replay":
+		return dlqReplay(ctx, args[1:])
+	case "purge":
+		return dlqPurge(ctx, args[1:])
+	default:
+		return fmt.Errorf("dlq: unknown subcommand %q", args[0])
+	}
+}
+
+// dlqList выводит сообщения DLQ, подходящие под фильтр
+func dlqList(ctx context.Context, args []string) error {
+	var common dlqFlags
+	fs := flag.NewFlagSet("dlq list", flag.ContinueOnError)
+	common.register(fs)
+	filterExpr := fs.String("filter", "", "filter: order_id=..,reason=..,since=..,until=..")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	filter, err := dlq.ParseFilter(*filterExpr, time.Now())
+	if err != nil {
+		return err
+	}
+
+	out := newRecordPrinter(common.jsonOut)
+	defer out.flush()
+	return common.inspector().Scan(ctx, func(r dlq.Record) error {
+		if !filter.Match(r.Envelope) {
+			return nil
+		}
+		return out.print(r)
+	})
+}
+
+// dlqShow выводит одно сообщение DLQ целиком
+func dlqShow(ctx context.Context, args []string) error {
+	var common dlqFlags
+	fs := flag.NewFlagSet("dlq show", flag.ContinueOnError)
+	common.register(fs)
+	partition := fs.Int("partition", 0, "DLQ partition")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("dlq show: exactly one OFFSET required")
+	}
+	offset, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("dlq show: invalid offset %q", fs.Arg(0))
+	}
+
+	rec, err := common.inspector().Show(ctx, *partition, offset)
+	if err != nil {
+		return err
+	}
+	if common.jsonOut {
+		return json.NewEncoder(os.Stdout).Encode(rec)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rec)
+}
+
+// dlqReplay переотправляет подходящие сообщения в основной топик со сброшенным счётчиком retries
+func dlqReplay(ctx context.Context, args []string) error {
+	var common dlqFlags
+	fs := flag.NewFlagSet("dlq replay", flag.ContinueOnError)
+	common.register(fs)
+	filterExpr := fs.String("filter", "", "filter: order_id=..,reason=..,since=..,until=..")
+	topic := fs.String("topic", envOr("KAFKA_TOPIC", "orders"), "topic to republish to")
+	dryRun := fs.Bool("dry-run", false, "only print what would be replayed")
+	partitioner := fs.String("partitioner", envOr("KAFKA_PARTITIONER", "hash"), "partitioner used by the receiver: hash, murmur2, crc32")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	filter, err := dlq.ParseFilter(*filterExpr, time.Now())
+	if err != nil {
+		return err
+	}
+	// тот же партиционер, что у приёмника, вернёт заказ в его партицию
+	balancer, err := server.BalancerByName(*partitioner)
+	if err != nil {
+		return err
+	}
+
+	var selected []dlq.Record
+	err = common.inspector().Scan(ctx, func(r dlq.Record) error {
+		if r.DecodeError == "" && filter.Match(r.Envelope) {
+			selected = append(selected, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !*dryRun {
+		writer := &kafka.Writer{
+			Addr:     kafka.TCP(strings.Split(common.brokers, ",")...),
+			Balancer: balancer,
+		}
+		defer writer.Close()
+
+		for start := 0; start < len(selected); start += replayBatchSize {
+			end := min(start+replayBatchSize, len(selected))
+			batch := make([]kafka.Message, 0, end-start)
+			for _, r := range selected[start:end] {
+				batch = append(batch, dlq.ReplayMessage(r.Envelope, *topic))
+			}
+			if err := writer.WriteMessages(ctx, batch...); err != nil {
+				return fmt.Errorf("replayed %d of %d messages: %w", start, len(selected), err)
+			}
+		}
+	}
+
+	return printReplay(selected, *topic, *dryRun, common.jsonOut)
+}
+
+// dlqPurge удаляет все сообщения DLQ
+func dlqPurge(ctx context.Context, args []string) error {
+	var common dlqFlags
+	fs := flag.NewFlagSet("dlq purge", flag.ContinueOnError)
+	common.register(fs)
+	yes := fs.Bool("yes", false, "confirm that the DLQ topic is deleted and recreated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*yes {
+		return fmt.Errorf("dlq purge: deletes every message in %s, rerun with --yes to confirm", common.dlqTopic)
+	}
+
+	if err := common.inspector().Purge(ctx); err != nil {
+		return err
+	}
+	fmt.Printf("purged %s\n", common.dlqTopic)
+	return nil
+}
+
+// recordPrinter печатает записи DLQ таблицей или JSON-строками
+type recordPrinter struct {
+	jsonOut bool
+	enc     *json.Encoder
+	tw      *tabwriter.Writer
+}
+
+func newRecordPrinter(jsonOut bool) *recordPrinter {
+	p := &recordPrinter{jsonOut: jsonOut}
+	if jsonOut {
+		p.enc = json.NewEncoder(os.Stdout)
+		return p
+	}
+	p.tw = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(p.tw, "PARTITION\tOFFSET\tFAILED AT\tREASON\tORDER\tRETRIES\tERROR")
+	return p
+}
+
+func (p *recordPrinter) print(r dlq.Record) error {
+	if p.jsonOut {
+		return p.enc.Encode(r)
+	}
+	errText := r.Error
+	if r.DecodeError != "" {
+		errText = "undecodable DLQ message: " + r.DecodeError
+	}
+	_, err := fmt.Fprintf(p.tw, "%d\t%d\t%s\t%s\t%s\t%d\t%s\n",
+		r.DLQPartition, r.DLQOffset, r.FailedAt.Format(time.RFC3339), r.Reason, r.OrderID, r.Retries, errText)
+	return err
+}
+
+func (p *recordPrinter) flush() {
+	if p.tw != nil {
+		p.tw.Flush()
+	}
+}
+
+// printReplay сообщает, какие сообщения были (или были бы) переотправлены
+func printReplay(records []dlq.Record, topic string, dryRun, jsonOut bool) error {
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range records {
+			err := enc.Encode(map[string]any{
+				"dlq_partition": r.DLQPartition,
+				"dlq_offset":    r.DLQOffset,
+				"order_id":      r.OrderID,
+				"reason":        r.Reason,
+				"topic":         topic,
+				"dry_run":       dryRun,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	verb := "replayed"
+	if dryRun {
+		verb = "would replay"
+	}
+	for _, r := range records {
+		fmt.Printf("%s partition %d offset %d (order %s, %s) -> %s\n", verb, r.DLQPartition, r.DLQOffset, r.OrderID, r.Reason, topic)
+	}
+	fmt.Printf("%s %d message(s)\n", verb, len(records))
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/tlsutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthPollInterval — пауза между проверками в режиме --wait
+const healthPollInterval = time.Second
+
+// runHealth проверяет готовность сервисов: host:port — через grpc.health.v1,
+// http(s)://... — HTTP-пробой, которая должна ответить 200
+func runHealth(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("health", flag.ContinueOnError)
+	wait := fs.Duration("wait", 0, "keep polling until every target is ready or the timeout expires")
+	service := fs.String("service", "", "gRPC service name to check; empty checks the whole server")
+	caFile := fs.String("tls-ca", "", "CA file to verify gRPC servers over TLS; empty uses plaintext unless a client cert is set")
+	certFile := fs.String("tls-cert", "", "client certificate for mTLS")
+	keyFile := fs.String("tls-key", "", "client key for mTLS")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	creds := insecure.NewCredentials()
+	if *caFile != "" || *certFile != "" {
+		cfg, err := tlsutil.ClientConfig(*caFile, *certFile, *keyFile)
+		if err != nil {
+			return err
+		}
+		creds = credentials.NewTLS(cfg)
+	}
+	targets := fs.Args()
+	if len(targets) == 0 {
+		return errors.New("health: at least one target required (host:port or http URL)")
+	}
+
+	deadline := time.Now().Add(*wait)
+	for _, target := range targets {
+		for {
+			err := probe(ctx, target, *service, creds)
+			if err == nil {
+				fmt.Printf("%s: ready\n", target)
+				break
+			}
+			if *wait <= 0 || time.Now().After(deadline) {
+				return fmt.Errorf("%s: %w", target, err)
+			}
+			fmt.Printf("%s: not ready: %v\n", target, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(healthPollInterval):
+			}
+		}
+	}
+	return nil
+}
+
+func probe(ctx context.Context, target, service string, creds credentials.TransportCredentials) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return probeHTTP(ctx, target)
+	}
+	return probeGRPC(ctx, target, service, creds)
+}
+
+func probeGRPC(ctx context.Context, addr, service string, creds credentials.TransportCredentials) error {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+func probeHTTP(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `orderctl — утилита обслуживания order-pipeline
+
+Использование:
+  orderctl dlq list    [--filter EXPR] [--json]
+  orderctl dlq show    [--partition N] [--json] OFFSET
+  orderctl dlq replay  [--filter EXPR] [--dry-run] [--json] [--topic TOPIC]
+  orderctl dlq purge   --yes
+  orderctl health      [--wait DURATION] [--service NAME] [--tls-ca FILE] [--tls-cert FILE --tls-key FILE] TARGET...
+
+Общие флаги dlq: --brokers (KAFKA_BROKERS), --dlq-topic (DLQ_TOPIC).
+EXPR — пары key=value через запятую: order_id, reason, since, until.
+since/until — время RFC3339 или длительность назад от текущего момента (например 2h).
+TARGET health — host:port (grpc.health.v1) или http-адрес пробы, например http://orderprocessor:9090/readyz.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "orderctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("command required")
+	}
+
+	switch args[0] {
+	case "dlq":
+		return runDLQ(ctx, args[1:])
+	case "health":
+		return runHealth(ctx, args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// envOr возвращает значение переменной окружения или значение по умолчанию
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
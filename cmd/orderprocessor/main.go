@@ -1,23 +1,191 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/go-portfolio/order-pipeline/internal/config"
+	"github.com/go-portfolio/order-pipeline/internal/health"
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+	"github.com/go-portfolio/order-pipeline/internal/metrics"
+	"github.com/go-portfolio/order-pipeline/internal/pipeline"
 	"github.com/go-portfolio/order-pipeline/internal/server"
+	"github.com/go-portfolio/order-pipeline/internal/tracing"
+	"github.com/go-portfolio/order-pipeline/internal/validation"
 )
 
 func main() {
-	appCfg := config.LoadConfig()
+	// Конфигурация: значения по умолчанию < файл --config < окружение < флаги
+	var appCfg config.Worker
+	config.MustLoad("orderprocessor", &appCfg)
+
+	// Логи в формате LOG_FORMAT с уровнем LOG_LEVEL; уровень меняется через /loglevel
+	logLevel, err := logging.Setup(logging.Config{Service: "orderprocessor", Format: appCfg.Log.Format, Level: appCfg.Log.Level})
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+
+	// Контекст отменяется по SIGINT/SIGTERM — воркер дорабатывает текущее сообщение и выходит
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	keyFunc, err := server.KeyFuncByName(appCfg.PartitionKey)
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+	balancer, err := server.BalancerByName(appCfg.Partitioner)
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+
+	// Трассировка: обработка продолжает трассу из заголовков сообщения Kafka
+	shutdownTracing, err := tracing.Setup(tracing.Config{
+		ServiceName: "orderprocessor",
+		Exporter:    appCfg.Trace.Exporter,
+		File:        appCfg.Trace.File,
+	})
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("flush traces", logging.KeyError, err)
+		}
+	}()
+
+	// Метрики сервиса отдаются на /metrics служебного HTTP-сервера
+	reg := metrics.NewRegistry()
+	m := metrics.New(reg)
+
+	validator, stages, err := buildStages(&appCfg, m)
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+
+	// по ClientID проверка готовности находит воркер среди участников группы
+	hostname, _ := os.Hostname()
+	clientID := fmt.Sprintf("orderprocessor-%s-%d", hostname, os.Getpid())
 
-	brokers := strings.Split(appCfg.KafkaBrokers, ",")
 	workerServer := server.NewWorkerServer(
-		brokers,
-		appCfg.KafkaTopic,
-		appCfg.DlqTopic,
-		appCfg.WorkerGroup,
-		appCfg.RedisAddr,
+		appCfg.Kafka.Brokers,
+		appCfg.Kafka.DLQTopic,
+		appCfg.Kafka.Group,
+		appCfg.Redis.Addr,
+		server.WorkerConfig{
+			Topic: appCfg.Kafka.Topic,
+			Retry: server.RetryPolicy{
+				MaxRetries: appCfg.MaxRetries,
+				Backoff:    appCfg.RetryBackoff,
+			},
+			Concurrency:    appCfg.Concurrency,
+			MaxConcurrency: appCfg.MaxConcurrency,
+			ProcessedTTL:   appCfg.IdempotencyWindow,
+			Key:            keyFunc,
+			Balancer:       balancer,
+			Validator:      validator,
+			Pipeline:       stages,
+			Metrics:        m,
+			ClientID:       clientID,
+			RedisPassword:  appCfg.Redis.Password,
+		},
 	)
 
-	workerServer.Run()
+	// SIGHUP или изменение файла --config меняют уровень логов, число обработчиков,
+	// число повторов, стадии и правила проверки без перезапуска
+	reloader := config.NewReloader(&appCfg, os.Args[1:], os.LookupEnv, func(cfg *config.Worker) error {
+		level, err := logging.ParseLevel(cfg.Log.Level)
+		if err != nil {
+			return err
+		}
+		validator, stages, err := buildStages(cfg, m)
+		if err != nil {
+			return err
+		}
+		logLevel.Set(level)
+		workerServer.Apply(server.WorkerSettings{
+			Concurrency:  cfg.Concurrency,
+			MaxRetries:   cfg.MaxRetries,
+			ProcessedTTL: cfg.IdempotencyWindow,
+			Validator:    validator,
+			Pipeline:     stages,
+		})
+		return nil
+	})
+	go reloader.Run(ctx, appCfg.ReloadInterval)
+
+	done := make(chan error, 1)
+	go func() { done <- workerServer.Run(ctx) }()
+
+	// /healthz — цикл чтения работает; /readyz — ещё Redis и членство в группе потребителей
+	live := health.NewMonitor(appCfg.Health.Interval, appCfg.Health.Timeout, workerServer.LivenessChecks()...)
+	ready := health.NewMonitor(appCfg.Health.Interval, appCfg.Health.Timeout, workerServer.ReadinessChecks()...)
+	go live.Run(ctx)
+	go ready.Run(ctx)
+
+	// Служебный HTTP: /metrics, /loglevel, /healthz и /readyz
+	admin := server.NewAdminServer(appCfg.AdminAddr, reg)
+	admin.Handle("/loglevel", logging.LevelHandler(logLevel))
+	admin.Handle("/healthz", live.Handler())
+	admin.Handle("/readyz", ready.Handler())
+
+	// служебный сервер работает до выхода из main, чтобы пробы видели остановку
+	adminCtx, stopAdmin := context.WithCancel(context.Background())
+	defer stopAdmin()
+	go func() {
+		if err := admin.Run(adminCtx); err != nil {
+			logging.Fatal("admin server", logging.KeyError, err)
+		}
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			logging.Fatal("worker stopped", logging.KeyError, err)
+		}
+		return
+	case <-ctx.Done():
+	}
+	ready.Shutdown()
+
+	slog.Info("shutting down, waiting for in-flight messages", "timeout", appCfg.ShutdownTimeout.String())
+	select {
+	case err := <-done:
+		if err != nil {
+			logging.Fatal("worker stopped", logging.KeyError, err)
+		}
+		slog.Info("worker stopped")
+	case <-time.After(appCfg.ShutdownTimeout):
+		logging.Fatal("worker did not stop in time", "timeout", appCfg.ShutdownTimeout.String())
+	}
+}
+
+// buildStages собирает правила проверки и стадии обработки из конфигурации
+func buildStages(cfg *config.Worker, m *metrics.Metrics) (*validation.Validator, *pipeline.Pipeline, error) {
+	validator, err := validation.New(validation.FromConfig(&cfg.Orders))
+	if err != nil {
+		return nil, nil, err
+	}
+	stageNames := cfg.Stages
+	if len(stageNames) == 0 {
+		stageNames = pipeline.DefaultStages
+	}
+	stages, err := pipeline.Build(stageNames, pipeline.Deps{
+		Validator:        validator,
+		TaxRatesBps:      cfg.TaxRates,
+		FraudMaxTotal:    cfg.Fraud.MaxTotal,
+		BlockedCustomers: cfg.Fraud.BlockedCustomers,
+		SimulatedDelay:   pipeline.DefaultSimulatedDelay,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	stages.Observe(m.ObserveStage)
+	slog.Info("worker stages", "stages", strings.Join(stages.Stages(), " -> "))
+	return validator, stages, nil
 }
@@ -1,52 +1,278 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net"
-	"strings"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/go-portfolio/order-pipeline/internal/auth"
+	"github.com/go-portfolio/order-pipeline/internal/backpressure"
 	"github.com/go-portfolio/order-pipeline/internal/config" // пакет для загрузки конфигурации приложения
+	"github.com/go-portfolio/order-pipeline/internal/health"
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+	"github.com/go-portfolio/order-pipeline/internal/metrics"
+	"github.com/go-portfolio/order-pipeline/internal/outbox"
+	"github.com/go-portfolio/order-pipeline/internal/ratelimit"
 	"github.com/go-portfolio/order-pipeline/internal/server"
+	"github.com/go-portfolio/order-pipeline/internal/tlsutil"
+	"github.com/go-portfolio/order-pipeline/internal/tracing"
+	"github.com/go-portfolio/order-pipeline/internal/validation"
 	pb "github.com/go-portfolio/order-pipeline/proto" // сгенерированные protobuf файлы для OrderService
-	"github.com/segmentio/kafka-go"                   // клиент Kafka для записи сообщений
-	"google.golang.org/grpc"                          // gRPC сервер
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go" // клиент Kafka для записи сообщений
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc" // gRPC сервер
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	// сериализация protobuf-сообщений
 )
 
 func main() {
-	// Загружаем конфигурацию приложения (например, адрес gRPC, Kafka brokers и topic)
-	appCfg := config.LoadConfig()
+	// Конфигурация: значения по умолчанию < файл --config < окружение < флаги
+	var appCfg config.Receiver
+	config.MustLoad("orderreceiver", &appCfg)
 
-	// Разделяем строку с брокерами на слайс
-	brokers := strings.Split(appCfg.KafkaBrokers, ",")
+	// Логи в формате LOG_FORMAT с уровнем LOG_LEVEL; уровень меняется через /loglevel
+	logLevel, err := logging.Setup(logging.Config{Service: "orderreceiver", Format: appCfg.Log.Format, Level: appCfg.Log.Level})
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+
+	// Контекст отменяется по SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	brokers := appCfg.Kafka.Brokers
+
+	// Ключ сообщения и партиционер определяют, в какую партицию попадёт заказ
+	keyFunc, err := server.KeyFuncByName(appCfg.PartitionKey)
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+	balancer, err := server.BalancerByName(appCfg.Partitioner)
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+
+	validator, err := validation.New(validation.FromConfig(&appCfg.Orders))
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+
+	// Трассировка: контекст приходит в gRPC и уходит дальше в заголовках Kafka
+	shutdownTracing, err := tracing.Setup(tracing.Config{
+		ServiceName: "orderreceiver",
+		Exporter:    appCfg.Trace.Exporter,
+		File:        appCfg.Trace.File,
+	})
+	if err != nil {
+		logging.Fatal("invalid config", logging.KeyError, err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("flush traces", logging.KeyError, err)
+		}
+	}()
+
+	// Метрики сервиса отдаются на /metrics служебного HTTP-сервера
+	reg := metrics.NewRegistry()
+	m := metrics.New(reg)
 
 	// Создаём Kafka writer с конфигурацией брокеров и топика
 	writer := kafka.NewWriter(kafka.WriterConfig{
-		Brokers: brokers,
-		Topic:   appCfg.KafkaTopic,
+		Brokers:  brokers,
+		Topic:    appCfg.Kafka.Topic,
+		Balancer: balancer,
 	})
 	defer writer.Close() // закрываем writer при завершении main
 
+	// Redis хранит ключи идемпотентности принятых заказов
+	rdb := redis.NewClient(&redis.Options{Addr: appCfg.Redis.Addr, Password: appCfg.Redis.Password})
+	defer rdb.Close()
+	rdb.AddHook(tracing.RedisHook())
+	rdb.AddHook(m.RedisHook())
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		logging.Fatal("cannot connect to Redis", "addr", appCfg.Redis.Addr, logging.KeyError, err)
+	}
+
 	// Создаём TCP listener для gRPC сервера
-	lis, err := net.Listen("tcp", appCfg.OrderServiceAddr)
+	lis, err := net.Listen("tcp", appCfg.Addr)
 	if err != nil {
-		log.Fatalf("listen: %v", err) // если порт занят или ошибка сети → завершаем приложение
+		logging.Fatal("listen", "addr", appCfg.Addr, logging.KeyError, err)
 	}
 
 	// Создаём gRPC сервер
-	s := grpc.NewServer()
+	// личность клиента из сертификата (mTLS) доступна обработчикам и попадает в логи
+	unary := []grpc.UnaryServerInterceptor{logging.UnaryServerInterceptor(), tlsutil.UnaryServerInterceptor(), m.UnaryServerInterceptor()}
+	stream := []grpc.StreamServerInterceptor{logging.StreamServerInterceptor(), tlsutil.StreamServerInterceptor(), m.StreamServerInterceptor()}
 
-	// Регистрируем наш сервис OrderService
-	pb.RegisterOrderServiceServer(s, server.NewOrderServer(writer))
+	// проверка API-ключа или JWT идёт после метрик, чтобы отказы считались по кодам
+	var authz *auth.Authorizer
+	if appCfg.Auth.Enabled {
+		authCfg, err := auth.FromConfig(&appCfg.Auth)
+		if err != nil {
+			logging.Fatal("invalid config", logging.KeyError, err)
+		}
+		authz = auth.NewAuthorizer(authCfg)
+		unary = append(unary, authz.UnaryServerInterceptor())
+		stream = append(stream, authz.StreamServerInterceptor())
+		slog.Info("auth enabled", "api_keys", appCfg.Auth.APIKeysFile != "", "jwks", appCfg.Auth.JWT.JWKSFile)
+	}
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+
+	// TLS включается путями к сертификату и ключу, mTLS — ещё и CA клиентов;
+	// новые файлы после ротации подхватываются без перезапуска
+	if appCfg.TLS.Enabled() {
+		certs, err := tlsutil.NewServer(tlsutil.Config{
+			CertFile:     appCfg.TLS.CertFile,
+			KeyFile:      appCfg.TLS.KeyFile,
+			ClientCAFile: appCfg.TLS.ClientCAFile,
+		})
+		if err != nil {
+			logging.Fatal("invalid config", logging.KeyError, err)
+		}
+		opts = append(opts, grpc.Creds(certs.Credentials()))
+		go certs.Run(ctx, appCfg.TLS.ReloadInterval)
+		slog.Info("TLS enabled", "mtls", appCfg.TLS.ClientCAFile != "", "not_after", certs.NotAfter())
+	}
+	s := grpc.NewServer(opts...)
+
+	// Лимит частоты заказов на клиента; с backend redis он общий для всех реплик приёмника
+	var limiter ratelimit.Limiter
+	if appCfg.RateLimit.Enabled {
+		limit := ratelimit.FromConfig(&appCfg.RateLimit)
+		if appCfg.RateLimit.Backend == "redis" {
+			limiter = ratelimit.NewRedis(rdb, "ratelimit:orders:", limit)
+		} else {
+			limiter = ratelimit.NewLocal(limit)
+		}
+		slog.Info("rate limit enabled", "backend", appCfg.RateLimit.Backend, "rate", limit.Rate, "per", limit.Per, "burst", limit.Burst)
+	}
 
-	log.Printf("Сервис заказов слушает на порту %s", appCfg.OrderServiceAddr)
+	// Готовность: отвечает Redis и запись заказов не стоит. Kafka проверяется только без outbox:
+	// с ним заказы принимаются и при недоступной Kafka, и готовность зависит от размера outbox
+	readyChecks := []health.Check{health.RedisPing(rdb)}
+
+	// Регистрируем наш сервис OrderService; запись в Kafka передаёт трассу и пишет метрики.
+	// В режиме outbox заказ принимается после записи в локальный файл, а в Kafka его публикует relay.
+	// Иначе запись идёт в Kafka сразу через пределы: при медленной Kafka лишние заказы получают Unavailable
+	var (
+		publisher server.KafkaWriter
+		producer  *backpressure.Writer
+		store     *outbox.Store
+		relay     *outbox.Relay
+	)
+	if appCfg.Outbox.Enabled {
+		store, err = outbox.Open(appCfg.Outbox.Path, appCfg.Outbox.MaxBacklog)
+		if err != nil {
+			logging.Fatal("open outbox", "path", appCfg.Outbox.Path, logging.KeyError, err)
+		}
+		defer store.Close()
+		relay = outbox.NewRelay(store, m.InstrumentWriter(writer, appCfg.Kafka.Topic), outbox.RelayConfig{
+			BatchSize:     appCfg.Outbox.BatchSize,
+			RetryInterval: appCfg.Outbox.RetryInterval,
+		}, m)
+		go relay.Run(ctx)
+		publisher = tracing.InstrumentWriter(store, appCfg.Kafka.Topic)
+		readyChecks = append(readyChecks, relay.Check())
+		slog.Info("outbox enabled", "path", appCfg.Outbox.Path, "backlog", store.Backlog())
+	} else {
+		producer = backpressure.New(
+			m.InstrumentWriter(tracing.InstrumentWriter(writer, appCfg.Kafka.Topic), appCfg.Kafka.Topic),
+			backpressure.FromConfig(&appCfg.Backpressure), m)
+		publisher = producer
+		readyChecks = append(readyChecks, health.KafkaMetadata(brokers, appCfg.Kafka.Topic), producer.Check())
+	}
+	orders := server.NewOrderServer(publisher, rdb, server.OrderServerConfig{
+		DedupWindow: appCfg.IdempotencyWindow,
+		Key:         keyFunc,
+		Validator:   validator,
+		Limiter:     limiter,
+	})
+	pb.RegisterOrderServiceServer(s, orders)
+
+	// SIGHUP или изменение файла --config меняют уровень логов, окно идемпотентности,
+	// правила проверки заказов, правила доступа к методам, лимит частоты и пределы записи без перезапуска
+	reloader := config.NewReloader(&appCfg, os.Args[1:], os.LookupEnv, func(cfg *config.Receiver) error {
+		level, err := logging.ParseLevel(cfg.Log.Level)
+		if err != nil {
+			return err
+		}
+		validator, err := validation.New(validation.FromConfig(&cfg.Orders))
+		if err != nil {
+			return err
+		}
+		logLevel.Set(level)
+		orders.Apply(server.OrderSettings{DedupWindow: cfg.IdempotencyWindow, Validator: validator})
+		if authz != nil {
+			authz.SetRules(auth.ParseRules(cfg.Auth.Rules))
+		}
+		if limiter != nil {
+			limiter.SetLimit(ratelimit.FromConfig(&cfg.RateLimit))
+		}
+		if producer != nil {
+			producer.Apply(backpressure.FromConfig(&cfg.Backpressure))
+		}
+		return nil
+	})
+	go reloader.Run(ctx, appCfg.ReloadInterval)
+
+	slog.Info("OrderService listening", "addr", appCfg.Addr)
 
 	// Включаем reflection
 	reflection.Register(s)
 
+	// grpc.health.v1: SERVING, пока проходят readyChecks (Kafka отвечает и предохранитель записи
+	// не разомкнут или outbox не переполнен); с начала остановки — NOT_SERVING
+	ready := health.NewMonitor(appCfg.Health.Interval, appCfg.Health.Timeout, readyChecks...)
+	healthSrv := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(s, healthSrv)
+	health.ServeGRPC(ready, healthSrv, pb.OrderService_ServiceDesc.ServiceName)
+	go ready.Run(ctx)
+
+	// Служебный HTTP: /metrics, /loglevel и /readyz
+	admin := server.NewAdminServer(appCfg.AdminAddr, reg)
+	admin.Handle("/loglevel", logging.LevelHandler(logLevel))
+	admin.Handle("/readyz", ready.Handler())
+
+	// служебный сервер работает до выхода из main, чтобы пробы видели остановку
+	adminCtx, stopAdmin := context.WithCancel(context.Background())
+	defer stopAdmin()
+	go func() {
+		if err := admin.Run(adminCtx); err != nil {
+			logging.Fatal("admin server", logging.KeyError, err)
+		}
+	}()
+
 	// Запускаем gRPC сервер и обрабатываем входящие запросы
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("serve: %v", err) // если сервер упал → логируем и завершаем
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.Serve(lis) }()
+
+	select {
+	case err := <-serveErr:
+		logging.Fatal("gRPC serve failed", logging.KeyError, err)
+	case <-ctx.Done():
+	}
+	ready.Shutdown()
+
+	// Даём активным CreateOrder дописать в Kafka, затем writer закрывается через defer
+	slog.Info("shutting down, draining", "timeout", appCfg.ShutdownTimeout.String())
+	server.GracefulStop(s, appCfg.ShutdownTimeout)
+
+	// Принятые заказы из outbox публикуются до выхода; что не успело — после следующего запуска
+	if relay != nil {
+		flushCtx, cancel := context.WithTimeout(context.Background(), appCfg.ShutdownTimeout)
+		defer cancel()
+		if err := relay.Flush(flushCtx); err != nil {
+			slog.Warn("outbox not fully published, the rest is sent after restart", "backlog", store.Backlog(), logging.KeyError, err)
+		}
 	}
 }
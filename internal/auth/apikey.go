@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// APIKeyHeader — заголовок gRPC-метаданных со статическим ключом
+const APIKeyHeader = "x-api-key"
+
+// apiKeyEntry — ключ в файле; хранится только SHA-256 ключа в hex
+type apiKeyEntry struct {
+	Name   string   `yaml:"name"`
+	SHA256 string   `yaml:"sha256"`
+	Tenant string   `yaml:"tenant"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// APIKeys проверяет статические ключи из заголовка x-api-key
+type APIKeys struct {
+	byHash map[string]*Principal
+}
+
+// LoadAPIKeys читает файл ключей:
+//
+//	keys:
+//	  - name: billing
+//	    sha256: <hex SHA-256 ключа>
+//	    tenant: acme
+//	    scopes: [orders:write, orders:read]
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("api keys: %w", err)
+	}
+	var file struct {
+		Keys []apiKeyEntry `yaml:"keys"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("api keys: parse %s: %w", path, err)
+	}
+	keys := &APIKeys{byHash: map[string]*Principal{}}
+	for i, e := range file.Keys {
+		hash := strings.ToLower(e.SHA256)
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("api keys: %s: key %d (%s): sha256 must be 64 hex characters", path, i, e.Name)
+		}
+		if e.Name == "" {
+			return nil, fmt.Errorf("api keys: %s: key %d has no name", path, i)
+		}
+		keys.byHash[hash] = &Principal{Subject: e.Name, Tenant: e.Tenant, Scopes: e.Scopes, Method: "api_key"}
+	}
+	return keys, nil
+}
+
+// Authenticate ищет ключ по его SHA-256: сам ключ нигде не хранится
+func (k *APIKeys) Authenticate(_ context.Context, md metadata.MD) (*Principal, error) {
+	values := md.Get(APIKeyHeader)
+	if len(values) == 0 {
+		return nil, errNoCredentials
+	}
+	sum := sha256.Sum256([]byte(values[0]))
+	p, ok := k.byHash[hex.EncodeToString(sum[:])]
+	if !ok {
+		return nil, errors.New("unknown api key")
+	}
+	copied := *p
+	return &copied, nil
+}
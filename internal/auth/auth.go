@@ -0,0 +1,186 @@
+// Package auth проверяет, кто вызывает gRPC-метод (API-ключ или JWT), и разрешает
+// вызов по scope, объявленным для метода в конфигурации.
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Principal — проверенный вызывающий
+type Principal struct {
+	// Subject — имя API-ключа или sub токена
+	Subject string
+	// Tenant — покупатель, чьи заказы доступны вызывающему; пусто — ни чьи, кроме как с AdminScope
+	Tenant string
+	Scopes []string
+	// Method — как подтверждена личность: api_key или jwt
+	Method string
+	// Admin — у вызывающего есть административный scope и доступ к заказам всех покупателей
+	Admin bool
+}
+
+// HasScope сообщает, есть ли у вызывающего scope
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal сохраняет вызывающего в контексте запроса
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext возвращает вызывающего; nil — проверка выключена или метод публичный
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// CanAccess сообщает, может ли вызывающий работать с заказами покупателя customerID.
+// Без проверки (нет Principal) доступ не ограничен.
+func CanAccess(ctx context.Context, customerID string) bool {
+	p := FromContext(ctx)
+	return p == nil || p.Admin || (p.Tenant != "" && p.Tenant == customerID)
+}
+
+// errNoCredentials — в запросе нет данных, которые понимает этот способ проверки
+var errNoCredentials = errors.New("no credentials")
+
+// Authenticator проверяет данные вызывающего из метаданных запроса.
+// Ошибка errNoCredentials значит, что их нужно искать у следующего способа.
+type Authenticator interface {
+	Authenticate(ctx context.Context, md metadata.MD) (*Principal, error)
+}
+
+// Rules — scope, нужные для вызова метода: ключ — полное имя метода (/order.OrderService/CreateOrder),
+// сервис целиком (/order.CacheService/*) или * для всех остальных. Достаточно любого из scope.
+// Метод без правила доступен любому проверенному вызывающему.
+type Rules map[string][]string
+
+// ParseRules разбирает правила из конфигурации: значение — scope через пробел
+func ParseRules(raw map[string]string) Rules {
+	rules := Rules{}
+	for method, scopes := range raw {
+		rules[method] = strings.Fields(scopes)
+	}
+	return rules
+}
+
+// required возвращает scope метода по самому точному правилу
+func (r Rules) required(method string) []string {
+	if scopes, ok := r[method]; ok {
+		return scopes
+	}
+	if i := strings.LastIndex(method, "/"); i > 0 {
+		if scopes, ok := r[method[:i]+"/*"]; ok {
+			return scopes
+		}
+	}
+	return r["*"]
+}
+
+// Config задаёт проверку вызовов
+type Config struct {
+	// Authenticators пробуются по порядку до первого, нашедшего свои данные в запросе
+	Authenticators []Authenticator
+	Rules          Rules
+	// PublicMethods вызываются без проверки, например grpc.health.v1
+	PublicMethods []string
+	// AdminScope даёт доступ к заказам всех покупателей
+	AdminScope string
+}
+
+// Authorizer — цепочка проверки для gRPC-сервера. Правила меняются на ходу через SetRules.
+type Authorizer struct {
+	cfg   Config
+	rules atomic.Pointer[Rules]
+}
+
+// NewAuthorizer конструктор
+func NewAuthorizer(cfg Config) *Authorizer {
+	a := &Authorizer{cfg: cfg}
+	a.SetRules(cfg.Rules)
+	return a
+}
+
+// SetRules подменяет правила доступа; начатые вызовы уже проверены по старым
+func (a *Authorizer) SetRules(rules Rules) {
+	a.rules.Store(&rules)
+}
+
+// authorize проверяет вызывающего и его право на метод и кладёт Principal в контекст
+func (a *Authorizer) authorize(ctx context.Context, method string) (context.Context, error) {
+	if slices.Contains(a.cfg.PublicMethods, method) {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var principal *Principal
+	for _, authn := range a.cfg.Authenticators {
+		p, err := authn.Authenticate(ctx, md)
+		if errors.Is(err, errNoCredentials) {
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "authentication failed", logging.KeyError, err)
+			return ctx, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		principal = p
+		break
+	}
+	if principal == nil {
+		return ctx, status.Error(codes.Unauthenticated, "missing credentials: send x-api-key or authorization: Bearer <jwt>")
+	}
+
+	principal.Admin = a.cfg.AdminScope != "" && principal.HasScope(a.cfg.AdminScope)
+	ctx = logging.With(WithPrincipal(ctx, principal), logging.KeyPrincipal, principal.Subject)
+
+	required := (*a.rules.Load()).required(method)
+	if len(required) > 0 && !principal.Admin && !slices.ContainsFunc(required, principal.HasScope) {
+		slog.WarnContext(ctx, "permission denied", "required_scopes", required)
+		return ctx, status.Errorf(codes.PermissionDenied, "%s requires scope %s", method, strings.Join(required, " or "))
+	}
+	return ctx, nil
+}
+
+// UnaryServerInterceptor проверяет вызывающего до обработчика
+func (a *Authorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor делает то же для потоковых вызовов
+func (a *Authorizer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &principalStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// principalStream подменяет контекст потока
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context { return s.ctx }
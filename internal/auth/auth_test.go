@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var b64 = base64.RawURLEncoding
+
+// sign собирает токен; key — *rsa.PrivateKey для RS256 или []byte для HS256
+func sign(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signed))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:])
+		require.NoError(t, err)
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+	return signed + "." + b64.EncodeToString(sig)
+}
+
+func writeJWKS(t *testing.T, path string, keys ...map[string]string) {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "kid": kid, "alg": "RS256", "use": "sig",
+		"n": b64.EncodeToString(key.N.Bytes()),
+		"e": b64.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func TestJWTVerifiesRS256AndHS256(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	octKey := []byte("0123456789abcdef0123456789abcdef")
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwks, rsaJWK("rsa-1", rsaKey), map[string]string{"kty": "oct", "kid": "hs-1", "k": b64.EncodeToString(octKey)})
+
+	verifier, err := NewJWT(JWTConfig{JWKSFile: jwks, Issuer: "https://idp.example", Audience: "orders"})
+	require.NoError(t, err)
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{
+			"sub": "svc-billing", "iss": "https://idp.example", "aud": []string{"orders"},
+			"exp": now.Add(time.Minute).Unix(), "tenant": "acme", "scope": "orders:write orders:read",
+		}
+	}
+
+	p, err := verifier.Verify(sign(t, "RS256", "rsa-1", rsaKey, valid()))
+	require.NoError(t, err)
+	require.Equal(t, &Principal{Subject: "svc-billing", Tenant: "acme", Scopes: []string{"orders:write", "orders:read"}, Method: "jwt"}, p)
+
+	scp := valid()
+	delete(scp, "scope")
+	scp["scp"] = []string{"orders:read"}
+	p, err = verifier.Verify(sign(t, "HS256", "hs-1", octKey, scp))
+	require.NoError(t, err)
+	require.Equal(t, []string{"orders:read"}, p.Scopes)
+
+	expired := valid()
+	expired["exp"] = now.Add(-time.Minute).Unix()
+	noExp := valid()
+	delete(noExp, "exp")
+	otherAud := valid()
+	otherAud["aud"] = "billing"
+	otherIss := valid()
+	otherIss["iss"] = "https://evil.example"
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	for name, token := range map[string]string{
+		"expired":       sign(t, "RS256", "rsa-1", rsaKey, expired),
+		"no exp":        sign(t, "RS256", "rsa-1", rsaKey, noExp),
+		"wrong aud":     sign(t, "RS256", "rsa-1", rsaKey, otherAud),
+		"wrong iss":     sign(t, "RS256", "rsa-1", rsaKey, otherIss),
+		"foreign key":   sign(t, "RS256", "rsa-1", otherKey, valid()),
+		"unknown kid":   sign(t, "RS256", "rsa-2", otherKey, valid()),
+		"alg confusion": sign(t, "HS256", "rsa-1", b64.AppendEncode(nil, rsaKey.N.Bytes()), valid()),
+		"alg none":      b64.EncodeToString([]byte(`{"alg":"none","kid":"rsa-1"}`)) + "." + b64.EncodeToString([]byte(`{"sub":"x"}`)) + ".",
+		"malformed":     "not-a-jwt",
+	} {
+		_, err := verifier.Verify(token)
+		require.Error(t, err, name)
+	}
+}
+
+func TestJWTPicksUpRotatedJWKS(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwks, rsaJWK("2024", oldKey))
+
+	verifier, err := NewJWT(JWTConfig{JWKSFile: jwks})
+	require.NoError(t, err)
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+	claims := map[string]any{"sub": "svc", "exp": now.Add(time.Hour).Unix()}
+
+	writeJWKS(t, jwks, rsaJWK("2024", oldKey), rsaJWK("2025", newKey))
+	_, err = verifier.Verify(sign(t, "RS256", "2025", newKey, claims))
+	require.Error(t, err, "JWKS is not re-read more often than jwksRefreshInterval")
+
+	now = now.Add(jwksRefreshInterval)
+	_, err = verifier.Verify(sign(t, "RS256", "2025", newKey, claims))
+	require.NoError(t, err)
+}
+
+func TestAPIKeysMatchByHash(t *testing.T) {
+	sum := sha256.Sum256([]byte("s3cret"))
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`keys:
+  - name: billing
+    sha256: `+hex.EncodeToString(sum[:])+`
+    tenant: acme
+    scopes: [orders:write]
+`), 0o600))
+	keys, err := LoadAPIKeys(path)
+	require.NoError(t, err)
+
+	p, err := keys.Authenticate(context.Background(), metadata.Pairs(APIKeyHeader, "s3cret"))
+	require.NoError(t, err)
+	require.Equal(t, &Principal{Subject: "billing", Tenant: "acme", Scopes: []string{"orders:write"}, Method: "api_key"}, p)
+
+	_, err = keys.Authenticate(context.Background(), metadata.Pairs(APIKeyHeader, "guess"))
+	require.Error(t, err)
+	_, err = keys.Authenticate(context.Background(), metadata.MD{})
+	require.ErrorIs(t, err, errNoCredentials)
+}
+
+// staticKeys — вызывающие по значению x-api-key
+type staticKeys map[string]*Principal
+
+func (s staticKeys) Authenticate(_ context.Context, md metadata.MD) (*Principal, error) {
+	v := md.Get(APIKeyHeader)
+	if len(v) == 0 {
+		return nil, errNoCredentials
+	}
+	if p, ok := s[v[0]]; ok {
+		copied := *p
+		return &copied, nil
+	}
+	return nil, status.Error(codes.Unauthenticated, "unknown")
+}
+
+func TestAuthorizerAppliesMethodRules(t *testing.T) {
+	a := NewAuthorizer(Config{
+		Authenticators: []Authenticator{staticKeys{
+			"writer": {Subject: "writer", Tenant: "acme", Scopes: []string{"orders:write"}},
+			"reader": {Subject: "reader", Tenant: "acme", Scopes: []string{"orders:read"}},
+			"admin":  {Subject: "ops", Scopes: []string{"orders:admin"}},
+		}},
+		Rules: ParseRules(map[string]string{
+			"/order.OrderService/CreateOrder": "orders:write",
+			"/order.CacheService/*":           "orders:read orders:write",
+		}),
+		PublicMethods: []string{"/grpc.health.v1.Health/Check"},
+		AdminScope:    "orders:admin",
+	})
+	interceptor := a.UnaryServerInterceptor()
+	call := func(method, key string) (*Principal, codes.Code) {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(APIKeyHeader, key))
+		}
+		var seen *Principal
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ any) (any, error) {
+			seen = FromContext(ctx)
+			return nil, nil
+		})
+		return seen, status.Code(err)
+	}
+
+	p, code := call("/order.OrderService/CreateOrder", "writer")
+	require.Equal(t, codes.OK, code)
+	require.Equal(t, "writer", p.Subject)
+
+	_, code = call("/order.OrderService/CreateOrder", "reader")
+	require.Equal(t, codes.PermissionDenied, code)
+	_, code = call("/order.CacheService/GetOrderResult", "reader")
+	require.Equal(t, codes.OK, code)
+	_, code = call("/order.CacheService/GetOrderResult", "writer")
+	require.Equal(t, codes.OK, code, "any of the listed scopes is enough")
+
+	p, code = call("/order.OrderService/CreateOrder", "admin")
+	require.Equal(t, codes.OK, code)
+	require.True(t, p.Admin)
+
+	_, code = call("/order.OrderService/CreateOrder", "")
+	require.Equal(t, codes.Unauthenticated, code)
+	_, code = call("/order.OrderService/CreateOrder", "stolen")
+	require.Equal(t, codes.Unauthenticated, code)
+	p, code = call("/grpc.health.v1.Health/Check", "")
+	require.Equal(t, codes.OK, code)
+	require.Nil(t, p)
+
+	// правила меняются без перезапуска
+	a.SetRules(ParseRules(map[string]string{"*": "orders:read"}))
+	_, code = call("/order.OrderService/CreateOrder", "reader")
+	require.Equal(t, codes.OK, code)
+}
+
+func TestCanAccessScopesByTenant(t *testing.T) {
+	ctx := context.Background()
+	require.True(t, CanAccess(ctx, "acme"), "auth disabled")
+
+	tenant := WithPrincipal(ctx, &Principal{Subject: "svc", Tenant: "acme"})
+	require.True(t, CanAccess(tenant, "acme"))
+	require.False(t, CanAccess(tenant, "globex"))
+	require.False(t, CanAccess(WithPrincipal(ctx, &Principal{Subject: "svc"}), ""), "no tenant claim")
+	require.True(t, CanAccess(WithPrincipal(ctx, &Principal{Subject: "ops", Admin: true}), "globex"))
+}
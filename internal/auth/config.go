@@ -0,0 +1,34 @@
+package auth
+
+import "github.com/go-portfolio/order-pipeline/internal/config"
+
+// FromConfig собирает проверку из секции auth конфигурации сервиса: сначала API-ключи, затем JWT
+func FromConfig(cfg *config.Auth) (Config, error) {
+	out := Config{
+		Rules:         ParseRules(cfg.Rules),
+		PublicMethods: cfg.PublicMethods,
+		AdminScope:    cfg.AdminScope,
+	}
+	if cfg.APIKeysFile != "" {
+		keys, err := LoadAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			return Config{}, err
+		}
+		out.Authenticators = append(out.Authenticators, keys)
+	}
+	if cfg.JWT.JWKSFile != "" || cfg.JWT.HS256Secret != "" {
+		verifier, err := NewJWT(JWTConfig{
+			JWKSFile:    cfg.JWT.JWKSFile,
+			HS256Secret: cfg.JWT.HS256Secret,
+			Issuer:      cfg.JWT.Issuer,
+			Audience:    cfg.JWT.Audience,
+			TenantClaim: cfg.JWT.TenantClaim,
+			Leeway:      cfg.JWT.Leeway,
+		})
+		if err != nil {
+			return Config{}, err
+		}
+		out.Authenticators = append(out.Authenticators, verifier)
+	}
+	return out, nil
+}
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// JWTConfig задаёт проверку токенов из заголовка authorization: Bearer <jwt>
+type JWTConfig struct {
+	// JWKSFile — локальный файл JWKS с ключами RSA (RS256) и/или oct (HS256)
+	JWKSFile string
+	// HS256Secret — общий секрет HS256 для токенов без kid
+	HS256Secret string
+	// Issuer и Audience, если заданы, должны совпасть с iss и aud токена
+	Issuer   string
+	Audience string
+	// TenantClaim — claim с покупателем, чьи заказы доступны вызывающему
+	TenantClaim string
+	// Leeway — допуск расхождения часов при проверке exp и nbf
+	Leeway time.Duration
+}
+
+// jwksRefreshInterval — не чаще этого файл JWKS перечитывается из-за незнакомого kid
+const jwksRefreshInterval = 30 * time.Second
+
+// JWT проверяет подписанные HS256 и RS256 токены. Незнакомый kid перечитывает файл JWKS,
+// так что новый ключ подхватывается без перезапуска.
+type JWT struct {
+	cfg JWTConfig
+	now func() time.Time
+
+	mu       sync.RWMutex
+	keys     map[string]jwk
+	loadedAt time.Time
+}
+
+// jwk — ключ из JWKS, уже разобранный
+type jwk struct {
+	alg    string
+	rsa    *rsa.PublicKey
+	secret []byte
+}
+
+// NewJWT конструктор; ошибка — файл JWKS не читается или в нём нет пригодных ключей
+func NewJWT(cfg JWTConfig) (*JWT, error) {
+	if cfg.JWKSFile == "" && cfg.HS256Secret == "" {
+		return nil, errors.New("jwt: set a JWKS file or an HS256 secret")
+	}
+	if cfg.TenantClaim == "" {
+		cfg.TenantClaim = "tenant"
+	}
+	j := &JWT{cfg: cfg, now: time.Now, keys: map[string]jwk{}}
+	if cfg.JWKSFile != "" {
+		if err := j.loadJWKS(); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// loadJWKS перечитывает файл; при ошибке остаются прежние ключи
+func (j *JWT) loadJWKS() error {
+	data, err := os.ReadFile(j.cfg.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("jwt: %w", err)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("jwt: parse %s: %w", j.cfg.JWKSFile, err)
+	}
+	keys := map[string]jwk{}
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return fmt.Errorf("jwt: %s: key %d (%s): bad RSA modulus or exponent", j.cfg.JWKSFile, i, k.Kid)
+			}
+			keys[k.Kid] = jwk{alg: "RS256", rsa: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}}
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil || len(secret) == 0 {
+				return fmt.Errorf("jwt: %s: key %d (%s): bad symmetric key", j.cfg.JWKSFile, i, k.Kid)
+			}
+			keys[k.Kid] = jwk{alg: "HS256", secret: secret}
+		default:
+			// другие типы ключей (EC и т. п.) не поддерживаются и пропускаются
+			continue
+		}
+		if k.Alg != "" && k.Alg != keys[k.Kid].alg {
+			return fmt.Errorf("jwt: %s: key %d (%s): alg %s does not match key type %s", j.cfg.JWKSFile, i, k.Kid, k.Alg, k.Kty)
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("jwt: no RSA or oct signing keys in %s", j.cfg.JWKSFile)
+	}
+	j.mu.Lock()
+	j.keys, j.loadedAt = keys, j.now()
+	j.mu.Unlock()
+	return nil
+}
+
+// key ищет ключ по kid; незнакомый kid перечитывает JWKS, но не чаще jwksRefreshInterval
+func (j *JWT) key(kid string) (jwk, bool) {
+	if kid == "" && j.cfg.HS256Secret != "" {
+		return jwk{alg: "HS256", secret: []byte(j.cfg.HS256Secret)}, true
+	}
+	j.mu.RLock()
+	k, ok := j.keys[kid]
+	stale := j.now().Sub(j.loadedAt) >= jwksRefreshInterval
+	j.mu.RUnlock()
+	if ok || j.cfg.JWKSFile == "" || !stale {
+		return k, ok
+	}
+	if err := j.loadJWKS(); err != nil {
+		return jwk{}, false
+	}
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	k, ok = j.keys[kid]
+	return k, ok
+}
+
+// claims — поля токена, которые нужны для проверки
+type claims struct {
+	Sub   string   `json:"sub"`
+	Iss   string   `json:"iss"`
+	Aud   audience `json:"aud"`
+	Exp   *int64   `json:"exp"`
+	Nbf   *int64   `json:"nbf"`
+	Scope string   `json:"scope"`
+	Scp   []string `json:"scp"`
+}
+
+// audience — aud бывает строкой или массивом строк
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = many
+	return nil
+}
+
+// Authenticate проверяет подпись, срок действия, издателя и аудиторию токена
+func (j *JWT) Authenticate(_ context.Context, md metadata.MD) (*Principal, error) {
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, errNoCredentials
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return nil, errNoCredentials
+	}
+	return j.Verify(token)
+}
+
+// Verify разбирает и проверяет токен
+func (j *JWT) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("jwt: malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("jwt: header: %w", err)
+	}
+	key, ok := j.key(header.Kid)
+	if !ok {
+		return nil, fmt.Errorf("jwt: unknown key %q", header.Kid)
+	}
+	// алгоритм задаёт ключ, а не токен: иначе alg=none или HS256 с открытым ключом RSA прошли бы
+	if header.Alg != key.alg {
+		return nil, fmt.Errorf("jwt: alg %q is not allowed for key %q", header.Alg, header.Kid)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("jwt: malformed signature")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	switch key.alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, errors.New("jwt: bad signature")
+		}
+	case "RS256":
+		sum := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key.rsa, crypto.SHA256, sum[:], sig); err != nil {
+			return nil, errors.New("jwt: bad signature")
+		}
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, fmt.Errorf("jwt: claims: %w", err)
+	}
+	var raw map[string]any
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("jwt: claims: %w", err)
+	}
+	now := j.now()
+	if c.Exp == nil {
+		return nil, errors.New("jwt: exp is required")
+	}
+	if now.After(time.Unix(*c.Exp, 0).Add(j.cfg.Leeway)) {
+		return nil, errors.New("jwt: token expired")
+	}
+	if c.Nbf != nil && now.Add(j.cfg.Leeway).Before(time.Unix(*c.Nbf, 0)) {
+		return nil, errors.New("jwt: token not valid yet")
+	}
+	if j.cfg.Issuer != "" && c.Iss != j.cfg.Issuer {
+		return nil, fmt.Errorf("jwt: unexpected issuer %q", c.Iss)
+	}
+	if j.cfg.Audience != "" && !slices.Contains(c.Aud, j.cfg.Audience) {
+		return nil, fmt.Errorf("jwt: token is not for audience %q", j.cfg.Audience)
+	}
+	if c.Sub == "" {
+		return nil, errors.New("jwt: sub is required")
+	}
+
+	tenant, _ := raw[j.cfg.TenantClaim].(string)
+	scopes := strings.Fields(c.Scope)
+	if len(scopes) == 0 {
+		scopes = c.Scp
+	}
+	return &Principal{Subject: c.Sub, Tenant: tenant, Scopes: scopes, Method: "jwt"}, nil
+}
+
+func decodeSegment(seg string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.New("bad base64url")
+	}
+	return json.Unmarshal(data, dst)
+}
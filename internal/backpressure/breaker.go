@@ -0,0 +1,125 @@
+package backpressure
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// State — состояние предохранителя
+type State int
+
+const (
+	// Closed — записи идут в Kafka
+	Closed State = iota
+	// HalfOpen — пауза истекла, одна пробная запись проверяет, восстановилась ли Kafka
+	HalfOpen
+	// Open — записи отклоняются сразу до конца паузы
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half_open"
+	case Open:
+		return "open"
+	}
+	return "unknown"
+}
+
+// breaker размыкается после threshold неудачных записей подряд и через cooldown
+// пропускает одну пробную: удача замыкает его, неудача размыкает снова
+type breaker struct {
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration, now func() time.Time) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: now}
+}
+
+// allow решает, пускать ли запись; probe — это пробная запись после паузы
+func (b *breaker) allow() (probe, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.state = HalfOpen
+		slog.Info("producer circuit half-open, probing Kafka")
+	}
+	switch b.state {
+	case Closed:
+		return false, true
+	case HalfOpen:
+		if b.probing {
+			return false, false
+		}
+		b.probing = true
+		return true, true
+	}
+	return false, false
+}
+
+// success отмечает удачную запись: счётчик сбрасывается, разомкнутый предохранитель замыкается
+func (b *breaker) success(probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+	b.failures = 0
+	if b.state != Closed {
+		b.state = Closed
+		slog.Info("producer circuit closed, Kafka recovered")
+	}
+}
+
+// failure отмечает неудачную запись; неудачная проба размыкает предохранитель на новую паузу
+func (b *breaker) failure(probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+	b.failures++
+	if (b.state == Closed && b.failures >= b.threshold) || (b.state == HalfOpen && probe) {
+		b.state = Open
+		b.openedAt = b.now()
+		slog.Warn("producer circuit open, rejecting orders", "failures", b.failures, "cooldown", b.cooldown)
+	}
+}
+
+// cancel возвращает пробу, если запись не дошла до Kafka или клиент ушёл раньше итога
+func (b *breaker) cancel(probe bool) {
+	if !probe {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// current — состояние с учётом истёкшей паузы
+func (b *breaker) current() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// configure меняет порог и паузу; текущее состояние сохраняется
+func (b *breaker) configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.threshold, b.cooldown = threshold, cooldown
+}
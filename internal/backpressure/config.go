@@ -0,0 +1,15 @@
+package backpressure
+
+import "github.com/go-portfolio/order-pipeline/internal/config"
+
+// FromConfig — пределы из секции backpressure конфигурации приёмника
+func FromConfig(cfg *config.Backpressure) Config {
+	return Config{
+		ProduceTimeout:  cfg.ProduceTimeout,
+		MinInFlight:     cfg.MinInFlight,
+		MaxInFlight:     cfg.MaxInFlight,
+		TargetLatency:   cfg.TargetLatency,
+		BreakerFailures: cfg.BreakerFailures,
+		BreakerCooldown: cfg.BreakerCooldown,
+	}
+}
@@ -0,0 +1,66 @@
+package backpressure
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// adaptive — предел одновременных записей по схеме AIMD: медленная или неудачная запись
+// сокращает предел на 10%, быстрая при занятом пределе — увеличивает примерно на 1 за «окно».
+// Так при деградации Kafka число ждущих горутин само уменьшается, а после восстановления растёт.
+type adaptive struct {
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	min, max int
+	target   time.Duration
+}
+
+// backoffRatio — во сколько раз сокращается предел после медленной или неудачной записи
+const backoffRatio = 0.9
+
+func newAdaptive(min, max int, target time.Duration) *adaptive {
+	return &adaptive{limit: float64(max), min: min, max: max, target: target}
+}
+
+// acquire занимает место, если предел не достигнут; не блокирует
+func (a *adaptive) acquire() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.inFlight >= int(a.limit) {
+		return false
+	}
+	a.inFlight++
+	return true
+}
+
+// release освобождает место и подстраивает предел по длительности и итогу записи
+func (a *adaptive) release(latency time.Duration, failed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// предел растёт, только если он действительно использовался, иначе он уйдёт в max без нагрузки
+	busy := a.inFlight >= int(a.limit)/2
+	a.inFlight--
+	switch {
+	case failed || latency > a.target:
+		a.limit = math.Max(float64(a.min), a.limit*backoffRatio)
+	case busy:
+		a.limit = math.Min(float64(a.max), a.limit+1/a.limit)
+	}
+}
+
+// resize меняет границы предела; текущий предел сдвигается внутрь новых границ
+func (a *adaptive) resize(min, max int, target time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.min, a.max, a.target = min, max, target
+	a.limit = math.Min(float64(max), math.Max(float64(min), a.limit))
+}
+
+// snapshot — сколько записей в работе и текущий предел
+func (a *adaptive) snapshot() (inFlight, limit int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.inFlight, int(a.limit)
+}
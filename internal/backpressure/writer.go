@@ -0,0 +1,154 @@
+// Package backpressure защищает приёмник от медленной Kafka: ограничивает число одновременных
+// записей адаптивным пределом, обрезает каждую запись своим таймаутом и размыкает
+// предохранитель после серии сбоев, чтобы лишние запросы отклонялись сразу, а не копились.
+package backpressure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/health"
+	"github.com/go-portfolio/order-pipeline/internal/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	// ErrOverloaded — достигнут предел одновременных записей
+	ErrOverloaded = errors.New("too many orders are being published")
+	// ErrCircuitOpen — предохранитель разомкнут после серии сбоев Kafka
+	ErrCircuitOpen = errors.New("kafka producer is failing, circuit open")
+	// ErrProduceTimeout — запись не уложилась в ProduceTimeout
+	ErrProduceTimeout = errors.New("kafka produce timed out")
+)
+
+// Config задаёт пределы записи
+type Config struct {
+	// ProduceTimeout ограничивает одну запись независимо от дедлайна клиента
+	ProduceTimeout time.Duration
+	// MinInFlight и MaxInFlight — границы адаптивного предела одновременных записей
+	MinInFlight int
+	MaxInFlight int
+	// TargetLatency — запись дольше считается признаком перегрузки и сокращает предел
+	TargetLatency time.Duration
+	// BreakerFailures — сколько неудачных записей подряд размыкают предохранитель
+	BreakerFailures int
+	// BreakerCooldown — пауза разомкнутого предохранителя до пробной записи
+	BreakerCooldown time.Duration
+}
+
+// Producer — запись в Kafka, как server.KafkaWriter
+type Producer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Writer — Producer с ограничением нагрузки. Отказ без обращения к Kafka — ErrOverloaded
+// или ErrCircuitOpen; они учитываются в метрике shed_total.
+type Writer struct {
+	next    Producer
+	metrics *metrics.Metrics
+	timeout atomic.Int64
+	limiter *adaptive
+	breaker *breaker
+	shed    atomic.Int64
+}
+
+// New оборачивает next; m может быть nil
+func New(next Producer, cfg Config, m *metrics.Metrics) *Writer {
+	w := &Writer{
+		next:    next,
+		metrics: m,
+		limiter: newAdaptive(cfg.MinInFlight, cfg.MaxInFlight, cfg.TargetLatency),
+		breaker: newBreaker(cfg.BreakerFailures, cfg.BreakerCooldown, time.Now),
+	}
+	w.timeout.Store(int64(cfg.ProduceTimeout))
+	w.observe()
+	return w
+}
+
+// Apply меняет пределы без перезапуска; накопленное состояние предела и предохранителя сохраняется
+func (w *Writer) Apply(cfg Config) {
+	w.timeout.Store(int64(cfg.ProduceTimeout))
+	w.limiter.resize(cfg.MinInFlight, cfg.MaxInFlight, cfg.TargetLatency)
+	w.breaker.configure(cfg.BreakerFailures, cfg.BreakerCooldown)
+	w.observe()
+}
+
+// WriteMessages пишет в Kafka, если предохранитель замкнут и предел не достигнут
+func (w *Writer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	probe, ok := w.breaker.allow()
+	if !ok {
+		w.reject("circuit_open")
+		return ErrCircuitOpen
+	}
+	if !w.limiter.acquire() {
+		w.breaker.cancel(probe)
+		w.reject("overloaded")
+		return ErrOverloaded
+	}
+	w.observe()
+
+	produceCtx, cancel := context.WithTimeout(ctx, time.Duration(w.timeout.Load()))
+	start := time.Now()
+	err := w.next.WriteMessages(produceCtx, msgs...)
+	latency := time.Since(start)
+	timedOut := errors.Is(produceCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+	cancel()
+
+	// клиент отменил вызов сам — это ничего не говорит о Kafka
+	clientGone := err != nil && ctx.Err() != nil
+	w.limiter.release(latency, err != nil && !clientGone)
+	switch {
+	case clientGone:
+		w.breaker.cancel(probe)
+	case err != nil:
+		w.breaker.failure(probe)
+	default:
+		w.breaker.success(probe)
+	}
+	w.observe()
+
+	if err != nil && timedOut {
+		return fmt.Errorf("%w after %s: %w", ErrProduceTimeout, time.Duration(w.timeout.Load()), err)
+	}
+	return err
+}
+
+// Close закрывает обёрнутый Producer
+func (w *Writer) Close() error {
+	return w.next.Close()
+}
+
+func (w *Writer) reject(reason string) {
+	w.shed.Add(1)
+	w.metrics.Shed(reason)
+}
+
+// observe переносит текущее состояние в метрики
+func (w *Writer) observe() {
+	inFlight, limit := w.limiter.snapshot()
+	w.metrics.ObserveProduceLimit(inFlight, limit)
+	w.metrics.SetBreakerState(int(w.breaker.current()))
+}
+
+// State — состояние предохранителя
+func (w *Writer) State() State {
+	return w.breaker.current()
+}
+
+// Check — проверка готовности: не готов, пока предохранитель разомкнут. После паузы проверка
+// снова проходит, чтобы балансировщик вернул трафик и пробная запись могла замкнуть предохранитель.
+func (w *Writer) Check() health.Check {
+	return health.Check{Name: "producer", Probe: func(context.Context) (string, error) {
+		inFlight, limit := w.limiter.snapshot()
+		state := w.breaker.current()
+		detail := fmt.Sprintf("circuit %s, in flight %d/%d, shed %d", state, inFlight, limit, w.shed.Load())
+		if state == Open {
+			return "", fmt.Errorf("%s: %w", detail, ErrCircuitOpen)
+		}
+		return detail, nil
+	}}
+}
@@ -0,0 +1,149 @@
+package backpressure
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProducer вызывает write на каждую запись и считает вызовы
+type fakeProducer struct {
+	calls atomic.Int32
+	write func(ctx context.Context) error
+}
+
+func (p *fakeProducer) WriteMessages(ctx context.Context, _ ...kafka.Message) error {
+	p.calls.Add(1)
+	return p.write(ctx)
+}
+
+func (p *fakeProducer) Close() error { return nil }
+
+func testConfig() Config {
+	return Config{
+		ProduceTimeout:  time.Second,
+		MinInFlight:     1,
+		MaxInFlight:     2,
+		TargetLatency:   time.Second,
+		BreakerFailures: 3,
+		BreakerCooldown: time.Minute,
+	}
+}
+
+func TestWriterShedsOverInFlightLimit(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	p := &fakeProducer{write: func(context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}}
+	w := New(p, testConfig(), nil)
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, w.WriteMessages(context.Background()))
+		}()
+	}
+	<-started
+	<-started
+
+	// третья запись отклоняется сразу, не дожидаясь Kafka
+	require.ErrorIs(t, w.WriteMessages(context.Background()), ErrOverloaded)
+	require.EqualValues(t, 2, p.calls.Load())
+	close(release)
+	wg.Wait()
+
+	detail, err := w.Check().Probe(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "circuit closed, in flight 0/2, shed 1", detail)
+}
+
+func TestWriterCutsProduceAtTimeout(t *testing.T) {
+	p := &fakeProducer{write: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	cfg := testConfig()
+	cfg.ProduceTimeout = 20 * time.Millisecond
+	w := New(p, cfg, nil)
+
+	// у клиента дедлайна нет, запись всё равно ограничена
+	err := w.WriteMessages(context.Background())
+	require.ErrorIs(t, err, ErrProduceTimeout)
+
+	// отмена клиентом не считается сбоем Kafka
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, w.WriteMessages(ctx), context.Canceled)
+	require.Equal(t, 1, w.breaker.failures)
+}
+
+func TestBreakerTripsAndProbesForRecovery(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	p := &fakeProducer{write: func(context.Context) error {
+		if failing.Load() {
+			return errors.New("leader not available")
+		}
+		return nil
+	}}
+	w := New(p, testConfig(), nil)
+	now := time.Now()
+	w.breaker.now = func() time.Time { return now }
+
+	for range 3 {
+		require.Error(t, w.WriteMessages(context.Background()))
+	}
+	require.Equal(t, Open, w.State())
+	require.ErrorIs(t, w.WriteMessages(context.Background()), ErrCircuitOpen)
+	require.EqualValues(t, 3, p.calls.Load(), "open circuit does not call Kafka")
+	_, err := w.Check().Probe(context.Background())
+	require.ErrorIs(t, err, ErrCircuitOpen)
+
+	// после паузы пробная запись снова неудачна — новая пауза
+	now = now.Add(time.Minute)
+	require.Equal(t, HalfOpen, w.State())
+	_, err = w.Check().Probe(context.Background())
+	require.NoError(t, err, "half-open service is ready again so that probes can arrive")
+	require.Error(t, w.WriteMessages(context.Background()))
+	require.Equal(t, Open, w.State())
+
+	// Kafka восстановилась: проба замыкает предохранитель
+	now = now.Add(time.Minute)
+	failing.Store(false)
+	require.NoError(t, w.WriteMessages(context.Background()))
+	require.Equal(t, Closed, w.State())
+	require.NoError(t, w.WriteMessages(context.Background()))
+}
+
+func TestAdaptiveLimitShrinksOnSlowWritesAndRecovers(t *testing.T) {
+	a := newAdaptive(2, 20, 100*time.Millisecond)
+	for range 30 {
+		require.True(t, a.acquire())
+		a.release(time.Second, false)
+	}
+	_, limit := a.snapshot()
+	require.Equal(t, 2, limit)
+
+	// быстрые записи под нагрузкой снова поднимают предел
+	for range 200 {
+		for a.acquire() {
+		}
+		inFlight, _ := a.snapshot()
+		for range inFlight {
+			a.release(time.Millisecond, false)
+		}
+	}
+	_, limit = a.snapshot()
+	require.Equal(t, 20, limit)
+}
@@ -1,41 +1,274 @@
+// Package config описывает конфигурацию сервисов и загружает её из файла, переменных окружения и флагов.
+//
+// Приоритет источников (каждый следующий перекрывает предыдущий):
+//
+//  1. значения по умолчанию из тега default;
+//  2. YAML-файл из --config или CONFIG_FILE;
+//  3. переменные окружения из тега env (пустая переменная считается незаданной);
+//  4. флаги командной строки: путь поля в файле через точку, например --kafka.brokers.
+//
+// Поля с тегом reload:"true" меняются на ходу: Reloader перечитывает конфигурацию
+// по SIGHUP и при изменении файла. Изменение остальных полей требует перезапуска.
+//
+// Каждый сервис читает только свою структуру, поэтому, например, кешу не нужны
+// KAFKA_TOPIC и WORKER_GROUP.
 package config
 
 import (
-	"log"
-	"os"
+	"fmt"
+	"time"
 )
 
-// Config хранит все переменные окружения проекта
-type Config struct {
-	KafkaBrokers     string
-	KafkaTopic       string
-	OrderServiceAddr string
-	RedisAddr        string
-	CacheServiceAddr string
-	DlqTopic         string
-	WorkerGroup      string
-}
-
-// Load ищет .env вверх от файла и загружает конфигурацию
-func Load() *Config {
-	cfg := &Config{}
-	cfg.KafkaBrokers = os.Getenv("KAFKA_BROKERS")
-	cfg.KafkaTopic = os.Getenv("KAFKA_TOPIC")
-	cfg.OrderServiceAddr = os.Getenv("ORDER_SERVICE_ADDR")
-	cfg.RedisAddr = os.Getenv("REDIS_ADDR")
-	cfg.CacheServiceAddr = os.Getenv("CACHE_SERVICE_ADDR")
-	cfg.DlqTopic = os.Getenv("DLQ_TOPIC")
-	cfg.WorkerGroup = os.Getenv("WORKER_GROUP")
-
-	if cfg.KafkaBrokers == "" || cfg.KafkaTopic == "" || cfg.OrderServiceAddr == "" ||
-		cfg.RedisAddr == "" || cfg.CacheServiceAddr == "" || cfg.DlqTopic == "" || cfg.WorkerGroup == "" {
-		log.Fatal("Не все переменные окружения для БД установлены")
+// Log — формат и уровень логов
+type Log struct {
+	// Format — json или text, Level — debug, info, warn или error
+	Format string `yaml:"format" env:"LOG_FORMAT" default:"json" oneof:"json,text"`
+	Level  string `yaml:"level" env:"LOG_LEVEL" default:"info" oneof:"debug,info,warn,error" reload:"true"`
+}
+
+// Trace — экспорт спанов
+type Trace struct {
+	// Exporter — куда писать спаны: none, stdout или file; File — путь для file
+	Exporter string `yaml:"exporter" env:"TRACE_EXPORTER" default:"none" oneof:"none,stdout,file"`
+	File     string `yaml:"file" env:"TRACE_FILE"`
+}
+
+// Health — проверки зависимостей для health/readiness
+type Health struct {
+	// Interval — период проверки, Timeout — предел одной проверки
+	Interval time.Duration `yaml:"interval" env:"HEALTH_INTERVAL" default:"5s" min:"1ms"`
+	Timeout  time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT" default:"2s" min:"1ms"`
+}
+
+// Ops — общие для всех сервисов параметры: служебный HTTP, логи, трассировка, остановка
+type Ops struct {
+	// AdminAddr — адрес служебного HTTP-сервера (/metrics); пусто — сервер выключен
+	AdminAddr string `yaml:"admin_addr" env:"ADMIN_ADDR" default:":9090"`
+	// ShutdownTimeout — сколько ждать завершения активных запросов и текущего сообщения при остановке
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"10s" min:"0s"`
+	// ReloadInterval — как часто проверять файл конфигурации на изменения; 0 — только по SIGHUP
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"CONFIG_RELOAD_INTERVAL" default:"5s" min:"0s"`
+
+	Log    Log    `yaml:"log"`
+	Trace  Trace  `yaml:"trace"`
+	Health Health `yaml:"health"`
+}
+
+// Redis — подключение к Redis
+type Redis struct {
+	Addr     string `yaml:"addr" env:"REDIS_ADDR" required:"true"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+}
+
+// TLS — сертификаты gRPC-сервера в PEM; пустой cert_file — без TLS.
+// Содержимое файлов перечитывается при ротации, смена путей требует перезапуска.
+type TLS struct {
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE"`
+	// ClientCAFile включает mTLS: клиент обязан предъявить сертификат, подписанный этим CA
+	ClientCAFile string `yaml:"client_ca_file" env:"TLS_CLIENT_CA_FILE"`
+	// ReloadInterval — как часто проверять файлы сертификатов на ротацию
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"TLS_RELOAD_INTERVAL" default:"30s" min:"1s"`
+}
+
+// Enabled сообщает, включён ли TLS
+func (t *TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// problems проверяет, что файлы TLS заданы согласованно
+func (t *TLS) problems() []string {
+	var problems []string
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		problems = append(problems, "tls.cert_file and tls.key_file must be set together")
 	}
+	if t.ClientCAFile != "" && t.CertFile == "" {
+		problems = append(problems, "tls.client_ca_file requires tls.cert_file and tls.key_file")
+	}
+	return problems
+}
 
-	return cfg
+// Auth — проверка вызывающих gRPC-сервера: API-ключи и JWT, правила доступа по методам.
+// Правила меняются на ходу, источники ключей — только при перезапуске.
+type Auth struct {
+	Enabled bool `yaml:"enabled" env:"AUTH_ENABLED" default:"false"`
+	// APIKeysFile — YAML со списком ключей (name, sha256, tenant, scopes)
+	APIKeysFile string `yaml:"api_keys_file" env:"AUTH_API_KEYS_FILE"`
+	JWT         struct {
+		// JWKSFile — локальный JWKS с ключами RS256 и HS256; незнакомый kid перечитывает файл
+		JWKSFile string `yaml:"jwks_file" env:"AUTH_JWKS_FILE"`
+		// HS256Secret — общий секрет для токенов HS256 без kid
+		HS256Secret string `yaml:"hs256_secret" env:"AUTH_JWT_HS256_SECRET" secret:"true"`
+		Issuer      string `yaml:"issuer" env:"AUTH_JWT_ISSUER"`
+		Audience    string `yaml:"audience" env:"AUTH_JWT_AUDIENCE"`
+		// TenantClaim — claim с покупателем, чьи заказы доступны владельцу токена
+		TenantClaim string        `yaml:"tenant_claim" env:"AUTH_JWT_TENANT_CLAIM" default:"tenant"`
+		Leeway      time.Duration `yaml:"leeway" env:"AUTH_JWT_LEEWAY" default:"30s" min:"0s"`
+	} `yaml:"jwt"`
+	// Rules — scope через пробел, нужные для метода: полное имя, сервис/* или *
+	Rules map[string]string `yaml:"rules" env:"AUTH_RULES" default:"/order.OrderService/*:orders:write,/order.CacheService/*:orders:read" reload:"true"`
+	// PublicMethods вызываются без проверки
+	PublicMethods []string `yaml:"public_methods" env:"AUTH_PUBLIC_METHODS" default:"/grpc.health.v1.Health/Check,/grpc.health.v1.Health/Watch"`
+	// AdminScope даёт доступ ко всем методам и заказам всех покупателей
+	AdminScope string `yaml:"admin_scope" env:"AUTH_ADMIN_SCOPE" default:"orders:admin"`
 }
 
-// loadConfig загружает конфигурацию из файла, определяя путь до текущего файла
-func LoadConfig() Config {
-	return *Load()
+// problems проверяет, что включённой проверке есть чем проверять
+func (a *Auth) problems() []string {
+	if a.Enabled && a.APIKeysFile == "" && a.JWT.JWKSFile == "" && a.JWT.HS256Secret == "" {
+		return []string{"auth.enabled requires auth.api_keys_file, auth.jwt.jwks_file or auth.jwt.hs256_secret"}
+	}
+	return nil
+}
+
+// RateLimit — корзина токенов на каждого клиента (API-ключ, sub токена, сертификат или IP):
+// Rate заказов за Per в среднем и не больше Burst подряд. Пакет расходует по токену на заказ.
+type RateLimit struct {
+	Enabled bool          `yaml:"enabled" env:"RATE_LIMIT_ENABLED" default:"false"`
+	Rate    int           `yaml:"rate" env:"RATE_LIMIT_RATE" default:"100" min:"1" reload:"true"`
+	Per     time.Duration `yaml:"per" env:"RATE_LIMIT_PER" default:"1s" min:"1ms" reload:"true"`
+	Burst   int           `yaml:"burst" env:"RATE_LIMIT_BURST" default:"200" min:"1" reload:"true"`
+	// Backend — где хранить корзины: local — в памяти реплики, redis — общие для всех реплик
+	Backend string `yaml:"backend" env:"RATE_LIMIT_BACKEND" default:"local" oneof:"local,redis"`
+}
+
+// Backpressure — пределы записи заказов в Kafka: адаптивный предел одновременных записей,
+// таймаут одной записи и предохранитель после серии сбоев. Меняются на ходу.
+type Backpressure struct {
+	// ProduceTimeout ограничивает одну запись независимо от дедлайна клиента
+	ProduceTimeout time.Duration `yaml:"produce_timeout" env:"PRODUCE_TIMEOUT" default:"5s" min:"10ms" reload:"true"`
+	MinInFlight    int           `yaml:"min_in_flight" env:"PRODUCE_MIN_IN_FLIGHT" default:"4" min:"1" reload:"true"`
+	MaxInFlight    int           `yaml:"max_in_flight" env:"PRODUCE_MAX_IN_FLIGHT" default:"256" min:"1" reload:"true"`
+	// TargetLatency — запись дольше сокращает предел одновременных записей
+	TargetLatency time.Duration `yaml:"target_latency" env:"PRODUCE_TARGET_LATENCY" default:"500ms" min:"1ms" reload:"true"`
+	// BreakerFailures — сколько неудачных записей подряд размыкают предохранитель
+	BreakerFailures int `yaml:"breaker_failures" env:"PRODUCE_BREAKER_FAILURES" default:"5" min:"1" reload:"true"`
+	// BreakerCooldown — сколько отклонять заказы до пробной записи
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown" env:"PRODUCE_BREAKER_COOLDOWN" default:"10s" min:"100ms" reload:"true"`
+}
+
+// Outbox — приём заказов через локальный файл: CreateOrder отвечает после записи на диск,
+// а публикация в Kafka идёт в фоне. Требует постоянного тома под path у каждой реплики.
+type Outbox struct {
+	Enabled bool   `yaml:"enabled" env:"OUTBOX_ENABLED" default:"false"`
+	Path    string `yaml:"path" env:"OUTBOX_PATH" default:"data/outbox.db"`
+	// BatchSize — сколько заказов публиковать одним WriteMessages
+	BatchSize int `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE" default:"100" min:"1"`
+	// RetryInterval — пауза после ошибки Kafka
+	RetryInterval time.Duration `yaml:"retry_interval" env:"OUTBOX_RETRY_INTERVAL" default:"1s" min:"10ms"`
+	// MaxBacklog — сколько неопубликованных заказов хранить; сверх него CreateOrder отвечает Unavailable
+	MaxBacklog int `yaml:"max_backlog" env:"OUTBOX_MAX_BACKLOG" default:"100000" min:"1"`
+}
+
+// Partitioning — как заказ попадает в партицию Kafka
+type Partitioning struct {
+	// PartitionKey — по какому полю заказа строится ключ Kafka (order_id, customer_id)
+	PartitionKey string `yaml:"partition_key" env:"ORDER_PARTITION_KEY" default:"order_id" oneof:"order_id,customer_id"`
+	// Partitioner — алгоритм выбора партиции по ключу (hash, murmur2, crc32)
+	Partitioner string `yaml:"partitioner" env:"KAFKA_PARTITIONER" default:"hash" oneof:"hash,murmur2,crc32"`
+}
+
+// Orders — ограничения на поля заказа: проверяются приёмником и воркером, меняются на ходу
+type Orders struct {
+	IDPattern     string   `yaml:"id_pattern" env:"ORDER_ID_PATTERN" default:"^[A-Za-z0-9][A-Za-z0-9._:-]*$" reload:"true"`
+	IDMaxLen      int      `yaml:"id_max_len" env:"ORDER_ID_MAX_LEN" default:"64" min:"1" reload:"true"`
+	ItemAllowlist []string `yaml:"item_allowlist" env:"ORDER_ITEM_ALLOWLIST" reload:"true"`
+	ItemMaxLen    int      `yaml:"item_max_len" env:"ORDER_ITEM_MAX_LEN" default:"128" min:"1" reload:"true"`
+	// PriceMin и PriceMax — диапазон цены за единицу в минимальных единицах валюты (центах)
+	PriceMin        int64  `yaml:"price_min" env:"ORDER_PRICE_MIN" default:"1" min:"0" reload:"true"`
+	PriceMax        int64  `yaml:"price_max" env:"ORDER_PRICE_MAX" default:"1000000000" min:"1" reload:"true"`
+	MaxLineItems    int    `yaml:"max_line_items" env:"ORDER_MAX_LINE_ITEMS" default:"100" min:"1" reload:"true"`
+	MaxQuantity     int32  `yaml:"max_quantity" env:"ORDER_MAX_QUANTITY" default:"10000" min:"1" reload:"true"`
+	DefaultCurrency string `yaml:"default_currency" env:"ORDER_DEFAULT_CURRENCY" default:"USD" reload:"true"`
+}
+
+// Receiver — конфигурация приёмника заказов (orderreceiver)
+type Receiver struct {
+	// Addr — адрес gRPC OrderService
+	Addr  string `yaml:"addr" env:"ORDER_SERVICE_ADDR" required:"true"`
+	TLS   TLS    `yaml:"tls"`
+	Auth  Auth   `yaml:"auth"`
+	Kafka struct {
+		Brokers []string `yaml:"brokers" env:"KAFKA_BROKERS" required:"true"`
+		Topic   string   `yaml:"topic" env:"KAFKA_TOPIC" required:"true"`
+	} `yaml:"kafka"`
+	Redis Redis `yaml:"redis"`
+
+	// IdempotencyWindow — сколько помнить принятые заказы для отсева повторов
+	IdempotencyWindow time.Duration `yaml:"idempotency_window" env:"IDEMPOTENCY_WINDOW" default:"24h" min:"1s" reload:"true"`
+	RateLimit         RateLimit     `yaml:"rate_limit"`
+	Backpressure      Backpressure  `yaml:"backpressure"`
+	Outbox            Outbox        `yaml:"outbox"`
+	Partitioning      `yaml:",inline"`
+	Orders            Orders `yaml:"orders"`
+	Ops               `yaml:",inline"`
+}
+
+// Cache — конфигурация сервиса чтения результатов (ordercache)
+type Cache struct {
+	// Addr — адрес gRPC CacheService
+	Addr  string `yaml:"addr" env:"CACHE_SERVICE_ADDR" required:"true"`
+	TLS   TLS    `yaml:"tls"`
+	Auth  Auth   `yaml:"auth"`
+	Redis Redis  `yaml:"redis"`
+	Ops   `yaml:",inline"`
+}
+
+// Worker — конфигурация воркера (orderprocessor)
+type Worker struct {
+	Kafka struct {
+		Brokers  []string `yaml:"brokers" env:"KAFKA_BROKERS" required:"true"`
+		Topic    string   `yaml:"topic" env:"KAFKA_TOPIC" required:"true"`
+		DLQTopic string   `yaml:"dlq_topic" env:"DLQ_TOPIC" required:"true"`
+		Group    string   `yaml:"group" env:"WORKER_GROUP" required:"true"`
+	} `yaml:"kafka"`
+	Redis Redis `yaml:"redis"`
+
+	// Concurrency — число параллельных обработчиков заказов, не больше MaxConcurrency
+	Concurrency int `yaml:"concurrency" env:"WORKER_CONCURRENCY" default:"4" min:"1" reload:"true"`
+	// MaxConcurrency — верхняя граница Concurrency при изменении на ходу
+	MaxConcurrency int `yaml:"max_concurrency" env:"WORKER_MAX_CONCURRENCY" default:"64" min:"1"`
+	// MaxRetries — сколько раз повторять обработку заказа перед отправкой в DLQ
+	MaxRetries int `yaml:"max_retries" env:"MAX_RETRIES" default:"3" min:"0" reload:"true"`
+	// RetryBackoff — задержки перед повторными попытками, по retry-топику на каждую
+	RetryBackoff []time.Duration `yaml:"retry_backoff" env:"RETRY_BACKOFF" default:"1s,30s,5m"`
+	// IdempotencyWindow — сколько помнить обработанные заказы для отсева повторов
+	IdempotencyWindow time.Duration `yaml:"idempotency_window" env:"IDEMPOTENCY_WINDOW" default:"24h" min:"1s" reload:"true"`
+	Partitioning      `yaml:",inline"`
+
+	// Stages — стадии обработки заказа по порядку; пусто — стадии по умолчанию
+	Stages []string `yaml:"stages" env:"WORKER_STAGES" reload:"true"`
+	// TaxRates — ставки налога по стране доставки в базисных пунктах (DE:1900 = 19%)
+	TaxRates map[string]int64 `yaml:"tax_rates" env:"TAX_RATES" reload:"true"`
+	Fraud    struct {
+		// MaxTotal — максимальная сумма заказа в минимальных единицах валюты, 0 — без ограничения
+		MaxTotal int64 `yaml:"max_total" env:"FRAUD_MAX_TOTAL" default:"0" min:"0" reload:"true"`
+		// BlockedCustomers — покупатели, заказы которых отклоняются
+		BlockedCustomers []string `yaml:"blocked_customers" env:"FRAUD_BLOCKED_CUSTOMERS" reload:"true"`
+	} `yaml:"fraud"`
+	Orders Orders `yaml:"orders"`
+	Ops    `yaml:",inline"`
+}
+
+// check сверяет поля приёмника между собой
+func (r *Receiver) check() []string {
+	problems := append(r.TLS.problems(), r.Auth.problems()...)
+	if r.Backpressure.MinInFlight > r.Backpressure.MaxInFlight {
+		problems = append(problems, fmt.Sprintf("backpressure.min_in_flight: %d is above backpressure.max_in_flight %d",
+			r.Backpressure.MinInFlight, r.Backpressure.MaxInFlight))
+	}
+	return problems
+}
+
+// check сверяет поля кеша между собой
+func (c *Cache) check() []string {
+	return append(c.TLS.problems(), c.Auth.problems()...)
+}
+
+// check сверяет поля воркера между собой
+func (w *Worker) check() []string {
+	if w.Concurrency > w.MaxConcurrency {
+		return []string{fmt.Sprintf("concurrency: %d is above max_concurrency %d", w.Concurrency, w.MaxConcurrency)}
+	}
+	return nil
 }
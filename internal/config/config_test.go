@@ -0,0 +1,171 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// envMap — окружение теста вместо os.LookupEnv
+func envMap(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+	return path
+}
+
+func TestPrecedenceDefaultsFileEnvFlags(t *testing.T) {
+	path := writeFile(t, `
+kafka:
+  brokers: [a:9092, b:9092]
+  topic: from-file
+  dlq_topic: dlq
+  group: from-file
+redis:
+  addr: file:6379
+concurrency: 8
+retry_backoff: [2s, 1m]
+tax_rates: {DE: 1900, FR: 2000}
+health:
+  interval: 7s
+`)
+	var cfg Worker
+	_, err := Load(&cfg, []string{"--config", path, "--kafka.group=from-flag", "--log.level=debug"},
+		envMap(map[string]string{"KAFKA_TOPIC": "from-env", "WORKER_GROUP": "from-env", "MAX_RETRIES": ""}))
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"a:9092", "b:9092"}, cfg.Kafka.Brokers)
+	require.Equal(t, "from-env", cfg.Kafka.Topic, "env overrides file")
+	require.Equal(t, "from-flag", cfg.Kafka.Group, "flag overrides env and file")
+	require.Equal(t, 8, cfg.Concurrency)
+	require.Equal(t, 3, cfg.MaxRetries, "empty env keeps the default")
+	require.Equal(t, []time.Duration{2 * time.Second, time.Minute}, cfg.RetryBackoff)
+	require.Equal(t, map[string]int64{"DE": 1900, "FR": 2000}, cfg.TaxRates)
+	require.Equal(t, 7*time.Second, cfg.Health.Interval)
+	require.Equal(t, 2*time.Second, cfg.Health.Timeout)
+	require.Equal(t, "debug", cfg.Log.Level)
+	require.Equal(t, "json", cfg.Log.Format)
+}
+
+func TestFileRejectsUnknownKeys(t *testing.T) {
+	path := writeFile(t, "addr: \":50052\"\nredis:\n  adr: redis:6379\nkafka:\n  topic: orders\n")
+	var cfg Cache
+	_, err := Load(&cfg, []string{"--config", path}, envMap(nil))
+	var cfgErr *Error
+	require.ErrorAs(t, err, &cfgErr)
+	require.Equal(t, []string{path + ": unknown keys kafka.topic, redis.adr"}, cfgErr.Problems)
+}
+
+func TestCacheNeedsOnlyItsOwnFields(t *testing.T) {
+	var cfg Cache
+	_, err := Load(&cfg, nil, envMap(map[string]string{"CACHE_SERVICE_ADDR": ":50052", "REDIS_ADDR": "redis:6379"}))
+	require.NoError(t, err)
+	require.Equal(t, ":9090", cfg.AdminAddr)
+}
+
+func TestValidationNamesEveryBadField(t *testing.T) {
+	var cfg Worker
+	_, err := Load(&cfg, []string{"--concurrency=0", "--log.format=xml"}, envMap(map[string]string{
+		"KAFKA_BROKERS":  "kafka:9092",
+		"KAFKA_TOPIC":    "orders",
+		"REDIS_ADDR":     "redis:6379",
+		"HEALTH_TIMEOUT": "soon",
+		"TAX_RATES":      "DE=19",
+	}))
+	var cfgErr *Error
+	require.ErrorAs(t, err, &cfgErr)
+	require.Equal(t, []string{
+		`tax_rates: invalid value "DE=19" from env TAX_RATES: want KEY:non-negative-number pairs`,
+		`health.timeout: invalid value "soon" from env HEALTH_TIMEOUT: want duration like 10s`,
+		"kafka.dlq_topic is required (config file, env DLQ_TOPIC or --kafka.dlq_topic)",
+		"kafka.group is required (config file, env WORKER_GROUP or --kafka.group)",
+		"concurrency: 0 is below minimum 1",
+		`log.format: "xml" is not one of json, text`,
+	}, cfgErr.Problems)
+}
+
+func TestPrintRedactsSecretsAndRoundTrips(t *testing.T) {
+	var cfg Cache
+	opts, err := Load(&cfg, []string{"--print-config"}, envMap(map[string]string{
+		"CACHE_SERVICE_ADDR": ":50052",
+		"REDIS_ADDR":         "redis:6379",
+		"REDIS_PASSWORD":     "hunter2",
+	}))
+	require.NoError(t, err)
+	require.True(t, opts.PrintConfig)
+	require.Equal(t, "hunter2", cfg.Redis.Password)
+
+	var out bytes.Buffer
+	require.NoError(t, Print(&out, &cfg))
+	require.NotContains(t, out.String(), "hunter2")
+	require.Contains(t, out.String(), "password: '***'")
+
+	// напечатанная конфигурация годится как файл --config
+	var again Cache
+	_, err = Load(&again, []string{"--config", writeFile(t, out.String())}, envMap(nil))
+	require.NoError(t, err)
+	again.Redis.Password = cfg.Redis.Password
+	require.Equal(t, cfg, again)
+}
+
+func TestLoadRejectsUnknownFlag(t *testing.T) {
+	var cfg Cache
+	_, err := Load(&cfg, []string{"--kafka.topic=orders"}, envMap(nil))
+	require.Error(t, err)
+	var cfgErr *Error
+	require.False(t, errors.As(err, &cfgErr))
+}
+
+func TestTLSFilesMustBeConsistent(t *testing.T) {
+	var cfg Cache
+	_, err := Load(&cfg, []string{"--tls.client_ca_file=ca.pem", "--tls.key_file=key.pem"}, envMap(map[string]string{
+		"CACHE_SERVICE_ADDR": ":50052",
+		"REDIS_ADDR":         "redis:6379",
+	}))
+	var cfgErr *Error
+	require.ErrorAs(t, err, &cfgErr)
+	require.Equal(t, []string{
+		"tls.cert_file and tls.key_file must be set together",
+		"tls.client_ca_file requires tls.cert_file and tls.key_file",
+	}, cfgErr.Problems)
+}
+
+func TestAuthRulesAndSources(t *testing.T) {
+	env := map[string]string{"CACHE_SERVICE_ADDR": ":50052", "REDIS_ADDR": "redis:6379"}
+	var cfg Cache
+	_, err := Load(&cfg, []string{"--config", writeFile(t, `
+auth:
+  enabled: true
+  jwt:
+    jwks_file: /etc/orders/jwks.json
+  rules:
+    /order.CacheService/GetOrderResult: orders:read orders:write
+    "*": orders:admin
+`)}, envMap(env))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"/order.CacheService/GetOrderResult": "orders:read orders:write",
+		"*":                                  "orders:admin",
+	}, cfg.Auth.Rules)
+
+	env["AUTH_RULES"] = "/order.OrderService/CreateOrder:orders:write"
+	env["AUTH_ENABLED"] = "true"
+	var noSource Cache
+	_, err = Load(&noSource, nil, envMap(env))
+	var cfgErr *Error
+	require.ErrorAs(t, err, &cfgErr)
+	require.Equal(t, []string{"auth.enabled requires auth.api_keys_file, auth.jwt.jwks_file or auth.jwt.hs256_secret"}, cfgErr.Problems)
+	require.Equal(t, map[string]string{"/order.OrderService/CreateOrder": "orders:write"}, noSource.Auth.Rules)
+}
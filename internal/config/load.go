@@ -0,0 +1,489 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted заменяет значения секретных полей при печати
+const redacted = "***"
+
+// Error перечисляет все найденные ошибки конфигурации, по одной на поле
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+// Options — служебные флаги загрузчика
+type Options struct {
+	// File — путь к YAML-файлу из --config или CONFIG_FILE
+	File string
+	// PrintConfig — показать итоговую конфигурацию и выйти
+	PrintConfig bool
+}
+
+// checker — структура сервиса с проверками, связывающими несколько полей
+type checker interface {
+	check() []string
+}
+
+// field — одно поле конфигурации со своими источниками
+type field struct {
+	path     string // путь в файле через точку, он же имя флага
+	env      string
+	def      *string
+	required bool
+	secret   bool
+	reload   bool
+	oneof    []string
+	min      string
+	value    reflect.Value
+}
+
+// source — откуда взято сырое значение, для сообщений об ошибках
+func (f field) source(from string) string {
+	switch from {
+	case "env":
+		return "env " + f.env
+	case "flag":
+		return "flag --" + f.path
+	default:
+		return from
+	}
+}
+
+// Load заполняет dst — указатель на структуру сервиса — из значений по умолчанию,
+// файла, окружения и флагов args (без имени программы) в этом порядке.
+// Ошибки всех полей собираются в один *Error.
+func Load(dst any, args []string, lookupEnv func(string) (string, bool)) (Options, error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return Options{}, fmt.Errorf("config: want pointer to struct, got %T", dst)
+	}
+	fields := collect(rv.Elem(), "")
+	env := func(key string) string {
+		if key == "" {
+			return ""
+		}
+		v, _ := lookupEnv(key)
+		return v
+	}
+
+	// флаги разбираем первыми: от них зависит путь к файлу, применяются они последними
+	var opts Options
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.File, "config", env("CONFIG_FILE"), "YAML config file (env CONFIG_FILE)")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective config with secrets redacted and exit")
+	flagValues := map[string]string{}
+	for _, f := range fields {
+		fs.Func(f.path, "env "+f.env, func(s string) error {
+			flagValues[f.path] = s
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("config: unexpected arguments %q", fs.Args())
+	}
+
+	var problems []string
+	apply := func(f field, raw, from string) {
+		if err := set(f.value, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid value %q from %s: %v", f.path, raw, f.source(from), err))
+		}
+	}
+
+	for _, f := range fields {
+		if f.def != nil {
+			apply(f, *f.def, "default")
+		}
+	}
+	if opts.File != "" {
+		values, err := readFile(opts.File, fields)
+		if err != nil {
+			return opts, err
+		}
+		for _, f := range fields {
+			if raw, ok := values[f.path]; ok {
+				apply(f, raw, "file "+opts.File)
+			}
+		}
+	}
+	for _, f := range fields {
+		if raw := env(f.env); raw != "" {
+			apply(f, raw, "env")
+		}
+	}
+	for _, f := range fields {
+		if raw, ok := flagValues[f.path]; ok {
+			apply(f, raw, "flag")
+		}
+	}
+
+	problems = append(problems, validate(fields)...)
+	if c, ok := dst.(checker); ok && len(problems) == 0 {
+		problems = append(problems, c.check()...)
+	}
+	if len(problems) > 0 {
+		return opts, &Error{Problems: problems}
+	}
+	return opts, nil
+}
+
+// MustLoad загружает конфигурацию сервиса из os.Args и окружения.
+// С --print-config печатает итоговую конфигурацию и завершает процесс;
+// при ошибках перечисляет их в stderr и завершает процесс с кодом 2.
+func MustLoad(service string, dst any) {
+	opts, err := Load(dst, os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		Usage(os.Stderr, service, dst)
+		os.Exit(0)
+	}
+	var cfgErr *Error
+	if errors.As(err, &cfgErr) {
+		fmt.Fprintf(os.Stderr, "%s: invalid config:\n", service)
+		for _, p := range cfgErr.Problems {
+			fmt.Fprintf(os.Stderr, "  - %s\n", p)
+		}
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", service, err)
+		os.Exit(2)
+	}
+	if opts.PrintConfig {
+		if err := Print(os.Stdout, dst); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", service, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+}
+
+// Usage печатает флаги сервиса вместе с переменными окружения и значениями по умолчанию
+func Usage(w io.Writer, service string, cfg any) {
+	fmt.Fprintf(w, "usage: %s [--config file.yaml] [--print-config] [--<path>=value ...]\n\n", service)
+	fmt.Fprintln(w, "precedence: defaults < config file (--config, CONFIG_FILE) < environment < flags")
+	fmt.Fprintln(w)
+	for _, f := range collect(reflect.ValueOf(cfg).Elem(), "") {
+		line := fmt.Sprintf("  --%s (env %s)", f.path, f.env)
+		if f.required {
+			line += " required"
+		}
+		if f.def != nil && *f.def != "" {
+			line += fmt.Sprintf(" default %q", *f.def)
+		}
+		if len(f.oneof) > 0 {
+			line += " one of " + strings.Join(f.oneof, "|")
+		}
+		if f.reload {
+			line += " (reloadable)"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// collect обходит структуру и возвращает её поля-значения; вложенные структуры
+// дают префикс пути, а поля с тегом yaml:",inline" — нет
+func collect(v reflect.Value, prefix string) []field {
+	var fields []field
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, opt, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		fv := v.Field(i)
+		if sf.Type.Kind() == reflect.Struct {
+			if opt == "inline" {
+				fields = append(fields, collect(fv, prefix)...)
+			} else {
+				fields = append(fields, collect(fv, prefix+name+".")...)
+			}
+			continue
+		}
+		f := field{
+			path:     prefix + name,
+			env:      sf.Tag.Get("env"),
+			required: sf.Tag.Get("required") == "true",
+			secret:   sf.Tag.Get("secret") == "true",
+			reload:   sf.Tag.Get("reload") == "true",
+			min:      sf.Tag.Get("min"),
+			value:    fv,
+		}
+		if def, ok := sf.Tag.Lookup("default"); ok {
+			f.def = &def
+		}
+		if oneof := sf.Tag.Get("oneof"); oneof != "" {
+			f.oneof = strings.Split(oneof, ",")
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// set разбирает сырое значение в поле: строки, числа, bool, длительности,
+// списки через запятую и пары КЛЮЧ:число или КЛЮЧ:строка через запятую
+func set(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return errors.New("want duration like 10s")
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return errors.New("want true or false")
+		}
+		v.SetBool(b)
+	case v.CanInt():
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("want %d-bit integer", v.Type().Bits())
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Slice && v.Type().Elem() == durationType:
+		var list []time.Duration
+		for _, part := range splitList(raw) {
+			d, err := time.ParseDuration(part)
+			if err != nil || d <= 0 {
+				return errors.New("want positive durations like 1s,30s,5m")
+			}
+			list = append(list, d)
+		}
+		v.Set(reflect.ValueOf(list))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		v.Set(reflect.ValueOf(splitList(raw)))
+	case v.Kind() == reflect.Map && v.Type().Elem().Kind() == reflect.Int64:
+		m := map[string]int64{}
+		for _, part := range splitList(raw) {
+			k, num, ok := strings.Cut(part, ":")
+			n, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+			if !ok || err != nil || n < 0 {
+				return errors.New("want KEY:non-negative-number pairs")
+			}
+			m[strings.TrimSpace(k)] = n
+		}
+		v.Set(reflect.ValueOf(m))
+	case v.Kind() == reflect.Map && v.Type().Elem().Kind() == reflect.String:
+		m := map[string]string{}
+		for _, part := range splitList(raw) {
+			// значение может само содержать двоеточие (orders:write), ключ — нет
+			k, val, ok := strings.Cut(part, ":")
+			if !ok || strings.TrimSpace(k) == "" {
+				return errors.New("want KEY:value pairs")
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// splitList делит строку по запятым; пустые элементы пропускаются
+func splitList(raw string) []string {
+	var list []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// validate проверяет обязательные поля, допустимые значения и нижние границы
+func validate(fields []field) []string {
+	var problems []string
+	for _, f := range fields {
+		switch {
+		case f.required && f.value.IsZero():
+			problems = append(problems, fmt.Sprintf("%s is required (config file, env %s or --%s)", f.path, f.env, f.path))
+		case len(f.oneof) > 0 && !slices.Contains(f.oneof, f.value.String()):
+			problems = append(problems, fmt.Sprintf("%s: %q is not one of %s", f.path, f.value.String(), strings.Join(f.oneof, ", ")))
+		case f.min != "":
+			bound := reflect.New(f.value.Type()).Elem()
+			if err := set(bound, f.min); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: bad min tag: %v", f.path, err))
+			} else if f.value.Int() < bound.Int() {
+				problems = append(problems, fmt.Sprintf("%s: %s is below minimum %s", f.path, format(f.value), f.min))
+			}
+		}
+	}
+	return problems
+}
+
+// readFile читает YAML и раскладывает его в сырые значения по путям полей.
+// Неизвестные ключи — ошибка, чтобы опечатки не проходили молча.
+func readFile(path string, fields []field) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	known := map[string]bool{}
+	for _, f := range fields {
+		known[f.path] = true
+	}
+	values := map[string]string{}
+	var unknown []string
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			p := prefix + k
+			if nested, ok := v.(map[string]any); ok && !known[p] {
+				walk(p+".", nested)
+				continue
+			}
+			if !known[p] {
+				unknown = append(unknown, p)
+				continue
+			}
+			values[p] = scalar(v)
+		}
+	}
+	walk("", doc)
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, &Error{Problems: []string{fmt.Sprintf("%s: unknown keys %s", path, strings.Join(unknown, ", "))}}
+	}
+	return values, nil
+}
+
+// scalar приводит значение из YAML к строке в формате окружения:
+// списки — через запятую, словари — пары КЛЮЧ:значение
+func scalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = scalar(e)
+		}
+		return strings.Join(parts, ",")
+	case map[string]any:
+		parts := make([]string, 0, len(v))
+		for k, e := range v {
+			parts = append(parts, k+":"+scalar(e))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ",")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// format печатает значение поля в том же виде, в каком его принимает set
+func format(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range v.Len() {
+			parts[i] = format(v.Index(i))
+		}
+		return strings.Join(parts, ",")
+	case v.Kind() == reflect.Map:
+		parts := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			parts = append(parts, k.String()+":"+format(v.MapIndex(k)))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// Print пишет конфигурацию в YAML в порядке полей структуры; значения секретов заменяются на ***
+func Print(w io.Writer, cfg any) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range collect(reflect.ValueOf(cfg).Elem(), "") {
+		parent := root
+		keys := strings.Split(f.path, ".")
+		for _, k := range keys[:len(keys)-1] {
+			parent = child(parent, k)
+		}
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: keys[len(keys)-1]}, printNode(f))
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// child возвращает вложенный словарь key, создавая его при необходимости
+func child(parent *yaml.Node, key string) *yaml.Node {
+	for i := 0; i < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == key {
+			return parent.Content[i+1]
+		}
+	}
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, n)
+	return n
+}
+
+// printNode строит YAML-узел значения поля
+func printNode(f field) *yaml.Node {
+	v := f.value
+	switch {
+	case f.secret && !v.IsZero():
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: redacted}
+	case v.Kind() == reflect.Slice:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for i := range v.Len() {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: format(v.Index(i))})
+		}
+		return n
+	case v.Kind() == reflect.Map:
+		n := &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			n.Content = append(n.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: k.String()},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: format(v.MapIndex(k))})
+		}
+		return n
+	case v.Kind() == reflect.String:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v.String()}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: format(v)}
+	}
+}
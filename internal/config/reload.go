@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+)
+
+// Change — изменение одного поля конфигурации
+type Change struct {
+	Path string
+	Old  string
+	New  string
+	// Reload — поле меняется на ходу; иначе изменение требует перезапуска
+	Reload bool
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %q -> %q", c.Path, c.Old, c.New)
+}
+
+// Diff сравнивает две конфигурации одного типа и возвращает изменённые поля
+// в порядке структуры; значения секретов скрыты
+func Diff(old, new any) []Change {
+	before := collect(reflect.ValueOf(old).Elem(), "")
+	after := collect(reflect.ValueOf(new).Elem(), "")
+	var changes []Change
+	for i, f := range before {
+		o, n := format(f.value), format(after[i].value)
+		if o == n {
+			continue
+		}
+		if f.secret {
+			o, n = redacted, redacted
+		}
+		changes = append(changes, Change{Path: f.path, Old: o, New: n, Reload: f.reload})
+	}
+	return changes
+}
+
+// RestartError — перезагрузка отклонена: изменились поля, которые меняются только перезапуском
+type RestartError struct {
+	Changes []Change
+}
+
+func (e *RestartError) Error() string {
+	parts := make([]string, len(e.Changes))
+	for i, c := range e.Changes {
+		parts[i] = c.String()
+	}
+	return "config reload rejected, nothing applied: restart required to change " + strings.Join(parts, ", ")
+}
+
+// Reloader перечитывает конфигурацию сервиса из тех же источников, что и при запуске,
+// по SIGHUP и при изменении файла. Новая конфигурация применяется целиком или никак:
+// если изменилось поле без тега reload:"true" или apply вернул ошибку, остаётся прежняя.
+type Reloader[T any] struct {
+	args      []string
+	lookupEnv func(string) (string, bool)
+	apply     func(cfg *T) error
+
+	mu      sync.Mutex
+	current T
+	// seen — содержимое файла при последней попытке перезагрузки
+	seen []byte
+}
+
+// NewReloader конструктор: cur — уже загруженная конфигурация, args и lookupEnv — источники
+// как у Load. apply получает новую конфигурацию; он должен сначала подготовить всё, что
+// может не получиться, и только потом менять состояние сервиса.
+func NewReloader[T any](cur *T, args []string, lookupEnv func(string) (string, bool), apply func(cfg *T) error) *Reloader[T] {
+	r := &Reloader[T]{args: args, lookupEnv: lookupEnv, apply: apply, current: *cur}
+	if opts, err := Load(new(T), args, lookupEnv); err == nil && opts.File != "" {
+		r.seen, _ = os.ReadFile(opts.File)
+	}
+	return r
+}
+
+// Current возвращает действующую конфигурацию
+func (r *Reloader[T]) Current() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload перечитывает конфигурацию и применяет изменения; возвращает применённые изменения
+func (r *Reloader[T]) Reload() ([]Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var next T
+	opts, err := Load(&next, r.args, r.lookupEnv)
+	if opts.File != "" {
+		r.seen, _ = os.ReadFile(opts.File)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config reload rejected, nothing applied: %w", err)
+	}
+
+	changes := Diff(&r.current, &next)
+	var restart []Change
+	for _, c := range changes {
+		if !c.Reload {
+			restart = append(restart, c)
+		}
+	}
+	if len(restart) > 0 {
+		return nil, &RestartError{Changes: restart}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	if err := r.apply(&next); err != nil {
+		return nil, fmt.Errorf("config reload rejected, nothing applied: %w", err)
+	}
+	r.current = next
+	return changes, nil
+}
+
+// Run перезагружает конфигурацию по SIGHUP и, если задан файл, при изменении его
+// содержимого (проверка каждые interval; 0 — только по SIGHUP) до отмены ctx.
+// Каждое применённое изменение пишется в лог отдельной записью.
+func (r *Reloader[T]) Run(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	opts, _ := Load(new(T), r.args, r.lookupEnv)
+	if opts.File != "" && interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("SIGHUP received, reloading config")
+			r.reloadAndLog()
+		case <-tick:
+			if r.fileChanged(opts.File) {
+				slog.Info("config file changed, reloading", "file", opts.File)
+				r.reloadAndLog()
+			}
+		}
+	}
+}
+
+// fileChanged сообщает, отличается ли файл от прочитанного при последней попытке
+func (r *Reloader[T]) fileChanged(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		slog.Warn("read config file", "file", path, logging.KeyError, err)
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !bytes.Equal(data, r.seen)
+}
+
+func (r *Reloader[T]) reloadAndLog() {
+	changes, err := r.Reload()
+	if err != nil {
+		slog.Error("config reload rejected", logging.KeyError, err)
+		return
+	}
+	if len(changes) == 0 {
+		slog.Info("config reloaded, nothing changed")
+		return
+	}
+	for _, c := range changes {
+		slog.Info("config changed", "field", c.Path, "old", c.Old, "new", c.New)
+	}
+	slog.Info("config reloaded", "changes", len(changes))
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const cacheFile = `
+addr: ":50052"
+redis:
+  addr: redis:6379
+  password: old-secret
+log:
+  level: info
+`
+
+func TestReloaderAppliesOnlyReloadableChanges(t *testing.T) {
+	path := writeFile(t, cacheFile)
+	args := []string{"--config", path}
+	var cfg Cache
+	_, err := Load(&cfg, args, envMap(nil))
+	require.NoError(t, err)
+
+	var applied []string
+	r := NewReloader(&cfg, args, envMap(nil), func(next *Cache) error {
+		if next.Log.Level == "error" {
+			return errors.New("refused by service")
+		}
+		applied = append(applied, next.Log.Level)
+		return nil
+	})
+
+	changes, err := r.Reload()
+	require.NoError(t, err)
+	require.Empty(t, changes)
+	require.Empty(t, applied, "nothing changed, nothing applied")
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+addr: ":50052"
+redis:
+  addr: redis:6379
+  password: old-secret
+log:
+  level: debug
+`), 0o600))
+	changes, err = r.Reload()
+	require.NoError(t, err)
+	require.Equal(t, []Change{{Path: "log.level", Old: "info", New: "debug", Reload: true}}, changes)
+	require.Equal(t, []string{"debug"}, applied)
+	require.Equal(t, "debug", r.Current().Log.Level)
+
+	// адрес и пароль меняются только перезапуском: отклоняется вся перезагрузка
+	require.NoError(t, os.WriteFile(path, []byte(`
+addr: ":50062"
+redis:
+  addr: redis:6379
+  password: new-secret
+log:
+  level: warn
+`), 0o600))
+	_, err = r.Reload()
+	var restart *RestartError
+	require.ErrorAs(t, err, &restart)
+	require.Equal(t, []Change{
+		{Path: "addr", Old: ":50052", New: ":50062"},
+		{Path: "redis.password", Old: redacted, New: redacted},
+	}, restart.Changes)
+	require.NotContains(t, err.Error(), "secret")
+	require.Equal(t, []string{"debug"}, applied)
+	require.Equal(t, "debug", r.Current().Log.Level)
+
+	// отказ сервиса тоже оставляет прежнюю конфигурацию
+	require.NoError(t, os.WriteFile(path, []byte(`
+addr: ":50052"
+redis:
+  addr: redis:6379
+  password: old-secret
+log:
+  level: error
+`), 0o600))
+	_, err = r.Reload()
+	require.ErrorContains(t, err, "refused by service")
+	require.Equal(t, "debug", r.Current().Log.Level)
+}
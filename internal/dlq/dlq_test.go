@@ -0,0 +1,81 @@
+package dlq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	f, err := ParseFilter("order_id=42, reason=unmarshal, since=2h, until=2026-10-01T11:30:00Z", now)
+	require.NoError(t, err)
+	require.Equal(t, "42", f.OrderID)
+	require.Equal(t, ReasonUnmarshal, f.Reason)
+	require.Equal(t, now.Add(-2*time.Hour), f.Since)
+	require.Equal(t, time.Date(2026, 10, 1, 11, 30, 0, 0, time.UTC), f.Until)
+
+	env := Envelope{OrderID: "42", Reason: ReasonUnmarshal, FailedAt: now.Add(-time.Hour)}
+	require.True(t, f.Match(env))
+
+	env.FailedAt = now.Add(-10 * time.Minute)
+	require.False(t, f.Match(env), "after until")
+	env.FailedAt = now.Add(-time.Hour)
+	env.Reason = ReasonRetriesExhausted
+	require.False(t, f.Match(env), "other reason")
+
+	_, err = ParseFilter("customer=1", now)
+	require.Error(t, err)
+	_, err = ParseFilter("since=yesterday", now)
+	require.Error(t, err)
+}
+
+func TestReplayMessageResetsRetries(t *testing.T) {
+	src := kafka.Message{
+		Topic: "orders-retry-5m",
+		Key:   []byte("order-7"),
+		Value: []byte("payload"),
+		Headers: []kafka.Header{
+			{Key: "retries", Value: []byte("3")},
+			{Key: "not-before", Value: []byte("1700000000000")},
+			{Key: "traceparent", Value: []byte("00-abc")},
+		},
+	}
+	env := New(src, ReasonRetriesExhausted, errors.New("boom"), "order-7", 3)
+
+	msg := ReplayMessage(env, "orders")
+	require.Equal(t, "orders", msg.Topic)
+	require.Equal(t, src.Key, msg.Key)
+	require.Equal(t, src.Value, msg.Value)
+	require.Equal(t, []kafka.Header{
+		{Key: "traceparent", Value: []byte("00-abc")},
+		{Key: "retries", Value: []byte("0")},
+		{Key: ReplayHeader, Value: []byte("1")},
+	}, msg.Headers)
+
+	// заголовки исходного конверта не изменились
+	require.Len(t, env.Headers, 3)
+}
+
+func TestCheckOffsetRejectsOffsetsOutsidePartition(t *testing.T) {
+	bounds := []kafka.PartitionOffsets{
+		{Partition: 0, FirstOffset: 5, LastOffset: 10},
+		{Partition: 1, FirstOffset: 0, LastOffset: 0},
+	}
+
+	require.NoError(t, checkOffset(bounds, 0, 5))
+	require.NoError(t, checkOffset(bounds, 0, 9))
+	// за концом партиции чтение ждало бы новое сообщение вечно
+	require.EqualError(t, checkOffset(bounds, 0, 10), "no message at partition 0 offset 10")
+	require.EqualError(t, checkOffset(bounds, 0, 99999), "no message at partition 0 offset 99999")
+	require.EqualError(t, checkOffset(bounds, 0, 4), "no message at partition 0 offset 4")
+	require.EqualError(t, checkOffset(bounds, 1, 0), "no message at partition 1 offset 0")
+	require.EqualError(t, checkOffset(bounds, 7, 0), "no message at partition 7 offset 0")
+
+	bounds[0].Error = errors.New("not leader")
+	require.ErrorContains(t, checkOffset(bounds, 0, 5), "not leader")
+}
@@ -0,0 +1,113 @@
+package dlq
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Reason — категория причины, по которой сообщение попало в DLQ
+type Reason string
+
+const (
+	// ReasonUnmarshal — тело сообщения не удалось разобрать как заказ
+	ReasonUnmarshal Reason = "unmarshal"
+	// ReasonBusinessRule — заказ нарушает бизнес-правило, повтор не поможет
+	ReasonBusinessRule Reason = "business_rule"
+	// ReasonRetriesExhausted — исчерпаны повторные попытки обработки
+	ReasonRetriesExhausted Reason = "retries_exhausted"
+	// ReasonKeyMismatch — ключ сообщения не соответствует заказу в теле
+	ReasonKeyMismatch Reason = "key_mismatch"
+	// ReasonValidation — заказ не прошёл проверку полей
+	ReasonValidation Reason = "validation"
+)
+
+// ReasonHeader дублирует причину в заголовке сообщения DLQ, чтобы её было видно без разбора тела
+const ReasonHeader = "dlq-reason"
+
+// ReplayHeader отмечает сообщение, переотправленное из DLQ вручную: только такое сообщение
+// снова запускает обработку заказа в FAILED или DEAD_LETTERED
+const ReplayHeader = "dlq-replay"
+
+// Header — заголовок исходного сообщения Kafka
+type Header struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Envelope — содержимое сообщения DLQ: исходное сообщение целиком и сведения о сбое
+type Envelope struct {
+	Reason  Reason `json:"reason"`
+	Error   string `json:"error"`
+	OrderID string `json:"order_id,omitempty"`
+	Retries int    `json:"retries"`
+
+	// откуда пришло исходное сообщение
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+
+	Key     []byte   `json:"key,omitempty"`
+	Value   []byte   `json:"value"`
+	Headers []Header `json:"headers,omitempty"`
+
+	// ProducedAt — время записи исходного сообщения, FailedAt — время отправки в DLQ
+	ProducedAt time.Time `json:"produced_at"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// New упаковывает исходное сообщение и причину сбоя в конверт
+func New(msg kafka.Message, reason Reason, cause error, orderID string, retries int) Envelope {
+	env := Envelope{
+		Reason:     reason,
+		OrderID:    orderID,
+		Retries:    retries,
+		Topic:      msg.Topic,
+		Partition:  msg.Partition,
+		Offset:     msg.Offset,
+		Key:        msg.Key,
+		Value:      msg.Value,
+		ProducedAt: msg.Time,
+		FailedAt:   time.Now().UTC(),
+	}
+	if cause != nil {
+		env.Error = cause.Error()
+	}
+	for _, h := range msg.Headers {
+		env.Headers = append(env.Headers, Header{Key: h.Key, Value: h.Value})
+	}
+	return env
+}
+
+// Message сериализует конверт в сообщение DLQ с тем же ключом, что и у исходного
+func (e Envelope) Message() (kafka.Message, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("marshal DLQ envelope: %w", err)
+	}
+	return kafka.Message{
+		Key:     e.Key,
+		Value:   b,
+		Headers: []kafka.Header{{Key: ReasonHeader, Value: []byte(e.Reason)}},
+	}, nil
+}
+
+// Decode разбирает сообщение DLQ обратно в конверт
+func Decode(msg kafka.Message) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(msg.Value, &env); err != nil {
+		return Envelope{}, fmt.Errorf("decode DLQ envelope at offset %d: %w", msg.Offset, err)
+	}
+	return env, nil
+}
+
+// Original восстанавливает исходное сообщение: ключ, тело и заголовки
+func (e Envelope) Original() kafka.Message {
+	msg := kafka.Message{Key: e.Key, Value: e.Value}
+	for _, h := range e.Headers {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: h.Key, Value: h.Value})
+	}
+	return msg
+}
@@ -0,0 +1,82 @@
+package dlq
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Filter отбирает сообщения DLQ; пустые поля не ограничивают выборку
+type Filter struct {
+	OrderID string
+	Reason  Reason
+	// Since/Until ограничивают время отправки в DLQ (FailedAt)
+	Since time.Time
+	Until time.Time
+}
+
+// ParseFilter разбирает фильтр вида "order_id=42,reason=unmarshal,since=1h".
+// since/until принимают время в RFC3339 или длительность, отсчитываемую назад от now.
+func ParseFilter(s string, now time.Time) (Filter, error) {
+	var f Filter
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Filter{}, fmt.Errorf("filter %q: expected key=value", part)
+		}
+		switch strings.TrimSpace(key) {
+		case "order_id":
+			f.OrderID = value
+		case "reason":
+			f.Reason = Reason(value)
+		case "since":
+			t, err := parseTime(value, now)
+			if err != nil {
+				return Filter{}, fmt.Errorf("filter since: %w", err)
+			}
+			f.Since = t
+		case "until":
+			t, err := parseTime(value, now)
+			if err != nil {
+				return Filter{}, fmt.Errorf("filter until: %w", err)
+			}
+			f.Until = t
+		default:
+			return Filter{}, fmt.Errorf("unknown filter key %q (want order_id, reason, since, until)", key)
+		}
+	}
+	return f, nil
+}
+
+// Match проверяет, подходит ли конверт под фильтр
+func (f Filter) Match(e Envelope) bool {
+	if f.OrderID != "" && e.OrderID != f.OrderID {
+		return false
+	}
+	if f.Reason != "" && e.Reason != f.Reason {
+		return false
+	}
+	if !f.Since.IsZero() && e.FailedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.FailedAt.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// parseTime принимает RFC3339 или длительность назад от now, например 30m
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither RFC3339 time nor duration", s)
+	}
+	return t, nil
+}
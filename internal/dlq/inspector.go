@@ -0,0 +1,259 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Record — сообщение DLQ вместе с его положением в топике DLQ
+type Record struct {
+	DLQPartition int    `json:"dlq_partition"`
+	DLQOffset    int64  `json:"dlq_offset"`
+	DecodeError  string `json:"decode_error,omitempty"`
+	Envelope
+}
+
+// Inspector читает, переотправляет и очищает топик DLQ
+type Inspector struct {
+	brokers []string
+	topic   string
+	client  *kafka.Client
+}
+
+// NewInspector создаёт инспектор топика DLQ
+func NewInspector(brokers []string, topic string) *Inspector {
+	return &Inspector{
+		brokers: brokers,
+		topic:   topic,
+		client:  &kafka.Client{Addr: kafka.TCP(brokers...), Timeout: 10 * time.Second},
+	}
+}
+
+// Scan читает все сообщения DLQ от начала до текущего конца каждой партиции
+func (in *Inspector) Scan(ctx context.Context, fn func(Record) error) error {
+	bounds, err := in.bounds(ctx)
+	if err != nil {
+		return err
+	}
+	for _, b := range bounds {
+		if b.Error != nil {
+			return fmt.Errorf("offsets of partition %d: %w", b.Partition, b.Error)
+		}
+		if b.FirstOffset >= b.LastOffset {
+			continue
+		}
+		if err := in.scanPartition(ctx, b.Partition, b.FirstOffset, b.LastOffset, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Show возвращает одно сообщение DLQ по партиции и оффсету.
+// Оффсет вне [FirstOffset, LastOffset) партиции — ошибка без чтения: чтение ждало бы вечно.
+func (in *Inspector) Show(ctx context.Context, partition int, offset int64) (Record, error) {
+	bounds, err := in.bounds(ctx)
+	if err != nil {
+		return Record{}, err
+	}
+	if err := checkOffset(bounds, partition, offset); err != nil {
+		return Record{}, err
+	}
+
+	var found Record
+	errFound := errors.New("found")
+	err = in.scanPartition(ctx, partition, offset, offset+1, func(r Record) error {
+		found = r
+		return errFound
+	})
+	if errors.Is(err, errFound) {
+		return found, nil
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{}, noMessage(partition, offset)
+}
+
+// checkOffset проверяет, что в партиции есть сообщение с таким оффсетом
+func checkOffset(bounds []kafka.PartitionOffsets, partition int, offset int64) error {
+	for _, b := range bounds {
+		if b.Partition != partition {
+			continue
+		}
+		if b.Error != nil {
+			return fmt.Errorf("offsets of partition %d: %w", b.Partition, b.Error)
+		}
+		if offset < b.FirstOffset || offset >= b.LastOffset {
+			return noMessage(partition, offset)
+		}
+		return nil
+	}
+	return noMessage(partition, offset)
+}
+
+func noMessage(partition int, offset int64) error {
+	return fmt.Errorf("no message at partition %d offset %d", partition, offset)
+}
+
+// Purge удаляет все сообщения DLQ: топик пересоздаётся с тем же числом партиций.
+// В Kafka нет удаления отдельных сообщений через этот клиент, поэтому операция необратима.
+func (in *Inspector) Purge(ctx context.Context) error {
+	meta, err := in.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{in.topic}})
+	if err != nil {
+		return fmt.Errorf("metadata of %s: %w", in.topic, err)
+	}
+	if len(meta.Topics) == 0 || meta.Topics[0].Error != nil {
+		return fmt.Errorf("topic %s not found", in.topic)
+	}
+	topic := meta.Topics[0]
+	replicas := 1
+	if len(topic.Partitions) > 0 && len(topic.Partitions[0].Replicas) > 0 {
+		replicas = len(topic.Partitions[0].Replicas)
+	}
+
+	del, err := in.client.DeleteTopics(ctx, &kafka.DeleteTopicsRequest{Topics: []string{in.topic}})
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", in.topic, err)
+	}
+	if err := del.Errors[in.topic]; err != nil {
+		return fmt.Errorf("delete %s: %w", in.topic, err)
+	}
+
+	// удаление топика асинхронное: пересоздаём, пока брокер не перестанет сообщать, что он существует
+	for {
+		created, err := in.client.CreateTopics(ctx, &kafka.CreateTopicsRequest{Topics: []kafka.TopicConfig{{
+			Topic:             in.topic,
+			NumPartitions:     len(topic.Partitions),
+			ReplicationFactor: replicas,
+		}}})
+		if err == nil {
+			err = created.Errors[in.topic]
+		}
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, kafka.TopicAlreadyExists) {
+			return fmt.Errorf("recreate %s: %w", in.topic, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// ReplayMessage готовит исходное сообщение к повторной отправке в основной топик:
+// счётчик retries обнуляется, отложенный срок обработки снимается, ключ сохраняется,
+// добавляется ReplayHeader.
+func ReplayMessage(e Envelope, topic string) kafka.Message {
+	msg := e.Original()
+	msg.Topic = topic
+
+	headers := msg.Headers[:0:0]
+	for _, h := range msg.Headers {
+		if strings.EqualFold(h.Key, "retries") || strings.EqualFold(h.Key, "not-before") || strings.EqualFold(h.Key, ReplayHeader) {
+			continue
+		}
+		headers = append(headers, h)
+	}
+	msg.Headers = append(headers,
+		kafka.Header{Key: "retries", Value: []byte("0")},
+		kafka.Header{Key: ReplayHeader, Value: []byte("1")},
+	)
+	return msg
+}
+
+// partitions возвращает номера партиций топика DLQ
+func (in *Inspector) partitions(ctx context.Context) ([]int, error) {
+	meta, err := in.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{in.topic}})
+	if err != nil {
+		return nil, fmt.Errorf("metadata of %s: %w", in.topic, err)
+	}
+	if len(meta.Topics) == 0 {
+		return nil, fmt.Errorf("topic %s not found", in.topic)
+	}
+	if err := meta.Topics[0].Error; err != nil {
+		return nil, fmt.Errorf("topic %s: %w", in.topic, err)
+	}
+
+	var ids []int
+	for _, p := range meta.Topics[0].Partitions {
+		ids = append(ids, p.ID)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// bounds возвращает первый и следующий за последним оффсеты каждой партиции DLQ
+func (in *Inspector) bounds(ctx context.Context) ([]kafka.PartitionOffsets, error) {
+	partitions, err := in.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets, err := in.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{in.topic: offsetRequests(partitions)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list offsets of %s: %w", in.topic, err)
+	}
+
+	bounds := offsets.Topics[in.topic]
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].Partition < bounds[j].Partition })
+	return bounds, nil
+}
+
+// scanPartition читает сообщения партиции в диапазоне [from, to)
+func (in *Inspector) scanPartition(ctx context.Context, partition int, from, to int64, fn func(Record) error) error {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   in.brokers,
+		Topic:     in.topic,
+		Partition: partition,
+		MaxBytes:  10e6,
+	})
+	defer reader.Close()
+
+	if err := reader.SetOffset(from); err != nil {
+		return fmt.Errorf("seek partition %d to %d: %w", partition, from, err)
+	}
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return fmt.Errorf("read partition %d: %w", partition, err)
+		}
+		if msg.Offset >= to {
+			return nil
+		}
+
+		rec := Record{DLQPartition: msg.Partition, DLQOffset: msg.Offset}
+		env, err := Decode(msg)
+		if err != nil {
+			rec.DecodeError = err.Error()
+			rec.Envelope = Envelope{Key: msg.Key, Value: msg.Value}
+		} else {
+			rec.Envelope = env
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+		if msg.Offset >= to-1 {
+			return nil
+		}
+	}
+}
+
+func offsetRequests(partitions []int) []kafka.OffsetRequest {
+	reqs := make([]kafka.OffsetRequest, 0, 2*len(partitions))
+	for _, p := range partitions {
+		reqs = append(reqs, kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+	}
+	return reqs
+}
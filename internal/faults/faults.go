@@ -0,0 +1,80 @@
+package faults
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Kind — класс ошибки, по которому воркер решает, что делать с сообщением
+type Kind int
+
+const (
+	// Retryable — временный сбой: операцию стоит повторить
+	Retryable Kind = iota + 1
+	// Permanent — повтор не поможет
+	Permanent
+	// RateLimited — внешняя система просит подождать RetryAfter
+	RateLimited
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Retryable:
+		return "retryable"
+	case Permanent:
+		return "permanent"
+	case RateLimited:
+		return "rate_limited"
+	}
+	return "unknown"
+}
+
+// Error — ошибка с классом
+type Error struct {
+	Kind Kind
+	// RetryAfter — сколько ждать перед повтором, только для RateLimited
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// AsRetryable помечает ошибку как временную
+func AsRetryable(err error) error {
+	return &Error{Kind: Retryable, Err: err}
+}
+
+// AsPermanent помечает ошибку как постоянную
+func AsPermanent(err error) error {
+	return &Error{Kind: Permanent, Err: err}
+}
+
+// AsRateLimited помечает ошибку как превышение лимита внешней системы
+func AsRateLimited(err error, retryAfter time.Duration) error {
+	return &Error{Kind: RateLimited, RetryAfter: retryAfter, Err: err}
+}
+
+// Retryablef — временная ошибка с форматированием, как fmt.Errorf
+func Retryablef(format string, args ...any) error {
+	return AsRetryable(fmt.Errorf(format, args...))
+}
+
+// KindOf возвращает класс ошибки; ok = false, если ошибка не классифицирована
+func KindOf(err error) (kind Kind, ok bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind, true
+	}
+	return 0, false
+}
+
+// RetryAfter возвращает задержку ошибки RateLimited
+func RetryAfter(err error) (time.Duration, bool) {
+	var e *Error
+	if errors.As(err, &e) && e.Kind == RateLimited {
+		return e.RetryAfter, true
+	}
+	return 0, false
+}
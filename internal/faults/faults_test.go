@@ -0,0 +1,28 @@
+package faults
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKindSurvivesWrapping(t *testing.T) {
+	cause := errors.New("redis: connection refused")
+	err := fmt.Errorf("store result: %w", AsRetryable(cause))
+
+	kind, ok := KindOf(err)
+	require.True(t, ok)
+	require.Equal(t, Retryable, kind)
+	require.ErrorIs(t, err, cause)
+
+	err = fmt.Errorf("stage fraud: %w", AsRateLimited(errors.New("429"), 2*time.Second))
+	after, ok := RetryAfter(err)
+	require.True(t, ok)
+	require.Equal(t, 2*time.Second, after)
+
+	_, ok = KindOf(errors.New("plain"))
+	require.False(t, ok)
+}
@@ -0,0 +1,148 @@
+// Package health проверяет зависимости сервиса и отдаёт итог в grpc.health.v1 и HTTP-пробы.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check — проверка одной зависимости. Probe возвращает краткое описание состояния
+// (например, назначенные партиции) или ошибку, если зависимость недоступна.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) (string, error)
+}
+
+// Result — итог одной проверки
+type Result struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report — итог всех проверок монитора
+type Report struct {
+	Ready        bool      `json:"ready"`
+	ShuttingDown bool      `json:"shutting_down,omitempty"`
+	CheckedAt    time.Time `json:"checked_at,omitzero"`
+	Checks       []Result  `json:"checks"`
+}
+
+// Monitor периодически выполняет проверки и хранит последний итог.
+// Сервис готов, когда все проверки прошли и остановка ещё не началась.
+type Monitor struct {
+	checks   []Check
+	interval time.Duration
+	timeout  time.Duration
+
+	mu        sync.Mutex
+	report    Report
+	listeners []func(ready bool)
+}
+
+// NewMonitor конструктор монитора: проверки выполняются каждые interval,
+// каждая ограничена timeout. До первой проверки сервис не готов.
+func NewMonitor(interval, timeout time.Duration, checks ...Check) *Monitor {
+	return &Monitor{checks: checks, interval: interval, timeout: timeout}
+}
+
+// OnChange подписывает fn на смену готовности; fn сразу получает текущее значение
+func (m *Monitor) OnChange(fn func(ready bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+	fn(m.report.Ready)
+}
+
+// Run выполняет проверки сразу и затем каждые interval до отмены ctx
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.CheckNow(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckNow выполняет все проверки параллельно и возвращает новый итог
+func (m *Monitor) CheckNow(ctx context.Context) Report {
+	results := make([]Result, len(m.checks))
+	var wg sync.WaitGroup
+	for i, c := range m.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, m.timeout)
+			defer cancel()
+			detail, err := c.Probe(probeCtx)
+			results[i] = Result{Name: c.Name, OK: err == nil, Detail: detail}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, r := range results {
+		ready = ready && r.OK
+	}
+	return m.update(func(r *Report) {
+		r.Checks = results
+		r.CheckedAt = time.Now()
+		r.Ready = ready && !r.ShuttingDown
+	})
+}
+
+// Shutdown отмечает начало остановки: сервис больше не готов, проверки это не меняют
+func (m *Monitor) Shutdown() {
+	m.update(func(r *Report) {
+		r.ShuttingDown = true
+		r.Ready = false
+	})
+}
+
+// Report возвращает последний итог проверок
+func (m *Monitor) Report() Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.report
+}
+
+// update меняет итог под блокировкой и оповещает подписчиков, если изменилась готовность
+func (m *Monitor) update(fn func(*Report)) Report {
+	m.mu.Lock()
+	was := m.report.Ready
+	fn(&m.report)
+	report := m.report
+	// подписчики вызываются под блокировкой, чтобы порядок оповещений совпадал с порядком изменений
+	if report.Ready != was {
+		slog.Info("readiness changed", "ready", report.Ready, "shutting_down", report.ShuttingDown)
+		for _, l := range m.listeners {
+			l(report.Ready)
+		}
+	}
+	m.mu.Unlock()
+	return report
+}
+
+// Handler отдаёт итог проверок в JSON: 200, если сервис готов, иначе 503
+func (m *Monitor) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := m.Report()
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// switchCheck — проверка, результат которой задаёт тест
+func switchCheck(name string, err *error) Check {
+	return Check{Name: name, Probe: func(context.Context) (string, error) { return "detail", *err }}
+}
+
+func TestMonitorReportsFailingCheck(t *testing.T) {
+	var redisErr, kafkaErr error
+	m := NewMonitor(time.Minute, time.Second, switchCheck("redis", &redisErr), switchCheck("kafka", &kafkaErr))
+	require.False(t, m.Report().Ready, "not ready before the first check")
+
+	require.True(t, m.CheckNow(context.Background()).Ready)
+
+	kafkaErr = errors.New("no brokers")
+	report := m.CheckNow(context.Background())
+	require.False(t, report.Ready)
+	require.Equal(t, []Result{
+		{Name: "redis", OK: true, Detail: "detail"},
+		{Name: "kafka", OK: false, Detail: "detail", Error: "no brokers"},
+	}, report.Checks)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var body Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, "no brokers", body.Checks[1].Error)
+}
+
+func TestShutdownFlipsGRPCStatus(t *testing.T) {
+	var err error
+	m := NewMonitor(time.Minute, time.Second, switchCheck("redis", &err))
+	hs := health.NewServer()
+	ServeGRPC(m, hs, "order.OrderService")
+
+	status := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return resp.Status
+	}
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(""))
+
+	m.CheckNow(context.Background())
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, status(""))
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, status("order.OrderService"))
+
+	m.Shutdown()
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status("order.OrderService"))
+
+	// проверки после начала остановки готовность не возвращают
+	require.False(t, m.CheckNow(context.Background()).Ready)
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(""))
+}
+
+func TestRedisPing(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	check := RedisPing(rdb)
+
+	_, err := check.Probe(context.Background())
+	require.NoError(t, err)
+
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	_, err = check.Probe(context.Background())
+	require.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Pinger — клиент Redis, который умеет PING
+type Pinger interface {
+	Ping(ctx context.Context) *redis.StatusCmd
+}
+
+// RedisPing проверяет, что Redis отвечает на PING
+func RedisPing(rdb Pinger) Check {
+	return Check{Name: "redis", Probe: func(ctx context.Context) (string, error) {
+		return "", rdb.Ping(ctx).Err()
+	}}
+}
+
+// KafkaMetadata проверяет, что брокеры отдают метаданные топика
+func KafkaMetadata(brokers []string, topic string) Check {
+	return Check{Name: "kafka", Probe: func(ctx context.Context) (string, error) {
+		client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+		meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+		if err != nil {
+			return "", err
+		}
+		for _, t := range meta.Topics {
+			if t.Name != topic {
+				continue
+			}
+			if t.Error != nil {
+				return "", fmt.Errorf("topic %s: %w", topic, t.Error)
+			}
+			return fmt.Sprintf("topic %s: %d partitions", topic, len(t.Partitions)), nil
+		}
+		return "", fmt.Errorf("topic %s not found", topic)
+	}}
+}
+
+// GroupMembership проверяет, что потребитель с clientID состоит в группе group,
+// и сообщает назначенные ему партиции
+func GroupMembership(brokers []string, group, clientID string) Check {
+	return Check{Name: "kafka_group", Probe: func(ctx context.Context) (string, error) {
+		client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+		coord, err := client.FindCoordinator(ctx, &kafka.FindCoordinatorRequest{Key: group, KeyType: kafka.CoordinatorKeyTypeConsumer})
+		if err != nil {
+			return "", err
+		}
+		if coord.Error != nil {
+			return "", fmt.Errorf("find coordinator of group %s: %w", group, coord.Error)
+		}
+		addr := kafka.TCP(net.JoinHostPort(coord.Coordinator.Host, strconv.Itoa(coord.Coordinator.Port)))
+
+		desc, err := client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{Addr: addr, GroupIDs: []string{group}})
+		if err != nil {
+			return "", err
+		}
+		for _, g := range desc.Groups {
+			if g.GroupID != group {
+				continue
+			}
+			if g.Error != nil {
+				return "", fmt.Errorf("describe group %s: %w", group, g.Error)
+			}
+			for _, member := range g.Members {
+				if member.ClientID != clientID {
+					continue
+				}
+				partitions := 0
+				for _, t := range member.MemberAssignments.Topics {
+					partitions += len(t.Partitions)
+				}
+				return fmt.Sprintf("member of %s (%s), %d partitions assigned", group, g.GroupState, partitions), nil
+			}
+			return "", fmt.Errorf("client %s is not a member of group %s (state %s)", clientID, group, g.GroupState)
+		}
+		return "", fmt.Errorf("group %s not found", group)
+	}}
+}
+
+// ServeGRPC переносит готовность монитора в статусы grpc.health.v1: общий ("") и
+// каждого сервиса из services. После Shutdown монитора статус остаётся NOT_SERVING.
+func ServeGRPC(m *Monitor, hs *health.Server, services ...string) {
+	m.OnChange(func(ready bool) {
+		st := healthpb.HealthCheckResponse_NOT_SERVING
+		if ready {
+			st = healthpb.HealthCheckResponse_SERVING
+		}
+		hs.SetServingStatus("", st)
+		for _, s := range services {
+			hs.SetServingStatus(s, st)
+		}
+	})
+}
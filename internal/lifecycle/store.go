@@ -0,0 +1,236 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// transitions перечисляет допустимые переходы: из состояния → в состояния.
+// UNSPECIFIED означает, что записи о заказе ещё нет (или она записана до появления состояний).
+// Незавершённые состояния можно повторять: сообщение может быть доставлено повторно.
+// Выход из FAILED и DEAD_LETTERED — только replayTransitions.
+var transitions = map[pb.OrderState][]pb.OrderState{
+	pb.OrderState_ORDER_STATE_UNSPECIFIED: {
+		pb.OrderState_ORDER_STATE_ACCEPTED,
+		pb.OrderState_ORDER_STATE_PROCESSING,
+		pb.OrderState_ORDER_STATE_FAILED,
+		pb.OrderState_ORDER_STATE_DEAD_LETTERED,
+	},
+	pb.OrderState_ORDER_STATE_ACCEPTED: {
+		pb.OrderState_ORDER_STATE_ACCEPTED,
+		pb.OrderState_ORDER_STATE_PROCESSING,
+		pb.OrderState_ORDER_STATE_FAILED,
+		pb.OrderState_ORDER_STATE_DEAD_LETTERED,
+	},
+	pb.OrderState_ORDER_STATE_PROCESSING: {
+		pb.OrderState_ORDER_STATE_PROCESSING,
+		pb.OrderState_ORDER_STATE_RETRYING,
+		pb.OrderState_ORDER_STATE_DONE,
+		pb.OrderState_ORDER_STATE_FAILED,
+		pb.OrderState_ORDER_STATE_DEAD_LETTERED,
+	},
+	pb.OrderState_ORDER_STATE_RETRYING: {
+		pb.OrderState_ORDER_STATE_RETRYING,
+		pb.OrderState_ORDER_STATE_PROCESSING,
+		pb.OrderState_ORDER_STATE_FAILED,
+		pb.OrderState_ORDER_STATE_DEAD_LETTERED,
+	},
+	pb.OrderState_ORDER_STATE_DONE:          {},
+	pb.OrderState_ORDER_STATE_FAILED:        {},
+	pb.OrderState_ORDER_STATE_DEAD_LETTERED: {},
+}
+
+// replayTransitions — дополнительные переходы для сообщения ручного replay из DLQ:
+// повторная доставка того же сообщения не должна снова запускать обработку
+var replayTransitions = map[pb.OrderState][]pb.OrderState{
+	pb.OrderState_ORDER_STATE_FAILED:        {pb.OrderState_ORDER_STATE_PROCESSING},
+	pb.OrderState_ORDER_STATE_DEAD_LETTERED: {pb.OrderState_ORDER_STATE_PROCESSING},
+}
+
+// CanTransition сообщает, допустим ли переход from → to
+func CanTransition(from, to pb.OrderState) bool {
+	return allowed(transitions, from, to)
+}
+
+// CanReplay сообщает, допустим ли переход from → to для сообщения replay из DLQ
+func CanReplay(from, to pb.OrderState) bool {
+	return allowed(transitions, from, to) || allowed(replayTransitions, from, to)
+}
+
+func allowed(table map[pb.OrderState][]pb.OrderState, from, to pb.OrderState) bool {
+	for _, s := range table[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal сообщает, что без вмешательства оператора заказ из состояния уже не выйдет
+func IsTerminal(state pb.OrderState) bool {
+	switch state {
+	case pb.OrderState_ORDER_STATE_DONE, pb.OrderState_ORDER_STATE_FAILED, pb.OrderState_ORDER_STATE_DEAD_LETTERED:
+		return true
+	}
+	return false
+}
+
+// Newer сообщает, что запись next сделана позже prev. Попытки только растут, а внутри
+// попытки заказ проходит PROCESSING и затем одно из итоговых состояний попытки.
+// Ручной replay из DLQ начинает попытки заново, поэтому сравнение верно только до
+// терминального состояния.
+func Newer(prev, next *pb.ResultResponse) bool {
+	if next.Attempts != prev.Attempts {
+		return next.Attempts > prev.Attempts
+	}
+	return attemptStage(next.State) > attemptStage(prev.State)
+}
+
+// attemptStage — порядок состояния внутри одной попытки
+func attemptStage(state pb.OrderState) int {
+	switch state {
+	case pb.OrderState_ORDER_STATE_UNSPECIFIED, pb.OrderState_ORDER_STATE_ACCEPTED:
+		return 0
+	case pb.OrderState_ORDER_STATE_PROCESSING:
+		return 1
+	}
+	return 2
+}
+
+// StatusName — короткое имя состояния для поля status: accepted, done, dead_lettered...
+func StatusName(state pb.OrderState) string {
+	return strings.ToLower(strings.TrimPrefix(state.String(), "ORDER_STATE_"))
+}
+
+// TransitionError — переход запрещён машиной состояний
+type TransitionError struct {
+	ID       string
+	From, To pb.OrderState
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("order %s: illegal transition %s -> %s", e.ID, StatusName(e.From), StatusName(e.To))
+}
+
+// Client — команды Redis, нужные хранилищу состояний
+type Client interface {
+	redis.Scripter
+}
+
+// transitionScript атомарно проверяет текущее состояние, записывает новую запись и
+// публикует её в канал событий заказа.
+// ARGV[1] — новая запись, ARGV[2] — канал событий, ARGV[3..] — состояния, из которых переход разрешён.
+// Возвращает {1, прежнее состояние} при успехе и {0, текущее состояние} при отказе.
+var transitionScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+local state = 'ORDER_STATE_UNSPECIFIED'
+if cur then
+  local ok, doc = pcall(cjson.decode, cur)
+  if ok and type(doc) == 'table' and type(doc['state']) == 'string' then
+    state = doc['state']
+  end
+end
+for i = 3, #ARGV do
+  if ARGV[i] == state then
+    redis.call('SET', KEYS[1], ARGV[1])
+    redis.call('PUBLISH', ARGV[2], ARGV[1])
+    return {1, state}
+  end
+end
+return {0, state}
+`)
+
+// deleteAcceptedScript удаляет запись о заказе, только пока она в состоянии ARGV[1]:
+// воркер мог уже взять заказ и записать PROCESSING или DONE, такую запись трогать нельзя.
+// Возвращает 1, если запись удалена.
+var deleteAcceptedScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if not cur then
+  return 0
+end
+local ok, doc = pcall(cjson.decode, cur)
+if ok and type(doc) == 'table' and doc['state'] == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Store хранит запись о заказе (ResultResponse) в Redis под ключом order:<id>
+// и меняет её только допустимыми переходами
+type Store struct {
+	rdb Client
+}
+
+// NewStore конструктор хранилища состояний
+func NewStore(rdb Client) *Store {
+	return &Store{rdb: rdb}
+}
+
+// Key — ключ записи о заказе в Redis
+func Key(id string) string {
+	return "order:" + id
+}
+
+// EventsChannelPrefix — общий префикс каналов событий; подписка на все заказы — EventsChannelPrefix + "*"
+const EventsChannelPrefix = "order-events:"
+
+// EventsChannel — канал Redis pub/sub, в который публикуется каждая новая запись о заказе
+func EventsChannel(id string) string {
+	return EventsChannelPrefix + id
+}
+
+// Transition записывает новую запись о заказе, если переход из текущего состояния
+// в next.State допустим, и публикует её в EventsChannel. Поле status заполняется по состоянию.
+// При запрещённом переходе возвращается *TransitionError.
+func (s *Store) Transition(ctx context.Context, id string, next *pb.ResultResponse) error {
+	return s.transition(ctx, id, next, CanTransition)
+}
+
+// Replay — Transition для сообщения ручного replay из DLQ: дополнительно разрешает
+// выход из FAILED и DEAD_LETTERED
+func (s *Store) Replay(ctx context.Context, id string, next *pb.ResultResponse) error {
+	return s.transition(ctx, id, next, CanReplay)
+}
+
+func (s *Store) transition(ctx context.Context, id string, next *pb.ResultResponse, can func(from, to pb.OrderState) bool) error {
+	next.Status = StatusName(next.State)
+	b, err := protojson.Marshal(next)
+	if err != nil {
+		return fmt.Errorf("marshal order %s state: %w", id, err)
+	}
+
+	args := []interface{}{b, EventsChannel(id)}
+	for from := range transitions {
+		if can(from, next.State) {
+			args = append(args, from.String())
+		}
+	}
+
+	res, err := transitionScript.Run(ctx, s.rdb, []string{Key(id)}, args...).Slice()
+	if err != nil {
+		return fmt.Errorf("order %s transition to %s: %w", id, StatusName(next.State), err)
+	}
+	if len(res) != 2 {
+		return fmt.Errorf("order %s transition: unexpected script reply %v", id, res)
+	}
+	if ok, _ := res[0].(int64); ok == 1 {
+		return nil
+	}
+	current, _ := res[1].(string)
+	return &TransitionError{ID: id, From: pb.OrderState(pb.OrderState_value[current]), To: next.State}
+}
+
+// DeleteAccepted удаляет запись о заказе, если он всё ещё в ACCEPTED, например когда его
+// не удалось опубликовать. deleted = false — записи нет или заказ уже дальше по жизненному циклу.
+func (s *Store) DeleteAccepted(ctx context.Context, id string) (deleted bool, err error) {
+	n, err := deleteAcceptedScript.Run(ctx, s.rdb, []string{Key(id)}, pb.OrderState_ORDER_STATE_ACCEPTED.String()).Int()
+	if err != nil {
+		return false, fmt.Errorf("order %s delete: %w", id, err)
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,111 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func newTestStore(t *testing.T) (*miniredis.Miniredis, *Store) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return mr, NewStore(rdb)
+}
+
+func TestStoreWalksLifecycle(t *testing.T) {
+	mr, store := newTestStore(t)
+	ctx := context.Background()
+
+	steps := []*pb.ResultResponse{
+		{State: pb.OrderState_ORDER_STATE_ACCEPTED, Item: "book", Price: 42},
+		{State: pb.OrderState_ORDER_STATE_PROCESSING, Item: "book", Price: 42, Attempts: 1},
+		{State: pb.OrderState_ORDER_STATE_RETRYING, Item: "book", Price: 42, Attempts: 1, LastError: "timeout"},
+		{State: pb.OrderState_ORDER_STATE_PROCESSING, Item: "book", Price: 42, Attempts: 2},
+		{State: pb.OrderState_ORDER_STATE_DONE, Item: "book", Price: 42, Attempts: 2},
+	}
+	for _, step := range steps {
+		require.NoError(t, store.Transition(ctx, "o-1", step))
+	}
+
+	raw, err := mr.Get(Key("o-1"))
+	require.NoError(t, err)
+	var got pb.ResultResponse
+	require.NoError(t, protojson.Unmarshal([]byte(raw), &got))
+	require.Equal(t, pb.OrderState_ORDER_STATE_DONE, got.State)
+	require.Equal(t, "done", got.Status)
+	require.Equal(t, int32(2), got.Attempts)
+
+	// DONE → PROCESSING запрещён, запись не меняется
+	err = store.Transition(ctx, "o-1", &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_PROCESSING, Attempts: 3})
+	var terr *TransitionError
+	require.True(t, errors.As(err, &terr))
+	require.Equal(t, pb.OrderState_ORDER_STATE_DONE, terr.From)
+	after, _ := mr.Get(Key("o-1"))
+	require.Equal(t, raw, after)
+}
+
+func TestStoreDeleteAcceptedKeepsLaterStates(t *testing.T) {
+	mr, store := newTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.Transition(ctx, "o-3", &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_ACCEPTED}))
+	require.NoError(t, store.Transition(ctx, "o-3", &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_PROCESSING, Attempts: 1}))
+	deleted, err := store.DeleteAccepted(ctx, "o-3")
+	require.NoError(t, err)
+	require.False(t, deleted)
+	require.True(t, mr.Exists(Key("o-3")))
+
+	require.NoError(t, store.Transition(ctx, "o-4", &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_ACCEPTED}))
+	deleted, err = store.DeleteAccepted(ctx, "o-4")
+	require.NoError(t, err)
+	require.True(t, deleted)
+	require.False(t, mr.Exists(Key("o-4")))
+}
+
+func TestStoreLeavesFailedOnlyOnReplay(t *testing.T) {
+	_, store := newTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.Transition(ctx, "o-5", &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_PROCESSING, Attempts: 1}))
+	require.NoError(t, store.Transition(ctx, "o-5", &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_FAILED, Attempts: 1}))
+
+	// повторная доставка того же сообщения не запускает обработку снова
+	err := store.Transition(ctx, "o-5", &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_PROCESSING, Attempts: 1})
+	var terr *TransitionError
+	require.True(t, errors.As(err, &terr))
+	require.Equal(t, pb.OrderState_ORDER_STATE_FAILED, terr.From)
+
+	require.NoError(t, store.Replay(ctx, "o-5", &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_PROCESSING, Attempts: 1}))
+}
+
+func TestStoreAcceptsLegacyRecords(t *testing.T) {
+	mr, store := newTestStore(t)
+
+	// запись старого формата без поля state
+	require.NoError(t, mr.Set(Key("o-2"), `{"item":"book","price":1,"status":"done"}`))
+	require.NoError(t, store.Transition(context.Background(), "o-2", &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_PROCESSING}))
+}
+
+func TestCanTransition(t *testing.T) {
+	require.False(t, CanTransition(pb.OrderState_ORDER_STATE_DEAD_LETTERED, pb.OrderState_ORDER_STATE_PROCESSING))
+	require.True(t, CanReplay(pb.OrderState_ORDER_STATE_DEAD_LETTERED, pb.OrderState_ORDER_STATE_PROCESSING))
+	require.False(t, CanReplay(pb.OrderState_ORDER_STATE_DONE, pb.OrderState_ORDER_STATE_PROCESSING))
+	require.False(t, CanTransition(pb.OrderState_ORDER_STATE_DONE, pb.OrderState_ORDER_STATE_PROCESSING))
+	require.False(t, CanTransition(pb.OrderState_ORDER_STATE_ACCEPTED, pb.OrderState_ORDER_STATE_DONE))
+	require.Equal(t, "dead_lettered", StatusName(pb.OrderState_ORDER_STATE_DEAD_LETTERED))
+}
+
+func TestNewer(t *testing.T) {
+	last := &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_RETRYING, Attempts: 1}
+	require.False(t, Newer(last, &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_PROCESSING, Attempts: 1}))
+	require.False(t, Newer(last, last))
+	require.True(t, Newer(last, &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_PROCESSING, Attempts: 2}))
+}
@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// levelBody — тело запроса и ответа LevelHandler
+type levelBody struct {
+	Level string `json:"level"`
+}
+
+// LevelHandler показывает (GET) и меняет (PUT или POST с {"level":"debug"}) уровень логов
+func LevelHandler(level *slog.LevelVar) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var body levelBody
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&body); err != nil {
+				http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			l, err := ParseLevel(body.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if prev := level.Level(); prev != l {
+				level.Set(l)
+				slog.Warn("log level changed", "from", prev.String(), "to", l.String())
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(levelBody{Level: level.Level().String()})
+	})
+}
@@ -0,0 +1,29 @@
+package logging
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor добавляет grpc_method в контекст записей обработчика
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(With(ctx, KeyMethod, info.FullMethod), req)
+	}
+}
+
+// StreamServerInterceptor делает то же для потоковых вызовов
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &methodStream{ServerStream: ss, ctx: With(ss.Context(), KeyMethod, info.FullMethod)})
+	}
+}
+
+// methodStream подменяет контекст потока
+type methodStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *methodStream) Context() context.Context { return s.ctx }
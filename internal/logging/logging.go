@@ -0,0 +1,142 @@
+// Package logging настраивает log/slog для сервисов: формат и уровень из конфигурации,
+// стандартные поля заказа из контекста и смену уровня без перезапуска.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Стандартные поля записей
+const (
+	KeyService   = "service"
+	KeyOrderID   = "order_id"
+	KeyTopic     = "topic"
+	KeyPartition = "partition"
+	KeyOffset    = "offset"
+	KeyRetry     = "retry"
+	KeyTraceID   = "trace_id"
+	KeyMethod    = "grpc_method"
+	KeyClient    = "client"
+	KeyPrincipal = "principal"
+	KeyError     = "error"
+)
+
+// Форматы вывода
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config задаёт вывод логов сервиса
+type Config struct {
+	// Service добавляется в каждую запись
+	Service string
+	// Format — json или text; пусто означает json
+	Format string
+	// Level — debug, info, warn или error; пусто означает info
+	Level string
+}
+
+// Setup делает slog-логгер по cfg логгером по умолчанию, в том числе для пакета log.
+// Возвращённым уровнем можно управлять во время работы, например через LevelHandler.
+func Setup(cfg Config) (*slog.LevelVar, error) {
+	return setup(os.Stderr, cfg)
+}
+
+func setup(w io.Writer, cfg Config) (*slog.LevelVar, error) {
+	level := new(slog.LevelVar)
+	if cfg.Level != "" {
+		l, err := ParseLevel(cfg.Level)
+		if err != nil {
+			return nil, err
+		}
+		level.Set(l)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch cfg.Format {
+	case "", FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want json or text)", cfg.Format)
+	}
+
+	logger := slog.New(contextHandler{h})
+	if cfg.Service != "" {
+		logger = logger.With(KeyService, cfg.Service)
+	}
+	slog.SetDefault(logger)
+	return level, nil
+}
+
+// ParseLevel разбирает имя уровня: debug, info, warn или error
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+	}
+	return l, nil
+}
+
+// Fatal пишет запись уровня error и завершает процесс
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type ctxKey struct{}
+
+// With возвращает ctx, записи с которым получат поля args (пары ключ-значение или slog.Attr)
+func With(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	r := slog.Record{}
+	r.Add(args...)
+	attrs := make([]slog.Attr, 0, len(prev)+r.NumAttrs())
+	attrs = append(attrs, prev...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+// Message — поля записи о сообщении Kafka: топик, партиция и оффсет
+func Message(msg kafka.Message) []any {
+	return []any{KeyTopic, msg.Topic, KeyPartition, msg.Partition, KeyOffset, msg.Offset}
+}
+
+// contextHandler дополняет запись полями из With и trace_id активного спана
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+			r.AddAttrs(attrs...)
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			r.AddAttrs(slog.String(KeyTraceID, sc.TraceID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
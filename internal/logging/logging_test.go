@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// capture направляет логгер по умолчанию в буфер до конца теста
+func capture(t *testing.T, cfg Config) (*bytes.Buffer, *slog.LevelVar) {
+	t.Helper()
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	var buf bytes.Buffer
+	level, err := setup(&buf, cfg)
+	require.NoError(t, err)
+	return &buf, level
+}
+
+func decodeLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	buf.Reset()
+	return line
+}
+
+func TestRecordsCarryContextFields(t *testing.T) {
+	buf, _ := capture(t, Config{Service: "orderprocessor"})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	ctx = With(ctx, Message(kafka.Message{Topic: "orders", Partition: 2, Offset: 42})...)
+	ctx = With(ctx, KeyOrderID, "order-1", KeyRetry, 1)
+
+	slog.InfoContext(ctx, "processing order")
+	line := decodeLine(t, buf)
+	require.Equal(t, "processing order", line["msg"])
+	require.Equal(t, "orderprocessor", line[KeyService])
+	require.Equal(t, "order-1", line[KeyOrderID])
+	require.Equal(t, "orders", line[KeyTopic])
+	require.EqualValues(t, 2, line[KeyPartition])
+	require.EqualValues(t, 42, line[KeyOffset])
+	require.EqualValues(t, 1, line[KeyRetry])
+	require.Equal(t, traceID.String(), line[KeyTraceID])
+}
+
+func TestUnaryInterceptorAddsMethod(t *testing.T) {
+	buf, _ := capture(t, Config{})
+	info := &grpc.UnaryServerInfo{FullMethod: "/order.OrderService/CreateOrder"}
+
+	_, err := UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, _ any) (any, error) {
+		slog.WarnContext(ctx, "rejected")
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "/order.OrderService/CreateOrder", decodeLine(t, buf)[KeyMethod])
+}
+
+func TestLevelHandlerChangesLevelAtRuntime(t *testing.T) {
+	buf, level := capture(t, Config{Format: FormatText, Level: "warn"})
+	h := LevelHandler(level)
+
+	slog.Info("hidden")
+	require.Empty(t, buf.String())
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/loglevel", strings.NewReader(`{"level":"debug"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"level":"DEBUG"}`, rec.Body.String())
+	require.Equal(t, slog.LevelDebug, level.Level())
+	buf.Reset()
+
+	slog.Debug("visible")
+	require.Contains(t, buf.String(), "msg=visible")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/loglevel", strings.NewReader(`{"level":"loud"}`)))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, slog.LevelDebug, level.Level())
+}
+
+func TestSetupRejectsUnknownFormat(t *testing.T) {
+	_, err := Setup(Config{Format: "xml"})
+	require.Error(t, err)
+	_, err = Setup(Config{Level: "verbose"})
+	require.Error(t, err)
+}
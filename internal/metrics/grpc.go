@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor считает запросы, коды ответов и задержку unary-вызовов
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observeRPC(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor делает то же для потоковых вызовов; задержка — вся длительность потока
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.observeRPC(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func (m *Metrics) observeRPC(fullMethod string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	service, method := splitMethod(fullMethod)
+	m.rpcRequests.WithLabelValues(service, method, status.Code(err).String()).Inc()
+	m.rpcDuration.WithLabelValues(service, method).Observe(time.Since(start).Seconds())
+}
+
+// splitMethod разбирает "/order.OrderService/CreateOrder" на сервис и метод
+func splitMethod(fullMethod string) (string, string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "unknown", fullMethod
+	}
+	return service, method
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Writer — запись в Kafka, как server.KafkaWriter
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Reader — чтение из Kafka, как server.KafkaReader
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type instrumentedWriter struct {
+	Writer
+	m     *Metrics
+	topic string
+}
+
+// InstrumentWriter измеряет задержку и ошибки записи; topic — метка для метрик
+func (m *Metrics) InstrumentWriter(w Writer, topic string) Writer {
+	if m == nil {
+		return w
+	}
+	return &instrumentedWriter{Writer: w, m: m, topic: topic}
+}
+
+func (w *instrumentedWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	start := time.Now()
+	err := w.Writer.WriteMessages(ctx, msgs...)
+	w.m.produceDuration.WithLabelValues(w.topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		w.m.produceErrors.WithLabelValues(w.topic).Inc()
+	}
+	return err
+}
+
+type instrumentedReader struct {
+	Reader
+	m *Metrics
+}
+
+// InstrumentReader обновляет отставание потребителя по каждому полученному сообщению
+func (m *Metrics) InstrumentReader(r Reader) Reader {
+	if m == nil {
+		return r
+	}
+	return &instrumentedReader{Reader: r, m: m}
+}
+
+func (r *instrumentedReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	msg, err := r.Reader.FetchMessage(ctx)
+	if err == nil && msg.HighWaterMark > 0 {
+		// HighWaterMark — оффсет следующего сообщения, которое будет записано в партицию
+		lag := msg.HighWaterMark - msg.Offset - 1
+		r.m.consumerLag.WithLabelValues(msg.Topic, strconv.Itoa(msg.Partition)).Set(float64(max(lag, 0)))
+	}
+	return msg, err
+}
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "orders"
+
+// Metrics — метрики сервисов конвейера. Все методы допускают nil-получатель,
+// поэтому компоненты работают и без метрик, например в тестах.
+type Metrics struct {
+	rpcRequests *prometheus.CounterVec
+	rpcDuration *prometheus.HistogramVec
+
+	produceDuration *prometheus.HistogramVec
+	produceErrors   *prometheus.CounterVec
+	consumerLag     *prometheus.GaugeVec
+
+	processed *prometheus.CounterVec
+	dlq       *prometheus.CounterVec
+
+	redisDuration *prometheus.HistogramVec
+	redisErrors   *prometheus.CounterVec
+
+	stageDuration *prometheus.HistogramVec
+
+	shed            *prometheus.CounterVec
+	produceInFlight prometheus.Gauge
+	produceLimit    prometheus.Gauge
+	breakerState    prometheus.Gauge
+
+	outboxBacklog prometheus.Gauge
+}
+
+// New создаёт метрики и регистрирует их в reg
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		rpcRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "grpc_requests_total",
+			Help: "gRPC requests by service, method and status code.",
+		}, []string{"service", "method", "code"}),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "grpc_request_duration_seconds",
+			Help:    "gRPC request latency.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"service", "method"}),
+
+		produceDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "kafka_produce_duration_seconds",
+			Help:    "Kafka WriteMessages latency.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"topic"}),
+		produceErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "kafka_produce_errors_total",
+			Help: "Failed Kafka WriteMessages calls.",
+		}, []string{"topic"}),
+		consumerLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "kafka_consumer_lag",
+			Help: "Messages between the last fetched offset and the partition high watermark.",
+		}, []string{"topic", "partition"}),
+
+		processed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "processed_total",
+			Help: "Orders handled by the worker by result: done, retried, dead_lettered, failed, skipped, rate_limited.",
+		}, []string{"result"}),
+		dlq: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "dlq_messages_total",
+			Help: "Messages written to the DLQ by reason.",
+		}, []string{"reason"}),
+
+		redisDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "redis_command_duration_seconds",
+			Help:    "Redis command latency.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"command"}),
+		redisErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "redis_errors_total",
+			Help: "Failed Redis commands (a missing key is not an error).",
+		}, []string{"command"}),
+
+		stageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "stage_duration_seconds",
+			Help:    "Worker pipeline stage latency by outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"stage", "outcome"}),
+
+		shed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "shed_total",
+			Help: "Requests rejected without calling Kafka by reason: overloaded, circuit_open.",
+		}, []string{"reason"}),
+		produceInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "produce_in_flight",
+			Help: "Kafka writes in progress.",
+		}),
+		produceLimit: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "produce_concurrency_limit",
+			Help: "Current adaptive limit of concurrent Kafka writes.",
+		}),
+		breakerState: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "producer_circuit_state",
+			Help: "Producer circuit breaker: 0 closed, 1 half-open, 2 open.",
+		}),
+
+		outboxBacklog: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "outbox_backlog",
+			Help: "Accepted orders in the outbox waiting to be published to Kafka.",
+		}),
+	}
+	reg.MustRegister(
+		m.rpcRequests, m.rpcDuration,
+		m.produceDuration, m.produceErrors, m.consumerLag,
+		m.processed, m.dlq,
+		m.redisDuration, m.redisErrors,
+		m.stageDuration,
+		m.shed, m.produceInFlight, m.produceLimit, m.breakerState,
+		m.outboxBacklog,
+	)
+	return m
+}
+
+// NewRegistry — реестр сервиса со стандартными метриками Go и процесса
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	return reg
+}
+
+// OrderProcessed учитывает итог обработки заказа воркером
+func (m *Metrics) OrderProcessed(result string) {
+	if m == nil {
+		return
+	}
+	m.processed.WithLabelValues(result).Inc()
+}
+
+// DLQWritten учитывает сообщение, записанное в DLQ
+func (m *Metrics) DLQWritten(reason string) {
+	if m == nil {
+		return
+	}
+	m.dlq.WithLabelValues(reason).Inc()
+}
+
+// ObserveStage учитывает время стадии; outcome — ok, skip или класс ошибки
+func (m *Metrics) ObserveStage(stage string, d time.Duration, outcome string) {
+	if m == nil {
+		return
+	}
+	m.stageDuration.WithLabelValues(stage, outcome).Observe(d.Seconds())
+}
+
+// Shed учитывает запрос, отклонённый без обращения к Kafka
+func (m *Metrics) Shed(reason string) {
+	if m == nil {
+		return
+	}
+	m.shed.WithLabelValues(reason).Inc()
+}
+
+// ObserveProduceLimit обновляет число записей в Kafka в работе и их текущий предел
+func (m *Metrics) ObserveProduceLimit(inFlight, limit int) {
+	if m == nil {
+		return
+	}
+	m.produceInFlight.Set(float64(inFlight))
+	m.produceLimit.Set(float64(limit))
+}
+
+// SetBreakerState обновляет состояние предохранителя записи: 0 закрыт, 1 пробует, 2 разомкнут
+func (m *Metrics) SetBreakerState(state int) {
+	if m == nil {
+		return
+	}
+	m.breakerState.Set(float64(state))
+}
+
+// SetOutboxBacklog обновляет число заказов в outbox, ещё не опубликованных в Kafka
+func (m *Metrics) SetOutboxBacklog(n int) {
+	if m == nil {
+		return
+	}
+	m.outboxBacklog.Set(float64(n))
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryInterceptorCountsCodes(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+	intercept := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/order.OrderService/CreateOrder"}
+
+	_, err := intercept(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	_, err = intercept(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		return nil, status.Error(codes.InvalidArgument, "bad order")
+	})
+	require.Error(t, err)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.rpcRequests.WithLabelValues("order.OrderService", "CreateOrder", "OK")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.rpcRequests.WithLabelValues("order.OrderService", "CreateOrder", "InvalidArgument")))
+	require.Equal(t, 1, testutil.CollectAndCount(m.rpcDuration))
+}
+
+type stubWriter struct{ err error }
+
+func (w stubWriter) WriteMessages(context.Context, ...kafka.Message) error { return w.err }
+func (w stubWriter) Close() error                                          { return nil }
+
+func TestInstrumentWriterCountsErrors(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+
+	require.NoError(t, m.InstrumentWriter(stubWriter{}, "orders").WriteMessages(context.Background()))
+	require.Error(t, m.InstrumentWriter(stubWriter{err: errors.New("broker down")}, "orders").WriteMessages(context.Background()))
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.produceErrors.WithLabelValues("orders")))
+	require.Equal(t, 1, testutil.CollectAndCount(m.produceDuration))
+}
+
+type stubReader struct{ msg kafka.Message }
+
+func (r stubReader) FetchMessage(context.Context) (kafka.Message, error)    { return r.msg, nil }
+func (r stubReader) CommitMessages(context.Context, ...kafka.Message) error { return nil }
+func (r stubReader) Close() error                                           { return nil }
+
+func TestInstrumentReaderReportsLag(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+	r := m.InstrumentReader(stubReader{msg: kafka.Message{Topic: "orders", Partition: 2, Offset: 10, HighWaterMark: 15}})
+
+	_, err := r.FetchMessage(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 4.0, testutil.ToFloat64(m.consumerLag.WithLabelValues("orders", "2")))
+}
+
+func TestNilMetricsAreNoop(t *testing.T) {
+	var m *Metrics
+	w := stubWriter{}
+	require.Equal(t, Writer(w), m.InstrumentWriter(w, "orders"))
+	m.OrderProcessed("done")
+	m.DLQWritten("validation")
+	m.ObserveStage("price", time.Millisecond, "ok")
+}
@@ -0,0 +1,108 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	pb "github.com/go-portfolio/order-pipeline/proto"
+)
+
+// ErrOverflow — сумма не помещается в int64 минимальных единиц
+var ErrOverflow = errors.New("money: amount overflows int64")
+
+// exponents — число знаков после запятой у валют ISO 4217, которые принимает конвейер
+var exponents = map[string]int{
+	"AED": 2, "AUD": 2, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2, "DKK": 2,
+	"EUR": 2, "GBP": 2, "HKD": 2, "INR": 2, "JPY": 0, "KRW": 0, "KZT": 2, "KWD": 3,
+	"MXN": 2, "NOK": 2, "NZD": 2, "PLN": 2, "RUB": 2, "SEK": 2, "SGD": 2, "TRY": 2,
+	"UAH": 2, "USD": 2, "ZAR": 2,
+}
+
+// Exponent возвращает число знаков после запятой валюты и false для неизвестной валюты
+func Exponent(currency string) (int, bool) {
+	e, ok := exponents[currency]
+	return e, ok
+}
+
+// FromMajor переводит сумму в целых единицах валюты (рублях, долларах) в минимальные
+func FromMajor(major int64, currency string) (*pb.Money, error) {
+	exp, ok := Exponent(currency)
+	if !ok {
+		return nil, fmt.Errorf("money: unknown currency %q", currency)
+	}
+	minor := major
+	for i := 0; i < exp; i++ {
+		if minor > math.MaxInt64/10 || minor < math.MinInt64/10 {
+			return nil, ErrOverflow
+		}
+		minor *= 10
+	}
+	return &pb.Money{Currency: currency, MinorUnits: minor}, nil
+}
+
+// Mul умножает сумму на количество
+func Mul(m *pb.Money, qty int32) (*pb.Money, error) {
+	if qty != 0 && (m.MinorUnits > math.MaxInt64/int64(qty) || m.MinorUnits < math.MinInt64/int64(qty)) {
+		return nil, ErrOverflow
+	}
+	return &pb.Money{Currency: m.Currency, MinorUnits: m.MinorUnits * int64(qty)}, nil
+}
+
+// Add складывает суммы в одной валюте
+func Add(a, b *pb.Money) (*pb.Money, error) {
+	if a.Currency != b.Currency {
+		return nil, fmt.Errorf("money: cannot add %s to %s", b.Currency, a.Currency)
+	}
+	if (b.MinorUnits > 0 && a.MinorUnits > math.MaxInt64-b.MinorUnits) ||
+		(b.MinorUnits < 0 && a.MinorUnits < math.MinInt64-b.MinorUnits) {
+		return nil, ErrOverflow
+	}
+	return &pb.Money{Currency: a.Currency, MinorUnits: a.MinorUnits + b.MinorUnits}, nil
+}
+
+// Format печатает сумму с десятичной точкой: "12.50 USD"
+func Format(m *pb.Money) string {
+	exp, ok := Exponent(m.Currency)
+	if !ok || exp == 0 {
+		return fmt.Sprintf("%d %s", m.MinorUnits, m.Currency)
+	}
+	sign, v := "", m.MinorUnits
+	if v < 0 {
+		sign = "-"
+	}
+	digits := fmt.Sprintf("%0*d", exp+1, absUint(v))
+	cut := len(digits) - exp
+	return fmt.Sprintf("%s%s.%s %s", sign, digits[:cut], digits[cut:], m.Currency)
+}
+
+func absUint(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
+
+// NormalizeCurrency приводит код валюты к верхнему регистру
+func NormalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Percent возвращает долю суммы в базисных пунктах (1900 = 19%) с округлением half-up
+func Percent(m *pb.Money, bps int64) (*pb.Money, error) {
+	if bps < 0 {
+		return nil, fmt.Errorf("money: negative rate %d bps", bps)
+	}
+	if bps != 0 && (m.MinorUnits > math.MaxInt64/bps || m.MinorUnits < math.MinInt64/bps) {
+		return nil, ErrOverflow
+	}
+	p := m.MinorUnits * bps
+	q, r := p/10_000, p%10_000
+	if r >= 5_000 {
+		q++
+	} else if r <= -5_000 {
+		q--
+	}
+	return &pb.Money{Currency: m.Currency, MinorUnits: q}, nil
+}
@@ -0,0 +1,50 @@
+package money
+
+import (
+	"math"
+	"testing"
+
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFromMajorUsesCurrencyExponent(t *testing.T) {
+	m, err := FromMajor(42, "USD")
+	require.NoError(t, err)
+	require.Equal(t, int64(4200), m.MinorUnits)
+
+	m, err = FromMajor(42, "JPY")
+	require.NoError(t, err)
+	require.Equal(t, int64(42), m.MinorUnits)
+
+	_, err = FromMajor(1, "XXX")
+	require.Error(t, err)
+}
+
+func TestFormat(t *testing.T) {
+	require.Equal(t, "12.05 USD", Format(&pb.Money{Currency: "USD", MinorUnits: 1205}))
+	require.Equal(t, "-0.50 EUR", Format(&pb.Money{Currency: "EUR", MinorUnits: -50}))
+	require.Equal(t, "1.250 KWD", Format(&pb.Money{Currency: "KWD", MinorUnits: 1250}))
+	require.Equal(t, "700 JPY", Format(&pb.Money{Currency: "JPY", MinorUnits: 700}))
+}
+
+func TestTotals(t *testing.T) {
+	order := &pb.OrderRequest{Currency: "USD", LineItems: []*pb.LineItem{
+		{Sku: "book", Quantity: 2, UnitPrice: &pb.Money{Currency: "USD", MinorUnits: 1250}},
+		{Sku: "pen", Quantity: 3, UnitPrice: &pb.Money{Currency: "USD", MinorUnits: 99}},
+	}}
+	totals, err := Totals(order)
+	require.NoError(t, err)
+	require.Equal(t, int64(2500), totals.Lines[0].Subtotal.MinorUnits)
+	require.Equal(t, int64(297), totals.Lines[1].Subtotal.MinorUnits)
+	require.True(t, proto.Equal(&pb.Money{Currency: "USD", MinorUnits: 2797}, totals.Total))
+
+	order.LineItems[1].UnitPrice.Currency = "EUR"
+	_, err = Totals(order)
+	require.ErrorContains(t, err, "differs from order currency")
+
+	order.LineItems = []*pb.LineItem{{Sku: "gold", Quantity: 2, UnitPrice: &pb.Money{Currency: "USD", MinorUnits: math.MaxInt64/2 + 1}}}
+	_, err = Totals(order)
+	require.ErrorIs(t, err, ErrOverflow)
+}
@@ -0,0 +1,35 @@
+package money
+
+import (
+	"fmt"
+
+	pb "github.com/go-portfolio/order-pipeline/proto"
+)
+
+// Totals считает стоимость каждой строки и итог заказа в валюте заказа.
+// Заказ должен быть приведён к версии 2 (validation.Normalize).
+func Totals(order *pb.OrderRequest) (*pb.OrderTotals, error) {
+	totals := &pb.OrderTotals{Total: &pb.Money{Currency: order.Currency}}
+	for i, li := range order.LineItems {
+		if li.UnitPrice == nil {
+			return nil, fmt.Errorf("line %d (%s): unit price is missing", i, li.Sku)
+		}
+		if li.UnitPrice.Currency != order.Currency {
+			return nil, fmt.Errorf("line %d (%s): currency %s differs from order currency %s", i, li.Sku, li.UnitPrice.Currency, order.Currency)
+		}
+		subtotal, err := Mul(li.UnitPrice, li.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("line %d (%s): %w", i, li.Sku, err)
+		}
+		if totals.Total, err = Add(totals.Total, subtotal); err != nil {
+			return nil, fmt.Errorf("order total: %w", err)
+		}
+		totals.Lines = append(totals.Lines, &pb.LineTotal{
+			Sku:       li.Sku,
+			Quantity:  li.Quantity,
+			UnitPrice: li.UnitPrice,
+			Subtotal:  subtotal,
+		})
+	}
+	return totals, nil
+}
@@ -0,0 +1,156 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProducer запоминает опубликованные сообщения; write решает итог каждой записи
+type fakeProducer struct {
+	mu    sync.Mutex
+	sent  []kafka.Message
+	write func(msgs []kafka.Message) error
+}
+
+func (p *fakeProducer) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.write(msgs)
+	var perItem kafka.WriteErrors
+	for i, msg := range msgs {
+		if err == nil || (errors.As(err, &perItem) && perItem[i] == nil) {
+			p.sent = append(p.sent, msg)
+		}
+	}
+	return err
+}
+
+func (p *fakeProducer) keys() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := make([]string, len(p.sent))
+	for i, msg := range p.sent {
+		keys[i] = string(msg.Key)
+	}
+	return keys
+}
+
+func order(id string) kafka.Message {
+	return kafka.Message{Key: []byte(id), Value: []byte("payload-" + id), Headers: []kafka.Header{{Key: "traceparent", Value: []byte("00-abc")}}}
+}
+
+func TestStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox", "orders.db")
+	store, err := Open(path, 0)
+	require.NoError(t, err)
+	require.NoError(t, store.WriteMessages(context.Background(), order("order-1"), order("order-2")))
+	require.NoError(t, store.WriteMessages(context.Background(), order("order-3")))
+	require.NoError(t, store.Close())
+
+	store, err = Open(path, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	require.Equal(t, 3, store.Backlog())
+
+	entries, err := store.Pending(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for i, id := range []string{"order-1", "order-2", "order-3"} {
+		require.Equal(t, order(id), entries[i].Msg)
+	}
+
+	require.NoError(t, store.MarkSent(entries[0].Seq, entries[0].Seq))
+	require.Equal(t, 2, store.Backlog(), "marking twice counts once")
+}
+
+func TestStoreBacklogLimitUnderConcurrentWrites(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "orders.db"), 5)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	var wg sync.WaitGroup
+	var accepted, rejected atomic.Int32
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.WriteMessages(context.Background(), order(fmt.Sprintf("order-%d", i)))
+			if errors.Is(err, ErrBacklogFull) {
+				rejected.Add(1)
+				return
+			}
+			require.NoError(t, err)
+			accepted.Add(1)
+		}()
+	}
+	wg.Wait()
+
+	require.EqualValues(t, 5, accepted.Load())
+	require.EqualValues(t, 15, rejected.Load())
+	require.Equal(t, 5, store.Backlog())
+	entries, err := store.Pending(100)
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+}
+
+func TestRelayPublishesAtLeastOnce(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "orders.db"), 3)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	kafkaDown := errors.New("kafka down")
+	producer := &fakeProducer{write: func([]kafka.Message) error { return kafkaDown }}
+	relay := NewRelay(store, producer, RelayConfig{BatchSize: 2, RetryInterval: time.Hour}, nil)
+
+	// Kafka недоступна — заказы принимаются и ждут в outbox
+	require.NoError(t, store.WriteMessages(ctx, order("order-1"), order("order-2"), order("order-3")))
+	require.ErrorIs(t, relay.Flush(ctx), kafkaDown)
+	require.Equal(t, 3, store.Backlog())
+	require.ErrorIs(t, store.WriteMessages(ctx, order("order-4")), ErrBacklogFull)
+	_, err = relay.Check().Probe(ctx)
+	require.ErrorIs(t, err, ErrBacklogFull)
+
+	// Kafka приняла только второе сообщение пачки — первое повторяется следующей публикацией
+	producer.write = func(msgs []kafka.Message) error {
+		if string(msgs[0].Key) == "order-1" && len(producer.sent) == 0 {
+			return kafka.WriteErrors{errors.New("not leader"), nil}
+		}
+		return nil
+	}
+	require.Error(t, relay.Flush(ctx))
+	require.Equal(t, 2, store.Backlog())
+	require.NoError(t, relay.Flush(ctx))
+	require.Zero(t, store.Backlog())
+	require.Equal(t, []string{"order-2", "order-1", "order-3"}, producer.keys())
+
+	detail, err := relay.Check().Probe(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "backlog 0", detail)
+}
+
+func TestRelayRunPublishesNewOrders(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "orders.db"), 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	producer := &fakeProducer{write: func([]kafka.Message) error { return nil }}
+	relay := NewRelay(store, producer, RelayConfig{BatchSize: 10, RetryInterval: time.Hour}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go relay.Run(ctx)
+
+	// публикация начинается по записи, не дожидаясь RetryInterval
+	require.NoError(t, store.WriteMessages(ctx, order("order-1")))
+	require.Eventually(t, func() bool { return store.Backlog() == 0 }, 2*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"order-1"}, producer.keys())
+}
@@ -0,0 +1,118 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/health"
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+	"github.com/go-portfolio/order-pipeline/internal/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+// Producer — запись в Kafka, как server.KafkaWriter
+type Producer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// RelayConfig задаёт публикацию из outbox
+type RelayConfig struct {
+	// BatchSize — сколько сообщений публиковать одним WriteMessages
+	BatchSize int
+	// RetryInterval — пауза после ошибки Kafka и период проверки outbox без новых записей
+	RetryInterval time.Duration
+}
+
+// Relay публикует сообщения из outbox в Kafka по порядку записи и удаляет опубликованные.
+// Падение между публикацией и удалением повторит публикацию: доставка не реже одного раза,
+// повторы отсеивает идемпотентность воркера.
+type Relay struct {
+	store    *Store
+	producer Producer
+	cfg      RelayConfig
+	metrics  *metrics.Metrics
+
+	// mu не даёт Run и Flush публиковать одно и то же одновременно
+	mu sync.Mutex
+}
+
+// NewRelay конструктор; m может быть nil
+func NewRelay(store *Store, producer Producer, cfg RelayConfig, m *metrics.Metrics) *Relay {
+	return &Relay{store: store, producer: producer, cfg: cfg, metrics: m}
+}
+
+// Run публикует outbox до отмены ctx: сразу после новых записей, а после ошибки — через RetryInterval
+func (r *Relay) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.store.added:
+		case <-timer.C:
+		}
+		if err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("publish from outbox failed, will retry", "backlog", r.store.Backlog(), "retry_in", r.cfg.RetryInterval, logging.KeyError, err)
+		}
+		timer.Reset(r.cfg.RetryInterval)
+	}
+}
+
+// Flush публикует пачками, пока outbox не опустеет; ошибка — Kafka не приняла очередную пачку
+func (r *Relay) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	defer func() { r.metrics.SetOutboxBacklog(r.store.Backlog()) }()
+	for {
+		entries, err := r.store.Pending(r.cfg.BatchSize)
+		if err != nil || len(entries) == 0 {
+			return err
+		}
+		if err := r.publish(ctx, entries); err != nil {
+			return err
+		}
+	}
+}
+
+// publish отправляет пачку и удаляет из outbox то, что приняла Kafka
+func (r *Relay) publish(ctx context.Context, entries []Entry) error {
+	msgs := make([]kafka.Message, len(entries))
+	for i, e := range entries {
+		msgs[i] = e.Msg
+	}
+	werr := r.producer.WriteMessages(ctx, msgs...)
+
+	// WriteErrors сообщает ошибку по каждому сообщению: принятые удаляются, остальные ждут повтора
+	var perItem kafka.WriteErrors
+	if werr != nil && (!errors.As(werr, &perItem) || len(perItem) != len(entries)) {
+		return werr
+	}
+	sent := make([]uint64, 0, len(entries))
+	for i, e := range entries {
+		if perItem == nil || perItem[i] == nil {
+			sent = append(sent, e.Seq)
+		}
+	}
+	if err := r.store.MarkSent(sent...); err != nil {
+		return err
+	}
+	return werr
+}
+
+// Check — проверка готовности с размером outbox; не готов, когда outbox переполнен
+func (r *Relay) Check() health.Check {
+	return health.Check{Name: "outbox", Probe: func(context.Context) (string, error) {
+		backlog := r.store.Backlog()
+		r.metrics.SetOutboxBacklog(backlog)
+		detail := fmt.Sprintf("backlog %d", backlog)
+		if r.store.maxBacklog > 0 && backlog >= r.store.maxBacklog {
+			return "", fmt.Errorf("%s: %w", detail, ErrBacklogFull)
+		}
+		return detail, nil
+	}}
+}
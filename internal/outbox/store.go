@@ -0,0 +1,202 @@
+// Package outbox — транзакционный outbox приёмника: заказ сначала надёжно записывается
+// в локальный файл bbolt, а Relay публикует его в Kafka позже, не реже одного раза.
+package outbox
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrBacklogFull — в outbox уже MaxBacklog неотправленных заказов
+var ErrBacklogFull = errors.New("outbox backlog is full")
+
+var (
+	// pendingBucket хранит неотправленные сообщения по возрастающему номеру
+	pendingBucket = []byte("pending")
+	// metaBucket хранит число неотправленных сообщений под backlogKey: оно меняется
+	// в тех же транзакциях, что и pendingBucket, поэтому предел MaxBacklog не обойти параллельными записями
+	metaBucket = []byte("meta")
+	backlogKey = []byte("backlog")
+)
+
+// record — сообщение Kafka в файле outbox
+type record struct {
+	Key       []byte         `json:"key"`
+	Value     []byte         `json:"value"`
+	Headers   []kafka.Header `json:"headers,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Entry — неотправленное сообщение и его номер в outbox
+type Entry struct {
+	Seq       uint64
+	Msg       kafka.Message
+	CreatedAt time.Time
+}
+
+// Store — файл outbox. WriteMessages делает его заменой KafkaWriter для приёма заказов:
+// запись завершается после fsync, а не после ответа Kafka.
+type Store struct {
+	db         *bolt.DB
+	maxBacklog int
+	// backlog — копия счётчика из файла для чтения без транзакции
+	backlog atomic.Int64
+	// added будит Relay после новой записи
+	added chan struct{}
+}
+
+// Open открывает или создаёт файл outbox; maxBacklog ограничивает число неотправленных заказов
+func Open(path string, maxBacklog int) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+	// файл занят другим процессом — ошибка через секунду, а не вечное ожидание
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("outbox: open %s: %w", path, err)
+	}
+	s := &Store{db: db, maxBacklog: maxBacklog, added: make(chan struct{}, 1)}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(pendingBucket)
+		if err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		// счётчик пересчитывается при открытии, чтобы файл без него или с ошибкой в нём не мешал
+		n := int64(b.Stats().KeyN)
+		s.backlog.Store(n)
+		return putBacklog(meta, n)
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+	return s, nil
+}
+
+// WriteMessages записывает сообщения одной транзакцией: после возврата без ошибки
+// они переживут падение процесса и будут опубликованы Relay
+func (s *Store) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	now := time.Now()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, meta := tx.Bucket(pendingBucket), tx.Bucket(metaBucket)
+		backlog := getBacklog(meta)
+		if s.maxBacklog > 0 && backlog+int64(len(msgs)) > int64(s.maxBacklog) {
+			return fmt.Errorf("%w: %d orders waiting for Kafka", ErrBacklogFull, backlog)
+		}
+		for _, msg := range msgs {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(record{Key: msg.Key, Value: msg.Value, Headers: msg.Headers, CreatedAt: now})
+			if err != nil {
+				return err
+			}
+			if err := b.Put(seqKey(seq), data); err != nil {
+				return err
+			}
+		}
+		return putBacklog(meta, backlog+int64(len(msgs)))
+	})
+	if errors.Is(err, ErrBacklogFull) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	s.backlog.Add(int64(len(msgs)))
+	select {
+	case s.added <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending возвращает до limit самых старых неотправленных сообщений
+func (s *Store) Pending(limit int) ([]Entry, error) {
+	var entries []Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(pendingBucket).Cursor()
+		for k, v := c.First(); k != nil && len(entries) < limit; k, v = c.Next() {
+			var r record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("entry %d: %w", binary.BigEndian.Uint64(k), err)
+			}
+			entries = append(entries, Entry{
+				Seq:       binary.BigEndian.Uint64(k),
+				Msg:       kafka.Message{Key: r.Key, Value: r.Value, Headers: r.Headers},
+				CreatedAt: r.CreatedAt,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+	return entries, nil
+}
+
+// MarkSent отмечает сообщения опубликованными: они удаляются из outbox
+func (s *Store) MarkSent(seqs ...uint64) error {
+	var removed int64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pendingBucket)
+		for _, seq := range seqs {
+			key := seqKey(seq)
+			if b.Get(key) == nil {
+				continue
+			}
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+			removed++
+		}
+		meta := tx.Bucket(metaBucket)
+		return putBacklog(meta, getBacklog(meta)-removed)
+	})
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	s.backlog.Add(-removed)
+	return nil
+}
+
+// Backlog — сколько заказов ждут публикации
+func (s *Store) Backlog() int {
+	return int(s.backlog.Load())
+}
+
+// Close закрывает файл; неотправленные сообщения будут опубликованы после следующего запуска
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func seqKey(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, seq)
+}
+
+func getBacklog(meta *bolt.Bucket) int64 {
+	v := meta.Get(backlogKey)
+	if len(v) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(v))
+}
+
+func putBacklog(meta *bolt.Bucket, n int64) error {
+	return meta.Put(backlogKey, binary.BigEndian.AppendUint64(nil, uint64(n)))
+}
@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"errors"
+
+	"github.com/go-portfolio/order-pipeline/internal/faults"
+)
+
+// Retryable помечает ошибку стадии как временную: заказ повторится через retry-топик
+func Retryable(err error) error {
+	return faults.AsRetryable(err)
+}
+
+// Permanent помечает ошибку стадии как постоянную: заказ сразу уйдёт в DLQ
+func Permanent(err error) error {
+	return faults.AsPermanent(err)
+}
+
+// skipError — стадия завершила заказ досрочно
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string { return e.reason }
+
+// Skip останавливает цепочку: заказ завершён без оставшихся стадий
+func Skip(reason string) error {
+	return &skipError{reason: reason}
+}
+
+// IsSkip сообщает, что цепочку остановила Skip
+func IsSkip(err error) bool {
+	var se *skipError
+	return errors.As(err, &se)
+}
+
+// Outcome — итог стадии для метрик и логов: ok, skip или класс ошибки
+func Outcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	if IsSkip(err) {
+		return "skip"
+	}
+	if kind, ok := faults.KindOf(err); ok {
+		return kind.String()
+	}
+	return faults.Retryable.String()
+}
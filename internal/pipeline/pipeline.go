@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/tracing"
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Order — заказ, который проходит через стадии обработки
+type Order struct {
+	// Request — заказ в том виде, в котором он пришёл из Kafka
+	Request *pb.OrderRequest
+	// Normalized — тот же заказ в формате версии 2, с ним работают стадии
+	Normalized *pb.OrderRequest
+	// Totals заполняет стадия price, налог — стадия tax
+	Totals *pb.OrderTotals
+	// Attempt — номер попытки обработки, начиная с 1
+	Attempt int
+}
+
+// Stage — одна стадия обработки заказа. Стадия возвращает заказ для следующей
+// стадии или ошибку: Retryable, Permanent, faults.AsRateLimited или Skip.
+// Ошибка без класса считается временной.
+type Stage interface {
+	Name() string
+	Process(ctx context.Context, o *Order) (*Order, error)
+}
+
+// Observer получает длительность и итог каждой стадии: ok, skip или класс ошибки
+type Observer func(stage string, d time.Duration, outcome string)
+
+// Pipeline выполняет стадии по порядку до первой ошибки
+type Pipeline struct {
+	stages  []Stage
+	observe Observer
+}
+
+// New конструктор цепочки стадий
+func New(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Observe подключает наблюдателя стадий, например метрики
+func (p *Pipeline) Observe(fn Observer) *Pipeline {
+	p.observe = fn
+	return p
+}
+
+// Stages возвращает имена стадий по порядку
+func (p *Pipeline) Stages() []string {
+	names := make([]string, len(p.stages))
+	for i, s := range p.stages {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+// Run прогоняет заказ через все стадии. При ошибке возвращает заказ после последней
+// успешной стадии и ошибку, дополненную именем стадии; тип ошибки сохраняется.
+// Skip останавливает цепочку: оставшиеся стадии не выполняются.
+// Каждая стадия пишет свой спан трассировки.
+func (p *Pipeline) Run(ctx context.Context, o *Order) (*Order, error) {
+	for _, s := range p.stages {
+		start := time.Now()
+		stageCtx, span := tracing.Tracer().Start(ctx, "stage "+s.Name())
+		next, err := s.Process(stageCtx, o)
+		outcome := Outcome(err)
+		span.SetAttributes(attribute.String("stage.outcome", outcome))
+		if !IsSkip(err) {
+			tracing.Fail(span, err)
+		}
+		span.End()
+		if p.observe != nil {
+			p.observe(s.Name(), time.Since(start), outcome)
+		}
+		if err != nil {
+			return o, fmt.Errorf("stage %s: %w", s.Name(), err)
+		}
+		if next != nil {
+			o = next
+		}
+	}
+	return o, nil
+}
@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-portfolio/order-pipeline/internal/faults"
+	"github.com/go-portfolio/order-pipeline/internal/validation"
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"github.com/stretchr/testify/require"
+)
+
+// stageFunc — стадия из функции для тестов
+type stageFunc struct {
+	name string
+	fn   func(*Order) error
+}
+
+func (s stageFunc) Name() string { return s.name }
+
+func (s stageFunc) Process(_ context.Context, o *Order) (*Order, error) {
+	return o, s.fn(o)
+}
+
+func requireKind(t *testing.T, want faults.Kind, err error) {
+	t.Helper()
+	kind, ok := faults.KindOf(err)
+	require.True(t, ok, "unclassified error: %v", err)
+	require.Equal(t, want, kind)
+}
+
+func newOrder(req *pb.OrderRequest) *Order {
+	return &Order{Request: req, Normalized: validation.Normalize(req, "USD"), Attempt: 1}
+}
+
+func TestPipelineStopsAtFirstErrorAndKeepsKind(t *testing.T) {
+	var ran []string
+	record := func(name string, err error) Stage {
+		return stageFunc{name: name, fn: func(*Order) error { ran = append(ran, name); return err }}
+	}
+
+	_, err := New(record("a", nil), record("b", Skip("test order")), record("c", nil)).Run(context.Background(), &Order{})
+	require.Equal(t, []string{"a", "b"}, ran)
+	require.True(t, IsSkip(err))
+	require.EqualError(t, err, "stage b: test order")
+
+	cause := errors.New("card declined")
+	_, err = New(record("d", Permanent(cause))).Run(context.Background(), &Order{})
+	requireKind(t, faults.Permanent, err)
+	require.ErrorIs(t, err, cause)
+}
+
+func TestDefaultStagesComputeTaxAndRejectFraud(t *testing.T) {
+	p, err := Build([]string{"validate", "price", "tax", "fraud"}, Deps{
+		TaxRatesBps:   map[string]int64{"DE": 1900},
+		FraudMaxTotal: 100_00,
+	})
+	require.NoError(t, err)
+
+	req := &pb.OrderRequest{
+		Id: "o-1", SchemaVersion: 2, Currency: "EUR",
+		LineItems:       []*pb.LineItem{{Sku: "book", Quantity: 3, UnitPrice: &pb.Money{MinorUnits: 333}}},
+		ShippingAddress: &pb.Address{Line1: "Main st 1", City: "Berlin", Country: "DE"},
+	}
+	o, err := p.Run(context.Background(), newOrder(req))
+	require.NoError(t, err)
+	require.Equal(t, int64(999), o.Totals.Total.MinorUnits)
+	// 19% от 9.99 = 1.8981 → 1.90
+	require.Equal(t, int64(190), o.Totals.Tax.MinorUnits)
+	require.Equal(t, int64(1189), o.Totals.GrandTotal.MinorUnits)
+
+	req.LineItems[0].Quantity = 100
+	_, err = p.Run(context.Background(), newOrder(req))
+	requireKind(t, faults.Permanent, err)
+	require.ErrorContains(t, err, "stage fraud")
+
+	// невалидный заказ не доходит до расчёта
+	_, err = p.Run(context.Background(), newOrder(&pb.OrderRequest{Id: "o-2"}))
+	requireKind(t, faults.Permanent, err)
+	require.ErrorContains(t, err, "stage validate")
+}
+
+func TestSimulateStageFailsTemporarily(t *testing.T) {
+	_, err := SimulateStage{}.Process(context.Background(), newOrder(&pb.OrderRequest{Id: "o-3", Item: "fail-item", Price: 1}))
+	requireKind(t, faults.Retryable, err)
+}
+
+func TestBuildRejectsBadChains(t *testing.T) {
+	_, err := Build([]string{"validate", "unknown"}, Deps{})
+	require.ErrorContains(t, err, "unknown pipeline stage")
+	_, err = Build([]string{"tax", "price"}, Deps{})
+	require.ErrorContains(t, err, "needs price")
+	_, err = Build([]string{"price", "price"}, Deps{})
+	require.ErrorContains(t, err, "listed twice")
+}
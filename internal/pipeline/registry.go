@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/validation"
+)
+
+// DefaultStages — цепочка стадий воркера по умолчанию
+var DefaultStages = []string{"validate", "price", "tax", "fraud", "simulate"}
+
+// DefaultSimulatedDelay — задержка стадии simulate в цепочке воркера
+const DefaultSimulatedDelay = 300 * time.Millisecond
+
+// Deps — зависимости и настройки стадий, из которых собирается цепочка
+type Deps struct {
+	Validator        *validation.Validator
+	TaxRatesBps      map[string]int64
+	FraudMaxTotal    int64
+	BlockedCustomers []string
+	SimulatedDelay   time.Duration
+}
+
+// builders — стадии, доступные в конфигурации по имени
+var builders = map[string]func(Deps) Stage{
+	"validate": func(d Deps) Stage { return ValidateStage{Validator: d.Validator} },
+	"price":    func(Deps) Stage { return PriceStage{} },
+	"tax":      func(d Deps) Stage { return TaxStage{RatesBps: d.TaxRatesBps} },
+	"fraud":    func(d Deps) Stage { return FraudStage{MaxTotal: d.FraudMaxTotal, BlockedCustomers: d.BlockedCustomers} },
+	"simulate": func(d Deps) Stage { return SimulateStage{Delay: d.SimulatedDelay} },
+}
+
+// Build собирает цепочку из стадий с именами names в заданном порядке
+func Build(names []string, deps Deps) (*Pipeline, error) {
+	if deps.Validator == nil {
+		deps.Validator = validation.MustDefault()
+	}
+	stages := make([]Stage, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		build, ok := builders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown pipeline stage %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("pipeline stage %q is listed twice", name)
+		}
+		if name == "tax" && !seen["price"] {
+			return nil, fmt.Errorf("pipeline stage tax needs price before it")
+		}
+		seen[name] = true
+		stages = append(stages, build(deps))
+	}
+	return New(stages...), nil
+}
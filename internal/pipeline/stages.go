@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/money"
+	"github.com/go-portfolio/order-pipeline/internal/validation"
+)
+
+// ValidateStage повторяет проверку приёмника: в топик могут писать и другие продюсеры
+type ValidateStage struct {
+	Validator *validation.Validator
+}
+
+func (ValidateStage) Name() string { return "validate" }
+
+func (s ValidateStage) Process(_ context.Context, o *Order) (*Order, error) {
+	if err := s.Validator.Validate(o.Request); err != nil {
+		return nil, Permanent(err)
+	}
+	return o, nil
+}
+
+// PriceStage считает стоимость строк и итог заказа
+type PriceStage struct{}
+
+func (PriceStage) Name() string { return "price" }
+
+func (PriceStage) Process(_ context.Context, o *Order) (*Order, error) {
+	totals, err := money.Totals(o.Normalized)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	o.Totals = totals
+	return o, nil
+}
+
+// TaxStage начисляет налог по стране доставки. Ставки задаются в базисных пунктах
+// (1900 = 19%), налог округляется до минимальной единицы валюты по правилу half-up.
+// Заказы без адреса или в страны без ставки облагаются по ставке 0.
+type TaxStage struct {
+	RatesBps map[string]int64
+}
+
+func (TaxStage) Name() string { return "tax" }
+
+func (s TaxStage) Process(_ context.Context, o *Order) (*Order, error) {
+	if o.Totals == nil || o.Totals.Total == nil {
+		return nil, Permanent(fmt.Errorf("order totals are not computed, put the price stage before tax"))
+	}
+	var rate int64
+	if a := o.Normalized.ShippingAddress; a != nil {
+		rate = s.RatesBps[a.Country]
+	}
+
+	tax, err := money.Percent(o.Totals.Total, rate)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	grand, err := money.Add(o.Totals.Total, tax)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	o.Totals.Tax = tax
+	o.Totals.GrandTotal = grand
+	return o, nil
+}
+
+// FraudStage отклоняет заказы заблокированных покупателей и заказы дороже MaxTotal
+// (в минимальных единицах валюты, 0 — без ограничения)
+type FraudStage struct {
+	MaxTotal         int64
+	BlockedCustomers []string
+}
+
+func (FraudStage) Name() string { return "fraud" }
+
+func (s FraudStage) Process(_ context.Context, o *Order) (*Order, error) {
+	for _, c := range s.BlockedCustomers {
+		if c != "" && c == o.Normalized.CustomerId {
+			return nil, Permanent(fmt.Errorf("customer %s is blocked", c))
+		}
+	}
+	if s.MaxTotal > 0 && o.Totals != nil {
+		total := o.Totals.GrandTotal
+		if total == nil {
+			total = o.Totals.Total
+		}
+		if total != nil && total.MinorUnits > s.MaxTotal {
+			return nil, Permanent(fmt.Errorf("order total %s exceeds fraud limit", money.Format(total)))
+		}
+	}
+	return o, nil
+}
+
+// SimulateStage имитирует работу внешней системы: ждёт Delay и даёт временный
+// сбой на строках с SKU, начинающимся на "fail"
+type SimulateStage struct {
+	Delay time.Duration
+}
+
+func (SimulateStage) Name() string { return "simulate" }
+
+func (s SimulateStage) Process(ctx context.Context, o *Order) (*Order, error) {
+	if s.Delay > 0 {
+		select {
+		case <-time.After(s.Delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	for _, li := range o.Normalized.LineItems {
+		if strings.HasPrefix(li.Sku, "fail") {
+			return nil, Retryable(fmt.Errorf("processing failed for item %q", li.Sku))
+		}
+	}
+	return o, nil
+}
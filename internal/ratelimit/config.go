@@ -0,0 +1,8 @@
+package ratelimit
+
+import "github.com/go-portfolio/order-pipeline/internal/config"
+
+// FromConfig — лимит из секции rate_limit конфигурации приёмника
+func FromConfig(cfg *config.RateLimit) Limit {
+	return Limit{Rate: cfg.Rate, Per: cfg.Per, Burst: cfg.Burst}
+}
@@ -0,0 +1,139 @@
+// Package ratelimit ограничивает частоту запросов каждого клиента корзиной токенов:
+// в памяти процесса или в Redis, чтобы лимит был общим для нескольких реплик.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/auth"
+	"github.com/go-portfolio/order-pipeline/internal/tlsutil"
+	"google.golang.org/grpc/peer"
+)
+
+// Limit — Rate запросов за Per в среднем и не больше Burst подряд
+type Limit struct {
+	Rate  int
+	Per   time.Duration
+	Burst int
+}
+
+// perSecond — скорость пополнения корзины
+func (l Limit) perSecond() float64 {
+	return float64(l.Rate) / l.Per.Seconds()
+}
+
+// Result — решение по запросу
+type Result struct {
+	Allowed bool
+	// Remaining — сколько токенов осталось в корзине
+	Remaining int
+	// RetryAfter — через сколько накопится нужное число токенов, если запрос отклонён
+	RetryAfter time.Duration
+}
+
+// ErrOverBurst — запрос просит больше токенов, чем вмещает корзина, и не пройдёт никогда
+var ErrOverBurst = errors.New("request exceeds the rate limit burst")
+
+// Limiter списывает n токенов из корзины клиента key
+type Limiter interface {
+	Take(ctx context.Context, key string, n int) (Result, error)
+	SetLimit(Limit)
+}
+
+// retryAfter — время, за которое в корзине прибавится missing токенов
+func retryAfter(missing float64, l Limit) time.Duration {
+	return time.Duration(math.Ceil(missing / l.perSecond() * float64(time.Second)))
+}
+
+// Local держит корзины в памяти процесса: у каждой реплики свой лимит
+type Local struct {
+	limit atomic.Pointer[Limit]
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// pruneEvery — раз в столько вызовов Take из памяти убираются полные корзины
+const pruneEvery = 1024
+
+// NewLocal конструктор
+func NewLocal(limit Limit) *Local {
+	l := &Local{now: time.Now, buckets: map[string]*bucket{}}
+	l.SetLimit(limit)
+	return l
+}
+
+// SetLimit меняет лимит для всех клиентов; накопленные токены сохраняются
+func (l *Local) SetLimit(limit Limit) {
+	l.limit.Store(&limit)
+}
+
+// Take списывает n токенов, если они есть
+func (l *Local) Take(_ context.Context, key string, n int) (Result, error) {
+	limit := *l.limit.Load()
+	if n > limit.Burst {
+		return Result{}, ErrOverBurst
+	}
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.takes++
+	if l.takes%pruneEvery == 0 {
+		l.prune(now, limit)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.perSecond())
+	b.last = now
+	if b.tokens < float64(n) {
+		return Result{Remaining: int(b.tokens), RetryAfter: retryAfter(float64(n)-b.tokens, limit)}, nil
+	}
+	b.tokens -= float64(n)
+	return Result{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+// prune убирает корзины, которые уже пополнились доверху: новая корзина будет такой же
+func (l *Local) prune(now time.Time, limit Limit) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*limit.perSecond() >= float64(limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// ClientKey — кто расходует лимит: проверенный вызывающий (API-ключ или sub токена),
+// иначе subject клиентского сертификата, иначе IP-адрес клиента
+func ClientKey(ctx context.Context) string {
+	if p := auth.FromContext(ctx); p != nil {
+		return "principal:" + p.Subject
+	}
+	if id, ok := tlsutil.ClientIdentity(ctx); ok {
+		return "cert:" + id.Subject
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "ip:" + host
+	}
+	return "unknown"
+}
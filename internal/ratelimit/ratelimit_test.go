@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// checkBucket прогоняет одинаковый сценарий для любой реализации: limiter и часы now общие
+func checkBucket(t *testing.T, limiter Limiter, clock *time.Time) {
+	t.Helper()
+	ctx := context.Background()
+
+	// burst 3 проходит сразу, четвёртый ждёт пополнения одного токена: 2 в секунду → 500ms
+	for i := range 3 {
+		res, err := limiter.Take(ctx, "billing", 1)
+		require.NoError(t, err)
+		require.True(t, res.Allowed, "request %d", i)
+		require.Equal(t, 2-i, res.Remaining)
+	}
+	res, err := limiter.Take(ctx, "billing", 1)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	// у другого клиента своя корзина
+	res, err = limiter.Take(ctx, "shop", 1)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+
+	*clock = clock.Add(500 * time.Millisecond)
+	res, err = limiter.Take(ctx, "billing", 1)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+
+	// пакет списывает столько токенов, сколько в нём заказов
+	*clock = clock.Add(time.Second)
+	res, err = limiter.Take(ctx, "billing", 3)
+	require.NoError(t, err)
+	require.False(t, res.Allowed, "only 2 tokens refilled")
+	require.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	_, err = limiter.Take(ctx, "billing", 4)
+	require.ErrorIs(t, err, ErrOverBurst)
+
+	// новый лимит действует сразу
+	limiter.SetLimit(Limit{Rate: 10, Per: time.Second, Burst: 3})
+	*clock = clock.Add(100 * time.Millisecond)
+	res, err = limiter.Take(ctx, "billing", 3)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+}
+
+func TestLocalTokenBucket(t *testing.T) {
+	clock := time.Unix(1_700_000_000, 0)
+	limiter := NewLocal(Limit{Rate: 2, Per: time.Second, Burst: 3})
+	limiter.now = func() time.Time { return clock }
+	checkBucket(t, limiter, &clock)
+}
+
+func TestRedisTokenBucketIsSharedAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	clock := time.Unix(1_700_000_000, 0)
+	limiter := NewRedis(rdb, "ratelimit:", Limit{Rate: 2, Per: time.Second, Burst: 3})
+	limiter.now = func() time.Time { return clock }
+	checkBucket(t, limiter, &clock)
+
+	// вторая реплика видит ту же корзину
+	other := NewRedis(rdb, "ratelimit:", Limit{Rate: 10, Per: time.Second, Burst: 3})
+	other.now = limiter.now
+	res, err := other.Take(context.Background(), "billing", 1)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.True(t, mr.Exists("ratelimit:billing"))
+}
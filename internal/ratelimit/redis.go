@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript пополняет и списывает корзину атомарно; время приходит от реплики,
+// а отставшие часы не откатывают корзину назад.
+// ARGV: токенов в миллисекунду, burst, сейчас в мс, n. Ответ: {пропущен, осталось, ждать мс}.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1])
+local ts = tonumber(b[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) * rate)
+  ts = now
+end
+local allowed = 0
+local wait = 0
+if tokens >= n then
+  tokens = tokens - n
+  allowed = 1
+else
+  wait = math.ceil((n - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+return {allowed, math.floor(tokens), wait}
+`)
+
+// Redis держит корзины в Redis под ключами <prefix><клиент>: лимит общий для всех реплик.
+// Корзина, пополнившаяся доверху, удаляется по TTL.
+type Redis struct {
+	rdb    redis.Scripter
+	prefix string
+	limit  atomic.Pointer[Limit]
+	now    func() time.Time
+}
+
+// NewRedis конструктор
+func NewRedis(rdb redis.Scripter, prefix string, limit Limit) *Redis {
+	r := &Redis{rdb: rdb, prefix: prefix, now: time.Now}
+	r.SetLimit(limit)
+	return r
+}
+
+// SetLimit меняет лимит для всех клиентов; накопленные токены сохраняются
+func (r *Redis) SetLimit(limit Limit) {
+	r.limit.Store(&limit)
+}
+
+// Take списывает n токенов, если они есть
+func (r *Redis) Take(ctx context.Context, key string, n int) (Result, error) {
+	limit := *r.limit.Load()
+	if n > limit.Burst {
+		return Result{}, ErrOverBurst
+	}
+	perMs := limit.perSecond() / 1000
+	reply, err := takeScript.Run(ctx, r.rdb, []string{r.prefix + key},
+		perMs, limit.Burst, r.now().UnixMilli(), n).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit: %w", err)
+	}
+	if len(reply) != 3 {
+		return Result{}, fmt.Errorf("rate limit: unexpected reply %v", reply)
+	}
+	return Result{
+		Allowed:    reply[0] == 1,
+		Remaining:  int(reply[1]),
+		RetryAfter: time.Duration(reply[2]) * time.Millisecond,
+	}, nil
+}
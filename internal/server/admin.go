@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// AdminServer — служебный HTTP-сервер сервиса: /metrics и другие эндпоинты эксплуатации
+type AdminServer struct {
+	mux *http.ServeMux
+	srv *http.Server
+}
+
+// NewAdminServer конструктор служебного сервера; /metrics отдаёт метрики из reg
+func NewAdminServer(addr string, reg prometheus.Gatherer) *AdminServer {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	return &AdminServer{
+		mux: mux,
+		srv: &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second},
+	}
+}
+
+// Handle добавляет эндпоинт
+func (a *AdminServer) Handle(pattern string, h http.Handler) {
+	a.mux.Handle(pattern, h)
+}
+
+// Run обслуживает запросы до отмены ctx. Пустой адрес отключает сервер.
+func (a *AdminServer) Run(ctx context.Context) error {
+	if a.srv.Addr == "" {
+		return nil
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- a.srv.ListenAndServe() }()
+	slog.Info("admin HTTP listening", "addr", a.srv.Addr)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
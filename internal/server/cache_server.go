@@ -3,6 +3,8 @@ package server
 import (
 	"context"
 
+	"github.com/go-portfolio/order-pipeline/internal/auth"
+	"github.com/go-portfolio/order-pipeline/internal/lifecycle"
 	pb "github.com/go-portfolio/order-pipeline/proto"
 	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc/codes"
@@ -12,7 +14,7 @@ import (
 
 // NewCacheServer конструктор для инициализации сервера с внедрением зависимостей
 func NewCacheServer(rdb RedisClient) pb.CacheServiceServer {
-	return &cacheServer{rdb: rdb}
+	return &cacheServer{rdb: rdb, watches: newWatchHub(rdb)}
 }
 
 // GetOrderResult обрабатывает запрос на получение результата заказа по ID
@@ -37,6 +39,49 @@ func (s *cacheServer) GetOrderResult(ctx context.Context, req *pb.ResultRequest)
 		return nil, status.Error(codes.Internal, "unmarshal error: "+err.Error())
 	}
 
+	// чужой заказ неотличим от несуществующего, чтобы не раскрывать занятые ID
+	if !auth.CanAccess(ctx, res.CustomerId) {
+		return nil, status.Error(codes.NotFound, "order not found")
+	}
+
 	// возвращаем результат
 	return &res, nil
 }
+
+// WatchOrder отправляет текущую запись о заказе, а затем каждое её изменение,
+// пока заказ не придёт в терминальное состояние или клиент не отменит вызов
+func (s *cacheServer) WatchOrder(req *pb.ResultRequest, stream pb.CacheService_WatchOrderServer) error {
+	ctx := stream.Context()
+
+	// подписываемся до чтения записи, чтобы не пропустить изменение между ними
+	events, cancel, err := s.watches.subscribe(ctx, req.Id)
+	if err != nil {
+		return status.Error(codes.Unavailable, "redis subscribe: "+err.Error())
+	}
+	defer cancel()
+
+	last, err := s.GetOrderResult(ctx, req)
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&pb.OrderEvent{Id: req.Id, Result: last}); err != nil {
+		return err
+	}
+
+	for !lifecycle.IsTerminal(last.State) {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case res := <-events:
+			// события, опубликованные до чтения записи, уже учтены в ней
+			if !lifecycle.Newer(last, res) {
+				continue
+			}
+			if err := stream.Send(&pb.OrderEvent{Id: req.Id, Result: res}); err != nil {
+				return err
+			}
+			last = res
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/auth"
+	"github.com/go-portfolio/order-pipeline/internal/lifecycle"
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startCacheServer поднимает CacheService в памяти и возвращает клиента к нему
+func startCacheServer(t *testing.T, srv pb.CacheServiceServer) pb.CacheServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterCacheServiceServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewCacheServiceClient(conn)
+}
+
+func TestWatchOrderStreamsUntilTerminalState(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	srv := NewCacheServer(rdb)
+	client := startCacheServer(t, srv)
+	states := lifecycle.NewStore(rdb)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, states.Transition(ctx, "order-1", &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_ACCEPTED, Item: "book"}))
+
+	stream, err := client.WatchOrder(ctx, &pb.ResultRequest{Id: "order-1"})
+	require.NoError(t, err)
+	ev, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, pb.OrderState_ORDER_STATE_ACCEPTED, ev.Result.State)
+
+	steps := []*pb.ResultResponse{
+		{State: pb.OrderState_ORDER_STATE_PROCESSING, Attempts: 1},
+		{State: pb.OrderState_ORDER_STATE_RETRYING, Attempts: 1, LastError: "boom"},
+		{State: pb.OrderState_ORDER_STATE_PROCESSING, Attempts: 2},
+		{State: pb.OrderState_ORDER_STATE_DONE, Attempts: 2},
+	}
+	for _, step := range steps {
+		require.NoError(t, states.Transition(ctx, "order-1", step))
+	}
+	for _, step := range steps {
+		ev, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, "order-1", ev.Id)
+		require.Equal(t, step.State, ev.Result.State)
+		require.Equal(t, step.Attempts, ev.Result.Attempts)
+	}
+
+	// после терминального состояния поток закрывается, а подписка Redis освобождается
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+	hub := srv.(*cacheServer).watches
+	require.Eventually(t, func() bool { return !hub.active() }, time.Second, 10*time.Millisecond)
+}
+
+func TestWatchOrderUnknownOrder(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	client := startCacheServer(t, NewCacheServer(rdb))
+
+	stream, err := client.WatchOrder(context.Background(), &pb.ResultRequest{Id: "missing"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGetOrderResultHidesOtherTenantsOrders(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	srv := NewCacheServer(rdb)
+	ctx := context.Background()
+	require.NoError(t, lifecycle.NewStore(rdb).Transition(ctx, "order-1",
+		&pb.ResultResponse{State: pb.OrderState_ORDER_STATE_ACCEPTED, CustomerId: "acme"}))
+
+	res, err := srv.GetOrderResult(auth.WithPrincipal(ctx, &auth.Principal{Subject: "svc", Tenant: "acme"}), &pb.ResultRequest{Id: "order-1"})
+	require.NoError(t, err)
+	require.Equal(t, "acme", res.CustomerId)
+
+	_, err = srv.GetOrderResult(auth.WithPrincipal(ctx, &auth.Principal{Subject: "svc", Tenant: "globex"}), &pb.ResultRequest{Id: "order-1"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = srv.GetOrderResult(auth.WithPrincipal(ctx, &auth.Principal{Subject: "ops", Admin: true}), &pb.ResultRequest{Id: "order-1"})
+	require.NoError(t, err)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/go-portfolio/order-pipeline/internal/ratelimit"
 	pb "github.com/go-portfolio/order-pipeline/proto"
 	"github.com/redis/go-redis/v9"
 	"github.com/segmentio/kafka-go"
@@ -22,12 +23,31 @@ type KafkaWriter interface {
 }
 
 type RedisClient interface {
+	redis.Scripter
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
 	Get(ctx context.Context, key string) *redis.StringCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Exists(ctx context.Context, keys ...string) *redis.IntCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	PSubscribe(ctx context.Context, channels ...string) *redis.PubSub
+	Ping(ctx context.Context) *redis.StatusCmd
+	Close() error
+}
+
+// RateLimiter ограничивает частоту заказов клиента; nil в OrderServerConfig — без ограничения
+type RateLimiter interface {
+	Take(ctx context.Context, key string, n int) (ratelimit.Result, error)
+}
+
+// OrderService — gRPC-сервис приёма заказов, параметры которого меняются на ходу
+type OrderService interface {
+	pb.OrderServiceServer
+	Apply(OrderSettings)
 }
 
 // cacheServer реализует gRPC-сервис CacheService и хранит подключение к Redis через интерфейс
 type cacheServer struct {
 	pb.UnimplementedCacheServiceServer
-	rdb RedisClient
+	rdb     RedisClient
+	watches *watchHub
 }
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"sync"
+)
+
+// limiter ограничивает число одновременно выполняемых обработчиков;
+// предел можно менять на ходу, уже начатая работа не прерывается
+type limiter struct {
+	mu     sync.Mutex
+	limit  int
+	active int
+	// changed закрывается, когда освобождается место или меняется предел
+	changed chan struct{}
+}
+
+func newLimiter(limit int) *limiter {
+	return &limiter{limit: max(limit, 1), changed: make(chan struct{})}
+}
+
+// acquire ждёт свободного места; ошибка — ctx отменён раньше
+func (l *limiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// release освобождает место, занятое acquire
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.notify()
+}
+
+// setLimit меняет предел; при уменьшении лишние обработчики дорабатывают текущую работу
+func (l *limiter) setLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = max(limit, 1)
+	l.notify()
+}
+
+// notify будит всех ожидающих acquire; вызывается под l.mu
+func (l *limiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -2,36 +2,390 @@ package server
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/go-portfolio/order-pipeline/internal/auth"
+	"github.com/go-portfolio/order-pipeline/internal/backpressure"
+	"github.com/go-portfolio/order-pipeline/internal/lifecycle"
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+	"github.com/go-portfolio/order-pipeline/internal/ratelimit"
+	"github.com/go-portfolio/order-pipeline/internal/validation"
 	pb "github.com/go-portfolio/order-pipeline/proto"
+	"github.com/redis/go-redis/v9"
 	"github.com/segmentio/kafka-go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
+// Состояния ключа идемпотентности заказа в Redis
+const (
+	requestPending  = "pending"
+	requestAccepted = "accepted"
+
+	// pendingTTL ограничивает жизнь незавершённого приёма, если процесс упал до записи в Kafka
+	pendingTTL = 30 * time.Second
+)
+
+// OrderServerConfig задаёт параметры приёма заказов
+type OrderServerConfig struct {
+	// DedupWindow — сколько помнить принятые ID, чтобы не публиковать повторы
+	DedupWindow time.Duration
+	// Key выбирает ключ сообщения Kafka; по умолчанию ID заказа
+	Key KeyFunc
+	// Validator проверяет заказ до публикации; по умолчанию правила validation.DefaultRules
+	Validator *validation.Validator
+	// Limiter ограничивает частоту заказов каждого клиента; nil — без ограничения
+	Limiter RateLimiter
+}
+
+// OrderSettings — параметры приёма заказов, которые меняются на ходу через Apply
+type OrderSettings struct {
+	DedupWindow time.Duration
+	Validator   *validation.Validator
+}
 
 // orderServer реализует gRPC-сервис OrderService и хранит Kafka writer через интерфейс
 type orderServer struct {
 	pb.UnimplementedOrderServiceServer
 	writer KafkaWriter
+	rdb    RedisClient
+	states *lifecycle.Store
+	key    KeyFunc
+	limit  RateLimiter
+	// settings меняются через Apply; запрос обрабатывается с одним снимком
+	settings atomic.Pointer[OrderSettings]
 }
 
-// NewOrderServer конструктор для инициализации сервера с внедрением зависимости
-func NewOrderServer(writer KafkaWriter) pb.OrderServiceServer {
-	return &orderServer{writer: writer}
+// NewOrderServer конструктор для инициализации сервера с внедрением зависимостей.
+// Повторный CreateOrder с тем же ID в пределах cfg.DedupWindow не публикуется заново.
+func NewOrderServer(writer KafkaWriter, rdb RedisClient, cfg OrderServerConfig) OrderService {
+	if cfg.Key == nil {
+		cfg.Key = OrderIDKey
+	}
+	if cfg.Validator == nil {
+		cfg.Validator = validation.MustDefault()
+	}
+	s := &orderServer{writer: writer, rdb: rdb, states: lifecycle.NewStore(rdb), key: cfg.Key, limit: cfg.Limiter}
+	s.settings.Store(&OrderSettings{DedupWindow: cfg.DedupWindow, Validator: cfg.Validator})
+	return s
+}
+
+// Apply меняет параметры приёма без перезапуска; начатые запросы дорабатывают со старыми
+func (s *orderServer) Apply(settings OrderSettings) {
+	if settings.Validator == nil {
+		settings.Validator = s.settings.Load().Validator
+	}
+	s.settings.Store(&settings)
 }
 
-// CreateOrder обрабатывает запрос на создание нового заказа
+// maxBatchSize ограничивает число заказов в одном CreateOrdersBatch
+const maxBatchSize = 1000
+
+// claim — заказ, за которым закреплён ID и который ещё предстоит опубликовать
+type claim struct {
+	req         *pb.OrderRequest
+	key         string
+	fingerprint string
+	msg         kafka.Message
+}
+
+// CreateOrder обрабатывает запрос на создание нового заказа.
+// Тот же ID с тем же содержимым возвращает исходный ответ, с другим — AlreadyExists.
+// Некорректный заказ отклоняется с InvalidArgument и списком нарушений в errdetails.BadRequest.
 func (s *orderServer) CreateOrder(ctx context.Context, req *pb.OrderRequest) (*pb.OrderResponse, error) {
-	b, err := proto.Marshal(req)
+	if err := s.throttle(ctx, 1); err != nil {
+		return nil, err
+	}
+	if err := s.settings.Load().Validator.Validate(req); err != nil {
+		return nil, err
+	}
+	if err := checkCustomer(ctx, req); err != nil {
+		return nil, err
+	}
+
+	c, dup, err := s.claim(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	if dup {
+		return &pb.OrderResponse{Status: "accepted"}, nil
+	}
+
+	if err := s.writer.WriteMessages(ctx, c.msg); err != nil {
+		if relErr := s.release(ctx, c, err); relErr != nil {
+			err = errors.Join(err, relErr)
+		}
+		return nil, publishError(err)
+	}
 
-	msg := kafka.Message{Value: b}
+	s.confirm(ctx, c)
+	return &pb.OrderResponse{Status: "accepted"}, nil
+}
 
-	if err := s.writer.WriteMessages(ctx, msg); err != nil {
+// CreateOrdersBatch принимает пакет заказов и публикует новые одним вызовом WriteMessages.
+// Ошибка одного заказа не отменяет остальные: итог возвращается по каждому.
+func (s *orderServer) CreateOrdersBatch(ctx context.Context, req *pb.BatchOrderRequest) (*pb.BatchOrderResponse, error) {
+	if len(req.Orders) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch has %d orders, at most %d allowed", len(req.Orders), maxBatchSize)
+	}
+	// пакет расходует лимит клиента целиком: по токену на заказ
+	if err := s.throttle(ctx, len(req.Orders)); err != nil {
 		return nil, err
 	}
 
-	return &pb.OrderResponse{Status: "accepted"}, nil
+	// весь пакет проверяется одними правилами, даже если их поменяют посреди запроса
+	validator := s.settings.Load().Validator
+	results := make([]*pb.BatchItemResult, len(req.Orders))
+	var claims []*claim
+	var pending []int // индексы results для claims
+	seen := map[string]int{}
+
+	for i, order := range req.Orders {
+		results[i] = &pb.BatchItemResult{Id: order.Id}
+
+		if err := validator.Validate(order); err != nil {
+			rejectItem(results[i], err)
+			continue
+		}
+		if err := checkCustomer(ctx, order); err != nil {
+			rejectItem(results[i], err)
+			continue
+		}
+
+		// повтор ID внутри пакета сравниваем с первым вхождением, не трогая Redis
+		if first, ok := seen[order.Id]; ok {
+			if proto.Equal(req.Orders[first], order) {
+				results[i].Status = pb.BatchItemStatus_BATCH_ITEM_STATUS_DUPLICATE
+			} else {
+				rejectItem(results[i], status.Error(codes.AlreadyExists, "order with this id appears in the batch with different content"))
+			}
+			continue
+		}
+		seen[order.Id] = i
+
+		c, dup, err := s.claim(ctx, order)
+		switch {
+		case err != nil:
+			rejectItem(results[i], err)
+		case dup:
+			results[i].Status = pb.BatchItemStatus_BATCH_ITEM_STATUS_DUPLICATE
+		default:
+			claims = append(claims, c)
+			pending = append(pending, i)
+		}
+	}
+
+	if len(claims) == 0 {
+		return &pb.BatchOrderResponse{Results: results}, nil
+	}
+
+	msgs := make([]kafka.Message, len(claims))
+	for i, c := range claims {
+		msgs[i] = c.msg
+	}
+	werr := s.writer.WriteMessages(ctx, msgs...)
+
+	// WriteErrors сообщает ошибку по каждому сообщению, любая другая ошибка относится ко всем
+	var perItem kafka.WriteErrors
+	if !errors.As(werr, &perItem) || len(perItem) != len(claims) {
+		perItem = nil
+	}
+
+	for n, c := range claims {
+		res := results[pending[n]]
+		err := werr
+		if perItem != nil {
+			err = perItem[n]
+		}
+		if err == nil {
+			s.confirm(ctx, c)
+			res.Status = pb.BatchItemStatus_BATCH_ITEM_STATUS_ACCEPTED
+			continue
+		}
+		if relErr := s.release(ctx, c, err); relErr != nil {
+			err = errors.Join(err, relErr)
+		}
+		rejectItem(res, publishError(err))
+	}
+
+	return &pb.BatchOrderResponse{Results: results}, nil
+}
+
+// claim закрепляет ID заказа за этим запросом и записывает состояние ACCEPTED.
+// dup = true, если заказ с тем же содержимым уже принят; ошибки — gRPC-статусы.
+func (s *orderServer) claim(ctx context.Context, req *pb.OrderRequest) (*claim, bool, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fingerprint := payloadFingerprint(b)
+	key := requestKey(req.Id)
+
+	claimed, err := s.rdb.SetNX(ctx, key, requestPending+":"+fingerprint, pendingTTL).Result()
+	if err != nil {
+		return nil, false, status.Error(codes.Unavailable, "redis error: "+err.Error())
+	}
+	if !claimed {
+		if err := s.duplicate(ctx, key, fingerprint); err != nil {
+			return nil, false, err
+		}
+		return nil, true, nil
+	}
+
+	// состояние ACCEPTED пишется до публикации: воркер может взять заказ раньше, чем мы ответим
+	accepted := &pb.ResultResponse{State: pb.OrderState_ORDER_STATE_ACCEPTED, Item: req.Item, Price: req.Price, CustomerId: req.CustomerId}
+	if err := s.states.Transition(ctx, req.Id, accepted); err != nil {
+		// ключ идемпотентности освобождаем, запись о заказе не трогаем — она принадлежит прежнему заказу
+		s.rdb.Del(context.WithoutCancel(ctx), key)
+		var terr *lifecycle.TransitionError
+		if errors.As(err, &terr) {
+			return nil, false, status.Errorf(codes.AlreadyExists, "order %s is already %s", req.Id, lifecycle.StatusName(terr.From))
+		}
+		return nil, false, status.Error(codes.Unavailable, "redis error: "+err.Error())
+	}
+
+	return &claim{
+		req:         req,
+		key:         key,
+		fingerprint: fingerprint,
+		// ключ закрепляет все события заказа за одной партицией
+		msg: kafka.Message{Key: s.key(req), Value: b},
+	}, false, nil
+}
+
+// release освобождает ID неопубликованного заказа, чтобы повтор клиента мог его опубликовать.
+// После таймаута запись в Kafka могла состояться: ключ pending остаётся до истечения pendingTTL,
+// иначе повтор опубликует заказ второй раз. Запись о заказе удаляется, только пока он в ACCEPTED —
+// воркер мог уже получить сообщение и продвинуть заказ дальше.
+func (s *orderServer) release(ctx context.Context, c *claim, werr error) error {
+	if publishTimedOut(werr) {
+		slog.WarnContext(ctx, "order publish timed out, keeping its id claimed until pending ttl", logging.KeyOrderID, c.req.Id, "pending_ttl", pendingTTL)
+		return nil
+	}
+	ctx = context.WithoutCancel(ctx)
+	if _, err := s.states.DeleteAccepted(ctx, c.req.Id); err != nil {
+		return err
+	}
+	return s.rdb.Del(ctx, c.key).Err()
+}
+
+// publishTimedOut сообщает, что запись в Kafka прервана по таймауту и её итог неизвестен
+func publishTimedOut(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, backpressure.ErrProduceTimeout) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// confirm помечает опубликованный заказ принятым. Заказ уже в Kafka, поэтому
+// ошибка только логируется: ключ pending сам истечёт.
+func (s *orderServer) confirm(ctx context.Context, c *claim) {
+	if err := s.rdb.Set(ctx, c.key, requestAccepted+":"+c.fingerprint, s.settings.Load().DedupWindow).Err(); err != nil {
+		slog.WarnContext(ctx, "mark order accepted", logging.KeyOrderID, c.req.Id, logging.KeyError, err)
+	}
+}
+
+// publishError — ошибка записи в Kafka для клиента. Это всегда Unavailable: ID заказа освобождается
+// (после таймаута — по истечении pendingTTL), и повтор безопасен, в том числе после быстрого отказа
+// из-за перегрузки или разомкнутого предохранителя.
+func publishError(err error) error {
+	return status.Error(codes.Unavailable, "publish order: "+err.Error())
+}
+
+// RetryAfterHeader — заголовок ответа с числом секунд до повтора отклонённого по лимиту запроса
+const RetryAfterHeader = "retry-after"
+
+// throttle списывает n заказов из лимита клиента. Превышение — ResourceExhausted с заголовком
+// retry-after и errdetails.RetryInfo. Недоступный Redis лимита не останавливает приём.
+func (s *orderServer) throttle(ctx context.Context, n int) error {
+	if s.limit == nil {
+		return nil
+	}
+	client := ratelimit.ClientKey(ctx)
+	res, err := s.limit.Take(ctx, client, n)
+	if errors.Is(err, ratelimit.ErrOverBurst) {
+		return status.Errorf(codes.ResourceExhausted, "%d orders exceed the rate limit burst, send smaller batches", n)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "rate limiter unavailable, accepting without limit", logging.KeyError, err)
+		return nil
+	}
+	if res.Allowed {
+		return nil
+	}
+
+	slog.WarnContext(ctx, "rate limit exceeded", "rate_client", client, "retry_after", res.RetryAfter)
+	seconds := int(math.Ceil(res.RetryAfter.Seconds()))
+	// SetHeader не работает вне gRPC-вызова (в тестах) — тогда остаётся RetryInfo
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RetryAfterHeader, strconv.Itoa(seconds)))
+	st, detailErr := status.New(codes.ResourceExhausted, fmt.Sprintf("rate limit exceeded, retry in %s", res.RetryAfter)).
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(res.RetryAfter)})
+	if detailErr != nil {
+		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", res.RetryAfter)
+	}
+	return st.Err()
+}
+
+// checkCustomer не даёт вызывающему создать заказ от имени чужого покупателя
+func checkCustomer(ctx context.Context, req *pb.OrderRequest) error {
+	if !auth.CanAccess(ctx, req.CustomerId) {
+		return status.Errorf(codes.PermissionDenied, "caller may not create orders for customer %q", req.CustomerId)
+	}
+	return nil
+}
+
+// rejectItem заполняет итог отклонённого заказа по gRPC-ошибке
+func rejectItem(res *pb.BatchItemResult, err error) {
+	st := status.Convert(err)
+	res.Status = pb.BatchItemStatus_BATCH_ITEM_STATUS_REJECTED
+	res.Code = int32(st.Code())
+	res.Reason = st.Message()
+}
+
+// duplicate проверяет повторный запрос с уже занятым ID: nil, если это тот же принятый заказ
+func (s *orderServer) duplicate(ctx context.Context, key, fingerprint string) error {
+	val, err := s.rdb.Get(ctx, key).Result()
+	if err == redis.Nil {
+		// первый запрос не смог опубликовать заказ и освободил ID
+		return status.Error(codes.Aborted, "concurrent request for the same order failed, retry")
+	} else if err != nil {
+		return status.Error(codes.Unavailable, "redis error: "+err.Error())
+	}
+
+	state, stored, _ := strings.Cut(val, ":")
+	if stored != fingerprint {
+		return status.Error(codes.AlreadyExists, "order with this id already exists with different content")
+	}
+	if state == requestPending {
+		return status.Error(codes.Aborted, "order with this id is still being accepted, retry")
+	}
+	return nil
+}
+
+// requestKey — ключ идемпотентности приёма заказа
+func requestKey(id string) string {
+	return "order-request:" + id
+}
+
+// payloadFingerprint — отпечаток содержимого заказа для сравнения повторов
+func payloadFingerprint(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-portfolio/order-pipeline/internal/auth"
+	"github.com/go-portfolio/order-pipeline/internal/backpressure"
+	"github.com/go-portfolio/order-pipeline/internal/lifecycle"
+	"github.com/go-portfolio/order-pipeline/internal/ratelimit"
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestRedis поднимает Redis в памяти на время теста
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return mr, rdb
+}
+
+func TestCreateOrderIsIdempotent(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	writer := &fakeWriter{}
+	srv := NewOrderServer(writer, rdb, OrderServerConfig{DedupWindow: time.Hour})
+	ctx := context.Background()
+
+	req := &pb.OrderRequest{Id: "order-1", Item: "book", Price: 42}
+	resp, err := srv.CreateOrder(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, "accepted", resp.Status)
+	require.Equal(t, []byte("order-1"), writer.msgs[0].Key)
+	require.Equal(t, pb.OrderState_ORDER_STATE_ACCEPTED, readState(t, mr, "order-1").State)
+
+	// повтор с тем же содержимым получает исходный ответ и не публикуется заново
+	resp, err = srv.CreateOrder(ctx, &pb.OrderRequest{Id: "order-1", Item: "book", Price: 42})
+	require.NoError(t, err)
+	require.Equal(t, "accepted", resp.Status)
+	require.Len(t, writer.msgs, 1)
+
+	// тот же ID с другим содержимым отклоняется
+	_, err = srv.CreateOrder(ctx, &pb.OrderRequest{Id: "order-1", Item: "book", Price: 43})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+	require.Len(t, writer.msgs, 1)
+}
+
+func TestCreateOrderOnlyForCallersCustomer(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	writer := &fakeWriter{}
+	srv := NewOrderServer(writer, rdb, OrderServerConfig{DedupWindow: time.Hour})
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "billing", Tenant: "acme"})
+
+	_, err := srv.CreateOrder(ctx, &pb.OrderRequest{Id: "order-1", Item: "book", Price: 42, CustomerId: "globex"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	require.Empty(t, writer.msgs)
+
+	_, err = srv.CreateOrder(ctx, &pb.OrderRequest{Id: "order-1", Item: "book", Price: 42, CustomerId: "acme"})
+	require.NoError(t, err)
+	require.Len(t, writer.msgs, 1)
+}
+
+func TestCreateOrderReleasesIDWhenKafkaFails(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	writer := &fakeWriter{err: errors.New("kafka down")}
+	srv := NewOrderServer(writer, rdb, OrderServerConfig{DedupWindow: time.Hour})
+	ctx := context.Background()
+
+	req := &pb.OrderRequest{Id: "order-2", Item: "pen", Price: 5}
+	_, err := srv.CreateOrder(ctx, req)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+
+	// после восстановления Kafka повтор клиента проходит
+	writer.err = nil
+	resp, err := srv.CreateOrder(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, "accepted", resp.Status)
+	require.Len(t, writer.msgs, 1)
+}
+
+func TestCreateOrderKeepsWorkerStateWhenPublishFails(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	req := &pb.OrderRequest{Id: "order-3", Item: "pen", Price: 5}
+	// Kafka записала сообщение, но ответила ошибкой, а воркер успел взять заказ
+	writer := &hookWriter{write: func() error {
+		require.NoError(t, lifecycle.NewStore(rdb).Transition(context.Background(), req.Id,
+			&pb.ResultResponse{State: pb.OrderState_ORDER_STATE_PROCESSING, Attempts: 1}))
+		return errors.New("connection reset")
+	}}
+	srv := NewOrderServer(writer, rdb, OrderServerConfig{DedupWindow: time.Hour})
+
+	_, err := srv.CreateOrder(context.Background(), req)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, pb.OrderState_ORDER_STATE_PROCESSING, readState(t, mr, req.Id).State)
+}
+
+func TestCreateOrderKeepsClaimAfterPublishTimeout(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	writer := &hookWriter{write: func() error {
+		return fmt.Errorf("%w after 1s: %w", backpressure.ErrProduceTimeout, context.DeadlineExceeded)
+	}}
+	srv := NewOrderServer(writer, rdb, OrderServerConfig{DedupWindow: time.Hour})
+	ctx := context.Background()
+
+	req := &pb.OrderRequest{Id: "order-4", Item: "pen", Price: 5}
+	_, err := srv.CreateOrder(ctx, req)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+
+	// итог записи неизвестен — повтор ждёт истечения pending, а не публикует заказ второй раз
+	writer.write = func() error { return nil }
+	_, err = srv.CreateOrder(ctx, req)
+	require.Equal(t, codes.Aborted, status.Code(err))
+	require.Equal(t, pb.OrderState_ORDER_STATE_ACCEPTED, readState(t, mr, req.Id).State)
+
+	mr.FastForward(pendingTTL)
+	_, err = srv.CreateOrder(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, 2, writer.calls)
+}
+
+// hookWriter вызывает write на каждую запись
+type hookWriter struct {
+	fakeWriter
+	write func() error
+	calls int
+}
+
+func (w *hookWriter) WriteMessages(_ context.Context, _ ...kafka.Message) error {
+	w.calls++
+	return w.write()
+}
+
+// partialWriter отклоняет сообщения с ключом из failKeys, как kafka.Writer при частичном сбое
+type partialWriter struct {
+	fakeWriter
+	failKeys map[string]bool
+	calls    int
+}
+
+func (w *partialWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.calls++
+	errs := make(kafka.WriteErrors, len(msgs))
+	var ok []kafka.Message
+	failed := false
+	for i, m := range msgs {
+		if w.failKeys[string(m.Key)] {
+			errs[i] = errors.New("partition leader unavailable")
+			failed = true
+			continue
+		}
+		ok = append(ok, m)
+	}
+	w.fakeWriter.WriteMessages(ctx, ok...)
+	if failed {
+		return errs
+	}
+	return nil
+}
+
+func TestCreateOrdersBatchReportsEachItem(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	writer := &partialWriter{failKeys: map[string]bool{"b-3": true}}
+	srv := NewOrderServer(writer, rdb, OrderServerConfig{DedupWindow: time.Hour})
+	ctx := context.Background()
+
+	_, err := srv.CreateOrder(ctx, &pb.OrderRequest{Id: "b-0", Item: "pen", Price: 1})
+	require.NoError(t, err)
+
+	resp, err := srv.CreateOrdersBatch(ctx, &pb.BatchOrderRequest{Orders: []*pb.OrderRequest{
+		{Id: "b-1", Item: "book", Price: 10},
+		{Id: "b-0", Item: "pen", Price: 1},  // уже принят
+		{Id: "b-0", Item: "pen", Price: 2},  // тот же ID, другое содержимое
+		{Id: "b-3", Item: "lamp", Price: 7}, // Kafka отклонит
+		{Id: "b-1", Item: "book", Price: 10},
+	}})
+	require.NoError(t, err)
+
+	var got []pb.BatchItemStatus
+	for _, r := range resp.Results {
+		got = append(got, r.Status)
+	}
+	require.Equal(t, []pb.BatchItemStatus{
+		pb.BatchItemStatus_BATCH_ITEM_STATUS_ACCEPTED,
+		pb.BatchItemStatus_BATCH_ITEM_STATUS_DUPLICATE,
+		pb.BatchItemStatus_BATCH_ITEM_STATUS_REJECTED,
+		pb.BatchItemStatus_BATCH_ITEM_STATUS_REJECTED,
+		pb.BatchItemStatus_BATCH_ITEM_STATUS_DUPLICATE,
+	}, got)
+	require.Equal(t, int32(codes.AlreadyExists), resp.Results[2].Code)
+	require.Equal(t, int32(codes.Unavailable), resp.Results[3].Code)
+	require.Contains(t, resp.Results[3].Reason, "partition leader unavailable")
+
+	// новые заказы ушли одним вызовом, неопубликованный освободил ID
+	require.Equal(t, 2, writer.calls)
+	require.Len(t, writer.msgs, 2)
+	require.False(t, mr.Exists(requestKey("b-3")))
+	require.False(t, mr.Exists(lifecycle.Key("b-3")))
+}
+
+func TestCreateOrderRejectsInvalidOrder(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	writer := &fakeWriter{}
+	srv := NewOrderServer(writer, rdb, OrderServerConfig{DedupWindow: time.Hour})
+
+	_, err := srv.CreateOrder(context.Background(), &pb.OrderRequest{Id: "order-9", Item: "book", Price: 0})
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	br := st.Details()[0].(*errdetails.BadRequest)
+	require.Equal(t, "price", br.FieldViolations[0].Field)
+	require.Empty(t, writer.msgs)
+}
+
+func TestCreateOrderOverRateLimitReturnsRetryAfter(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	writer := &fakeWriter{}
+	srv := NewOrderServer(writer, rdb, OrderServerConfig{
+		DedupWindow: time.Hour,
+		Limiter:     ratelimit.NewLocal(ratelimit.Limit{Rate: 1, Per: time.Minute, Burst: 2}),
+	})
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterOrderServiceServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := pb.NewOrderServiceClient(conn)
+	ctx := context.Background()
+
+	_, err = client.CreateOrder(ctx, &pb.OrderRequest{Id: "order-1", Item: "book", Price: 42})
+	require.NoError(t, err)
+	_, err = client.CreateOrder(ctx, &pb.OrderRequest{Id: "order-2", Item: "book", Price: 42})
+	require.NoError(t, err)
+
+	var header metadata.MD
+	_, err = client.CreateOrder(ctx, &pb.OrderRequest{Id: "order-3", Item: "book", Price: 42}, grpc.Header(&header))
+	st := status.Convert(err)
+	require.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Equal(t, []string{"60"}, header.Get(RetryAfterHeader))
+	require.Len(t, st.Details(), 1)
+	require.InDelta(t, time.Minute, st.Details()[0].(*errdetails.RetryInfo).RetryDelay.AsDuration(), float64(time.Second))
+	require.Len(t, writer.msgs, 2)
+
+	// пакет больше burst не пройдёт никогда
+	_, err = client.CreateOrdersBatch(ctx, &pb.BatchOrderRequest{Orders: make([]*pb.OrderRequest, 3)})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"fmt"
+
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"github.com/segmentio/kafka-go"
+)
+
+// KeyFunc выбирает ключ сообщения Kafka для заказа.
+// Сообщения с одинаковым ключом попадают в одну партицию и обрабатываются по порядку.
+type KeyFunc func(order *pb.OrderRequest) []byte
+
+// OrderIDKey — ключ по ID заказа
+func OrderIDKey(order *pb.OrderRequest) []byte {
+	return []byte(order.Id)
+}
+
+// CustomerIDKey — ключ по покупателю: все заказы покупателя обрабатываются по порядку.
+// Заказ без покупателя получает ключ по своему ID.
+func CustomerIDKey(order *pb.OrderRequest) []byte {
+	if order.CustomerId == "" {
+		return OrderIDKey(order)
+	}
+	return []byte(order.CustomerId)
+}
+
+// keyFuncs — доступные стратегии выбора ключа
+var keyFuncs = map[string]KeyFunc{
+	"order_id":    OrderIDKey,
+	"customer_id": CustomerIDKey,
+}
+
+// KeyFuncByName возвращает стратегию выбора ключа по имени из конфигурации
+func KeyFuncByName(name string) (KeyFunc, error) {
+	fn, ok := keyFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown partition key %q", name)
+	}
+	return fn, nil
+}
+
+// BalancerByName возвращает партиционер Kafka по имени из конфигурации:
+// hash (FNV-1a), murmur2 (совместим с Java-клиентом) или crc32 (совместим с librdkafka)
+func BalancerByName(name string) (kafka.Balancer, error) {
+	switch name {
+	case "hash":
+		return &kafka.Hash{}, nil
+	case "murmur2":
+		return kafka.Murmur2Balancer{}, nil
+	case "crc32":
+		return kafka.CRC32Balancer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown partitioner %q", name)
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки Kafka, которые воркер добавляет к повторно отправленным сообщениям
+const (
+	retriesHeader   = "retries"
+	notBeforeHeader = "not-before"
+)
+
+// RetryPolicy описывает повторные попытки обработки заказа.
+// Каждой задержке из Backoff соответствует отдельный retry-топик, например
+// orders-retry-1s, orders-retry-30s, orders-retry-5m. Попытки сверх числа
+// задержек используют последнюю из них.
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    []time.Duration
+}
+
+// Delay возвращает задержку перед попыткой attempt (начиная с 1)
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if len(p.Backoff) == 0 {
+		return 0
+	}
+	i := attempt - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(p.Backoff) {
+		i = len(p.Backoff) - 1
+	}
+	return p.Backoff[i]
+}
+
+// TopicFor возвращает retry-топик для попытки attempt
+func (p RetryPolicy) TopicFor(mainTopic string, attempt int) string {
+	if len(p.Backoff) == 0 {
+		return mainTopic
+	}
+	return retryTopic(mainTopic, p.Delay(attempt))
+}
+
+// Topics возвращает все retry-топики политики без повторов
+func (p RetryPolicy) Topics(mainTopic string) []string {
+	var topics []string
+	seen := map[string]bool{}
+	for _, d := range p.Backoff {
+		t := retryTopic(mainTopic, d)
+		if !seen[t] {
+			seen[t] = true
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
+// retryTopic строит имя retry-топика вида orders-retry-30s
+func retryTopic(mainTopic string, delay time.Duration) string {
+	return mainTopic + "-retry-" + durationLabel(delay)
+}
+
+// durationLabel форматирует задержку коротко: 5m вместо 5m0s
+func durationLabel(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d >= time.Minute && d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	default:
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	}
+}
+
+// notBefore возвращает момент, раньше которого сообщение нельзя обрабатывать
+func notBefore(msg kafka.Message) (time.Time, bool) {
+	v, ok := headerValue(msg, notBeforeHeader)
+	if !ok {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+// headerValue возвращает значение заголовка без учёта регистра ключа
+func headerValue(msg kafka.Message, key string) (string, bool) {
+	for _, h := range msg.Headers {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
+
+// deleteHeader убирает заголовок, не изменяя исходный срез
+func deleteHeader(headers []kafka.Header, key string) []kafka.Header {
+	out := make([]kafka.Header, 0, len(headers))
+	for _, h := range headers {
+		if !strings.EqualFold(h.Key, key) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// setHeader заменяет или добавляет заголовок, не изменяя исходный срез
+func setHeader(headers []kafka.Header, key, value string) []kafka.Header {
+	out := make([]kafka.Header, 0, len(headers)+1)
+	found := false
+	for _, h := range headers {
+		if strings.EqualFold(h.Key, key) {
+			if !found {
+				out = append(out, kafka.Header{Key: key, Value: []byte(value)})
+				found = true
+			}
+			continue
+		}
+		out = append(out, h)
+	}
+	if !found {
+		out = append(out, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	return out
+}
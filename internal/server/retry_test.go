@@ -0,0 +1,52 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyTopics(t *testing.T) {
+	p := RetryPolicy{
+		MaxRetries: 5,
+		Backoff:    []time.Duration{time.Second, 30 * time.Second, 5 * time.Minute},
+	}
+
+	require.Equal(t, []string{"orders-retry-1s", "orders-retry-30s", "orders-retry-5m"}, p.Topics("orders"))
+	require.Equal(t, "orders-retry-1s", p.TopicFor("orders", 1))
+	require.Equal(t, "orders-retry-5m", p.TopicFor("orders", 3))
+	// попытки сверх числа задержек используют последнюю
+	require.Equal(t, "orders-retry-5m", p.TopicFor("orders", 5))
+	require.Equal(t, 5*time.Minute, p.Delay(5))
+
+	// без задержек сообщение возвращается в основной топик сразу
+	require.Equal(t, "orders", RetryPolicy{MaxRetries: 1}.TopicFor("orders", 1))
+}
+
+func TestRetryMessageKeepsKeyAndSetsDueTime(t *testing.T) {
+	w := &WorkerServer{cfg: WorkerConfig{
+		Topic: "orders",
+		Retry: RetryPolicy{MaxRetries: 3, Backoff: []time.Duration{30 * time.Second}},
+	}}
+	msg := kafka.Message{
+		Key:     []byte("order-1"),
+		Value:   []byte("payload"),
+		Headers: []kafka.Header{{Key: "Retries", Value: []byte("1")}, {Key: "trace", Value: []byte("x")}},
+	}
+
+	before := time.Now()
+	next := w.retryMessage(msg, 2)
+
+	require.Equal(t, "orders-retry-30s", next.Topic)
+	require.Equal(t, msg.Key, next.Key)
+	require.Equal(t, 2, getRetries(next))
+	v, ok := headerValue(next, "trace")
+	require.True(t, ok)
+	require.Equal(t, "x", v)
+
+	due, ok := notBefore(next)
+	require.True(t, ok)
+	require.WithinDuration(t, before.Add(30*time.Second), due, time.Second)
+}
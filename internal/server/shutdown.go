@@ -0,0 +1,24 @@
+package server
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// GracefulStop останавливает gRPC сервер: новые вызовы отклоняются, активным даётся
+// время завершиться. Если за timeout они не успели, соединения закрываются принудительно.
+func GracefulStop(s *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		s.Stop()
+		<-done
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/go-portfolio/order-pipeline/internal/lifecycle"
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// watchBuffer — сколько непрочитанных событий держим на подписчика.
+// При переполнении выбрасывается самое старое: запись о заказе полная, важна только последняя.
+const watchBuffer = 16
+
+// watchHub раздаёт события о заказах подписчикам WatchOrder.
+// На все заказы открыта одна подписка Redis (PSUBSCRIBE order-events:*): она
+// создаётся с первым подписчиком и закрывается, когда уходит последний.
+type watchHub struct {
+	rdb RedisClient
+
+	mu       sync.Mutex
+	pubsub   *redis.PubSub
+	watchers map[string]map[chan *pb.ResultResponse]struct{}
+	count    int
+}
+
+func newWatchHub(rdb RedisClient) *watchHub {
+	return &watchHub{rdb: rdb, watchers: map[string]map[chan *pb.ResultResponse]struct{}{}}
+}
+
+// subscribe регистрирует подписчика на заказ id. Подписка Redis подтверждена до возврата,
+// поэтому ни одно событие после вызова не теряется. cancel нужно вызвать обязательно.
+func (h *watchHub) subscribe(ctx context.Context, id string) (<-chan *pb.ResultResponse, func(), error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.pubsub == nil {
+		ps := h.rdb.PSubscribe(ctx, lifecycle.EventsChannelPrefix+"*")
+		if _, err := ps.Receive(ctx); err != nil {
+			ps.Close()
+			return nil, nil, err
+		}
+		h.pubsub = ps
+		go h.dispatch(ps)
+	}
+
+	ch := make(chan *pb.ResultResponse, watchBuffer)
+	if h.watchers[id] == nil {
+		h.watchers[id] = map[chan *pb.ResultResponse]struct{}{}
+	}
+	h.watchers[id][ch] = struct{}{}
+	h.count++
+
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.watchers[id][ch]; !ok {
+			return
+		}
+		delete(h.watchers[id], ch)
+		if len(h.watchers[id]) == 0 {
+			delete(h.watchers, id)
+		}
+		h.count--
+		if h.count == 0 && h.pubsub != nil {
+			h.pubsub.Close()
+			h.pubsub = nil
+		}
+	}
+	return ch, cancel, nil
+}
+
+// active сообщает, открыта ли сейчас подписка Redis
+func (h *watchHub) active() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.pubsub != nil
+}
+
+// dispatch читает события подписки ps и раздаёт их подписчикам, пока ps не закроют
+func (h *watchHub) dispatch(ps *redis.PubSub) {
+	for msg := range ps.Channel() {
+		id := strings.TrimPrefix(msg.Channel, lifecycle.EventsChannelPrefix)
+		var res pb.ResultResponse
+		if err := protojson.Unmarshal([]byte(msg.Payload), &res); err != nil {
+			slog.Warn("watch: undecodable order event", logging.KeyOrderID, id, logging.KeyError, err)
+			continue
+		}
+
+		h.mu.Lock()
+		for ch := range h.watchers[id] {
+			publishLatest(ch, &res)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// publishLatest кладёт событие в канал, при переполнении вытесняя самое старое
+func publishLatest(ch chan *pb.ResultResponse, res *pb.ResultResponse) {
+	for {
+		select {
+		case ch <- res:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
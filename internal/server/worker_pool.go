@@ -0,0 +1,450 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/faults"
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+	"github.com/go-portfolio/order-pipeline/internal/tracing"
+	"github.com/segmentio/kafka-go"
+)
+
+// handlerFunc обрабатывает одно сообщение; оффсет коммитит пул.
+// Ошибка faults.Retryable повторяет сообщение на месте с нарастающей задержкой,
+// faults.RateLimited приостанавливает чтение всего пула на RetryAfter и тоже повторяет
+// сообщение. Любая другая ошибка останавливает пул: сообщение и всё после него
+// в партиции остаются незакоммиченными.
+type handlerFunc func(ctx context.Context, msg kafka.Message) error
+
+// Задержки повторов сообщения на месте после временной ошибки обработчика
+const (
+	minHandlerBackoff = 100 * time.Millisecond
+	maxHandlerBackoff = 30 * time.Second
+)
+
+// errInterrupted — повтор сообщения прерван остановкой пула
+var errInterrupted = errors.New("handler retry interrupted")
+
+// delayQueueSize — сколько отложенных сообщений одной партиции держится в памяти.
+// Когда очередь заполнена, чтение reader, получившего сообщение, ждёт освобождения места.
+// У каждого retry-топика свой reader, поэтому ждёт только его ярус задержки,
+// а основной топик и другие ярусы читаются дальше.
+const delayQueueSize = 1024
+
+// workerPool читает сообщения из Kafka и раздаёт их по обработчикам (lanes).
+// Сообщения с одинаковым ключом (ID заказа) всегда попадают в один lane и
+// обрабатываются строго по очереди. Оффсет партиции коммитится только после того,
+// как завершены все более ранние сообщения этой партиции.
+//
+// Сообщения с заголовком not-before проходят через очередь своей партиции и
+// ждут наступления срока, не задерживая остальные партиции.
+//
+// Каждый reader читается своим fetchLoop; оффсет коммитит тот reader, который
+// получил сообщение, — за ним закреплена партиция в группе потребителей.
+type workerPool struct {
+	reader  KafkaReader
+	handle  handlerFunc
+	lanes   []chan kafka.Message
+	tracker *offsetTracker
+	commits chan kafka.Message
+
+	// retryReaders — отдельные readers retry-топиков; nil — всё читает reader
+	retryReaders []KafkaReader
+	// readerOf — какой reader читает топик
+	readerMu sync.Mutex
+	readerOf map[string]KafkaReader
+
+	delayWG sync.WaitGroup
+
+	// failOnce/err хранят первую ошибку обработчика, stop прекращает чтение
+	failOnce sync.Once
+	err      error
+	stop     context.CancelFunc
+
+	// pausedUntil — до какого момента чтение и обработка приостановлены
+	pauseMu     sync.Mutex
+	pausedUntil time.Time
+
+	minBackoff, maxBackoff time.Duration
+
+	// lastFetch — время последнего успешного чтения (UnixNano); nil — не отслеживается
+	lastFetch *atomic.Int64
+	// limit ограничивает число одновременно работающих lanes; nil — работают все
+	limit *limiter
+}
+
+// newWorkerPool создаёт пул из size обработчиков
+func newWorkerPool(reader KafkaReader, size int, handle handlerFunc) *workerPool {
+	if size < 1 {
+		size = 1
+	}
+	p := &workerPool{
+		reader:   reader,
+		handle:   handle,
+		lanes:    make([]chan kafka.Message, size),
+		tracker:  newOffsetTracker(),
+		commits:  make(chan kafka.Message, size),
+		readerOf: map[string]KafkaReader{},
+
+		minBackoff: minHandlerBackoff,
+		maxBackoff: maxHandlerBackoff,
+	}
+	for i := range p.lanes {
+		p.lanes[i] = make(chan kafka.Message, 1)
+	}
+	return p
+}
+
+// run читает сообщения до отмены ctx или первой ошибки обработчика.
+// Уже начатые сообщения дорабатываются, а ожидающие в очереди пропускаются
+// без коммита — Kafka выдаст их повторно.
+func (p *workerPool) run(parent context.Context) error {
+	// обработка и коммит не должны прерываться отменой ctx
+	workCtx := context.WithoutCancel(parent)
+	ctx, stop := context.WithCancel(parent)
+	defer stop()
+	p.stop = stop
+
+	var lanesWG, committerWG sync.WaitGroup
+	committerWG.Add(1)
+	go func() {
+		defer committerWG.Done()
+		p.commitLoop(workCtx)
+	}()
+	for _, lane := range p.lanes {
+		lanesWG.Add(1)
+		go func(lane <-chan kafka.Message) {
+			defer lanesWG.Done()
+			for msg := range lane {
+				if ctx.Err() != nil {
+					continue
+				}
+				if p.limit != nil {
+					if p.limit.acquire(ctx) != nil {
+						continue
+					}
+				}
+				err := p.process(ctx, workCtx, msg)
+				if p.limit != nil {
+					p.limit.release()
+				}
+				if err != nil {
+					if !errors.Is(err, errInterrupted) {
+						p.fail(fmt.Errorf("partition %d offset %d: %w", msg.Partition, msg.Offset, err))
+					}
+					continue
+				}
+				if next, ok := p.tracker.done(msg); ok {
+					p.commits <- next
+				}
+			}
+		}(lane)
+	}
+
+	readers := append([]KafkaReader{p.reader}, p.retryReaders...)
+	errs := make([]error, len(readers))
+	delays := make([]map[partitionKey]chan kafka.Message, len(readers))
+	var fetchWG sync.WaitGroup
+	for i, reader := range readers {
+		delays[i] = map[partitionKey]chan kafka.Message{}
+		fetchWG.Add(1)
+		go func() {
+			defer fetchWG.Done()
+			errs[i] = p.fetchLoop(ctx, reader, delays[i])
+			// fetchLoop мог завершиться при живом ctx (reader закрыт): пул останавливается целиком,
+			// иначе delayLoop дожидался бы сроков отложенных сообщений, а они могут быть в минутах
+			stop()
+		}()
+	}
+	fetchWG.Wait()
+
+	for _, queues := range delays {
+		for _, queue := range queues {
+			close(queue)
+		}
+	}
+	p.delayWG.Wait()
+	for _, lane := range p.lanes {
+		close(lane)
+	}
+	lanesWG.Wait()
+	close(p.commits)
+	committerWG.Wait()
+	if p.err != nil {
+		return p.err
+	}
+	return errors.Join(errs...)
+}
+
+// process вызывает обработчик, пока он не завершится успехом или неустранимой ошибкой.
+// Пока сообщение повторяется, его оффсет и все следующие в партиции не коммитятся.
+func (p *workerPool) process(ctx, workCtx context.Context, msg kafka.Message) error {
+	backoff := p.minBackoff
+	for {
+		if !p.waitPause(ctx) {
+			return errInterrupted
+		}
+		err := p.handle(workCtx, msg)
+		if err == nil {
+			return nil
+		}
+
+		kind, _ := faults.KindOf(err)
+		switch kind {
+		case faults.RateLimited:
+			after, _ := faults.RetryAfter(err)
+			if after <= 0 {
+				after = backoff
+			}
+			slog.Warn("rate limited, pausing consumption", append(logging.Message(msg), "pause", after.String(), logging.KeyError, err)...)
+			p.pause(after)
+		case faults.Retryable:
+			slog.Warn("retrying message in place", append(logging.Message(msg), "backoff", backoff.String(), logging.KeyError, err)...)
+			if !sleepCtx(ctx, backoff) {
+				return errInterrupted
+			}
+			backoff = min(backoff*2, p.maxBackoff)
+		default:
+			return err
+		}
+	}
+}
+
+// pause приостанавливает чтение и обработку на d
+func (p *workerPool) pause(d time.Duration) {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if until := time.Now().Add(d); until.After(p.pausedUntil) {
+		p.pausedUntil = until
+	}
+}
+
+// waitPause ждёт окончания паузы; false — пул остановлен раньше
+func (p *workerPool) waitPause(ctx context.Context) bool {
+	for {
+		p.pauseMu.Lock()
+		wait := time.Until(p.pausedUntil)
+		p.pauseMu.Unlock()
+		if wait <= 0 {
+			return ctx.Err() == nil
+		}
+		if !sleepCtx(ctx, wait) {
+			return false
+		}
+	}
+}
+
+// sleepCtx ждёт d; false — ctx отменён раньше
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// fail запоминает первую ошибку обработки и останавливает чтение новых сообщений
+func (p *workerPool) fail(err error) {
+	p.failOnce.Do(func() {
+		slog.Error("stopping consumption, message left uncommitted", logging.KeyError, err)
+		p.err = err
+		p.stop()
+	})
+}
+
+// fetchLoop получает сообщения reader и отправляет их в lane по ключу.
+// delays — очереди отложенных сообщений по партициям этого reader.
+func (p *workerPool) fetchLoop(ctx context.Context, reader KafkaReader, delays map[partitionKey]chan kafka.Message) error {
+	for {
+		if !p.waitPause(ctx) {
+			return nil
+		}
+		start := time.Now()
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("kafka reader closed: %w", err)
+			}
+			slog.Error("fetch message", logging.KeyError, err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		if p.lastFetch != nil {
+			p.lastFetch.Store(time.Now().UnixNano())
+		}
+		tracing.RecordFetch(ctx, msg, start)
+		p.setReader(msg.Topic, reader)
+		p.tracker.track(msg)
+		p.dispatch(ctx, msg, delays)
+	}
+}
+
+// dispatch отправляет сообщение в lane. Отложенные сообщения идут через очередь
+// своей партиции, чтобы сохранить порядок между ними; полная очередь задерживает
+// только fetchLoop своего reader.
+func (p *workerPool) dispatch(ctx context.Context, msg kafka.Message, delays map[partitionKey]chan kafka.Message) {
+	if _, ok := notBefore(msg); !ok {
+		p.lanes[p.laneFor(msg)] <- msg
+		return
+	}
+
+	key := partitionKey{msg.Topic, msg.Partition}
+	queue, ok := delays[key]
+	if !ok {
+		queue = make(chan kafka.Message, delayQueueSize)
+		delays[key] = queue
+		p.delayWG.Add(1)
+		go func() {
+			defer p.delayWG.Done()
+			p.delayLoop(ctx, queue)
+		}()
+	}
+	queue <- msg
+}
+
+// delayLoop выдерживает сообщения партиции до их срока и передаёт в lane.
+// После отмены ctx оставшиеся сообщения пропускаются без коммита.
+func (p *workerPool) delayLoop(ctx context.Context, queue <-chan kafka.Message) {
+	for msg := range queue {
+		due, _ := notBefore(msg)
+		if wait := time.Until(due); wait > 0 && ctx.Err() == nil {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			continue
+		}
+		p.lanes[p.laneFor(msg)] <- msg
+	}
+}
+
+// setReader запоминает, какой reader читает топик
+func (p *workerPool) setReader(topic string, reader KafkaReader) {
+	p.readerMu.Lock()
+	defer p.readerMu.Unlock()
+	p.readerOf[topic] = reader
+}
+
+// readerFor возвращает reader, который коммитит оффсеты топика
+func (p *workerPool) readerFor(topic string) KafkaReader {
+	p.readerMu.Lock()
+	defer p.readerMu.Unlock()
+	if reader, ok := p.readerOf[topic]; ok {
+		return reader
+	}
+	return p.reader
+}
+
+// laneFor выбирает обработчик по ключу сообщения; сообщения без ключа
+// распределяются по партиции, чтобы сохранить порядок внутри неё
+func (p *workerPool) laneFor(msg kafka.Message) int {
+	h := fnv.New32a()
+	if len(msg.Key) > 0 {
+		h.Write(msg.Key)
+	} else {
+		h.Write([]byte(msg.Topic))
+		h.Write([]byte(strconv.Itoa(msg.Partition)))
+	}
+	return int(h.Sum32() % uint32(len(p.lanes)))
+}
+
+// commitLoop коммитит оффсеты из одной горутины, поэтому они никогда не откатываются назад
+func (p *workerPool) commitLoop(ctx context.Context) {
+	committed := map[partitionKey]int64{}
+	for msg := range p.commits {
+		key := partitionKey{msg.Topic, msg.Partition}
+		if last, ok := committed[key]; ok && msg.Offset <= last {
+			continue
+		}
+		if err := p.readerFor(msg.Topic).CommitMessages(ctx, msg); err != nil {
+			slog.Error("commit offset", append(logging.Message(msg), logging.KeyError, err)...)
+			continue
+		}
+		committed[key] = msg.Offset
+	}
+}
+
+// partitionKey идентифицирует партицию топика
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// offsetTracker хранит незавершённые сообщения каждой партиции в порядке получения
+type offsetTracker struct {
+	mu      sync.Mutex
+	pending map[partitionKey][]*trackedMessage
+}
+
+type trackedMessage struct {
+	msg  kafka.Message
+	done bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{pending: map[partitionKey][]*trackedMessage{}}
+}
+
+// track регистрирует полученное сообщение. Если оффсет не больше последнего
+// известного, партиция была перечитана после ребалансировки — старые записи сбрасываются.
+func (t *offsetTracker) track(msg kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := partitionKey{msg.Topic, msg.Partition}
+	queue := t.pending[key]
+	if n := len(queue); n > 0 && msg.Offset <= queue[n-1].msg.Offset {
+		queue = nil
+	}
+	t.pending[key] = append(queue, &trackedMessage{msg: msg})
+}
+
+// done отмечает сообщение завершённым и возвращает последнее сообщение непрерывного
+// завершённого префикса партиции — его оффсет можно коммитить
+func (t *offsetTracker) done(msg kafka.Message) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := partitionKey{msg.Topic, msg.Partition}
+	queue := t.pending[key]
+	for _, m := range queue {
+		if m.msg.Offset == msg.Offset {
+			m.done = true
+			break
+		}
+	}
+
+	var last kafka.Message
+	found := false
+	for len(queue) > 0 && queue[0].done {
+		last = queue[0].msg
+		found = true
+		queue = queue[1:]
+	}
+	t.pending[key] = queue
+	return last, found
+}
@@ -0,0 +1,423 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/faults"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReader отдаёт заранее подготовленные сообщения, затем блокируется до отмены ctx
+type fakeReader struct {
+	mu      sync.Mutex
+	msgs    []kafka.Message
+	commits []kafka.Message
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.mu.Lock()
+	if len(r.msgs) > 0 {
+		msg := r.msgs[0]
+		r.msgs = r.msgs[1:]
+		r.mu.Unlock()
+		return msg, nil
+	}
+	r.mu.Unlock()
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commits = append(r.commits, msgs...)
+	return nil
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+// committed возвращает последний закоммиченный оффсет партиции или -1
+func (r *fakeReader) committed(partition int) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last := int64(-1)
+	for _, m := range r.commits {
+		if m.Partition == partition && m.Offset > last {
+			last = m.Offset
+		}
+	}
+	return last
+}
+
+func msgAt(partition int, offset int64, key string) kafka.Message {
+	return kafka.Message{Topic: "orders", Partition: partition, Offset: offset, Key: []byte(key)}
+}
+
+func TestWorkerPoolKeepsOrderPerKey(t *testing.T) {
+	reader := &fakeReader{}
+	for i := 0; i < 30; i++ {
+		reader.msgs = append(reader.msgs, msgAt(i%2, int64(i/2), "order-"+strconv.Itoa(i%3)))
+	}
+
+	var mu sync.Mutex
+	seen := map[string][]int64{}
+	handled := make(chan struct{}, 30)
+	pool := newWorkerPool(reader, 4, func(_ context.Context, msg kafka.Message) error {
+		// разные задержки, чтобы обработчики завершались вразнобой
+		time.Sleep(time.Duration(msg.Offset%3) * time.Millisecond)
+		mu.Lock()
+		seen[string(msg.Key)] = append(seen[string(msg.Key)], int64(msg.Partition)*1000+msg.Offset)
+		mu.Unlock()
+		handled <- struct{}{}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- pool.run(ctx) }()
+	for i := 0; i < 30; i++ {
+		<-handled
+	}
+	cancel()
+	require.NoError(t, <-done)
+
+	// внутри одного ключа порядок получения сохраняется
+	for key, order := range seen {
+		require.Len(t, order, 10, key)
+		for p := 0; p < 2; p++ {
+			var last int64 = -1
+			for _, v := range order {
+				if int(v/1000) != p {
+					continue
+				}
+				require.Greater(t, v%1000, last, "key %s processed out of order: %v", key, order)
+				last = v % 1000
+			}
+		}
+	}
+	require.Equal(t, int64(14), reader.committed(0))
+	require.Equal(t, int64(14), reader.committed(1))
+}
+
+func TestWorkerPoolCommitsOnlyContiguousOffsets(t *testing.T) {
+	reader := &fakeReader{msgs: []kafka.Message{
+		msgAt(0, 10, "slow"),
+		msgAt(0, 11, "fast-1"),
+		msgAt(0, 12, "fast-2"),
+	}}
+
+	release := make(chan struct{})
+	handled := make(chan int64, 3)
+	pool := newWorkerPool(reader, 8, func(_ context.Context, msg kafka.Message) error {
+		if string(msg.Key) == "slow" {
+			<-release
+		}
+		handled <- msg.Offset
+		return nil
+	})
+	// ключи должны попасть в разные обработчики, иначе тест ничего не проверяет
+	require.NotEqual(t, pool.laneFor(msgAt(0, 0, "slow")), pool.laneFor(msgAt(0, 0, "fast-1")))
+	require.NotEqual(t, pool.laneFor(msgAt(0, 0, "slow")), pool.laneFor(msgAt(0, 0, "fast-2")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- pool.run(ctx) }()
+
+	// более поздние сообщения завершились раньше — коммитить их ещё нельзя
+	require.ElementsMatch(t, []int64{11, 12}, []int64{<-handled, <-handled})
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, int64(-1), reader.committed(0))
+
+	close(release)
+	require.Equal(t, int64(10), <-handled)
+	require.Eventually(t, func() bool { return reader.committed(0) == 12 }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestOffsetTrackerResetsAfterRebalance(t *testing.T) {
+	tr := newOffsetTracker()
+	tr.track(msgAt(0, 5, "a"))
+	tr.track(msgAt(0, 6, "b"))
+
+	// партицию перечитали с 5: незавершённые записи заменяются новыми
+	tr.track(msgAt(0, 5, "a"))
+	next, ok := tr.done(msgAt(0, 5, "a"))
+	require.True(t, ok)
+	require.Equal(t, int64(5), next.Offset)
+
+	_, ok = tr.done(msgAt(0, 6, "b"))
+	require.False(t, ok)
+}
+
+func TestWorkerPoolHoldsDelayedMessagesPerPartition(t *testing.T) {
+	delayed := msgAt(0, 0, "retry")
+	delayed.Topic = "orders-retry-1s"
+	delayed.Headers = setHeader(nil, notBeforeHeader, strconv.FormatInt(time.Now().Add(200*time.Millisecond).UnixMilli(), 10))
+	reader := &fakeReader{msgs: []kafka.Message{delayed, msgAt(1, 0, "fresh")}}
+
+	handled := make(chan kafka.Message, 2)
+	pool := newWorkerPool(reader, 2, func(_ context.Context, msg kafka.Message) error {
+		handled <- msg
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() { done <- pool.run(ctx) }()
+
+	// отложенное сообщение не блокирует другие партиции
+	first := <-handled
+	require.Equal(t, "fresh", string(first.Key))
+	second := <-handled
+	require.Equal(t, "retry", string(second.Key))
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestWorkerPoolFullDelayQueueStopsOnlyItsReader(t *testing.T) {
+	later := strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)
+	retry := &fakeReader{}
+	for i := range delayQueueSize + 10 {
+		msg := msgAt(0, int64(i), "retry-"+strconv.Itoa(i))
+		msg.Topic = "orders-retry-1h"
+		msg.Headers = setHeader(nil, notBeforeHeader, later)
+		retry.msgs = append(retry.msgs, msg)
+	}
+	main := &fakeReader{msgs: []kafka.Message{msgAt(0, 0, "fresh")}}
+
+	handled := make(chan kafka.Message, 1)
+	pool := newWorkerPool(main, 2, func(_ context.Context, msg kafka.Message) error {
+		handled <- msg
+		return nil
+	})
+	pool.retryReaders = []KafkaReader{retry}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- pool.run(ctx) }()
+
+	// переполненный ярус задержки не мешает основному топику
+	select {
+	case msg := <-handled:
+		require.Equal(t, "fresh", string(msg.Key))
+	case <-time.After(2 * time.Second):
+		t.Fatal("full delay queue blocked the main topic")
+	}
+	require.Eventually(t, func() bool { return main.committed(0) == 0 }, time.Second, 10*time.Millisecond)
+
+	// retry-reader перестал читать: в памяти очередь, таймер delayLoop и одно ждущее место сообщение
+	require.Eventually(t, func() bool {
+		retry.mu.Lock()
+		defer retry.mu.Unlock()
+		return len(retry.msgs) == 8
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	retry.mu.Lock()
+	require.Len(t, retry.msgs, 8)
+	retry.mu.Unlock()
+
+	cancel()
+	require.NoError(t, <-done)
+	require.Equal(t, int64(-1), retry.committed(0))
+}
+
+func TestWorkerPoolCommitsThroughFetchingReader(t *testing.T) {
+	due := msgAt(0, 4, "retry")
+	due.Topic = "orders-retry-1s"
+	due.Headers = setHeader(nil, notBeforeHeader, strconv.FormatInt(time.Now().UnixMilli(), 10))
+	retry := &fakeReader{msgs: []kafka.Message{due}}
+	main := &fakeReader{msgs: []kafka.Message{msgAt(0, 7, "fresh")}}
+
+	pool := newWorkerPool(main, 2, func(context.Context, kafka.Message) error { return nil })
+	pool.retryReaders = []KafkaReader{retry}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- pool.run(ctx) }()
+
+	require.Eventually(t, func() bool { return retry.committed(0) == 4 && main.committed(0) == 7 }, 2*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	require.Len(t, main.commits, 1)
+	require.Len(t, retry.commits, 1)
+}
+
+// closingReader отдаёт сообщения, затем io.EOF, как закрытый kafka.Reader
+type closingReader struct {
+	fakeReader
+}
+
+func (r *closingReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.msgs) == 0 {
+		return kafka.Message{}, io.EOF
+	}
+	msg := r.msgs[0]
+	r.msgs = r.msgs[1:]
+	return msg, nil
+}
+
+func TestWorkerPoolStopsDelayedMessagesWhenReaderCloses(t *testing.T) {
+	delayed := msgAt(0, 0, "retry")
+	delayed.Topic = "orders-retry-5m"
+	delayed.Headers = setHeader(nil, notBeforeHeader, strconv.FormatInt(time.Now().Add(5*time.Minute).UnixMilli(), 10))
+	reader := &closingReader{fakeReader{msgs: []kafka.Message{delayed}}}
+
+	pool := newWorkerPool(reader, 1, func(context.Context, kafka.Message) error {
+		t.Error("delayed message must be skipped after the reader closes")
+		return nil
+	})
+
+	// пул не ждёт срока отложенного сообщения — оно останется незакоммиченным
+	done := make(chan error, 1)
+	go func() { done <- pool.run(context.Background()) }()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, io.EOF)
+	case <-time.After(2 * time.Second):
+		t.Fatal("pool waited for the delayed message after the reader closed")
+	}
+}
+
+func TestWorkerPoolStopsWithoutCommitOnHandlerError(t *testing.T) {
+	reader := &fakeReader{msgs: []kafka.Message{
+		msgAt(0, 0, "ok"),
+		msgAt(0, 1, "dlq-down"),
+		msgAt(0, 2, "ok"),
+	}}
+
+	pool := newWorkerPool(reader, 1, func(_ context.Context, msg kafka.Message) error {
+		if string(msg.Key) == "dlq-down" {
+			return errors.New("dlq unavailable")
+		}
+		return nil
+	})
+
+	err := pool.run(context.Background())
+	require.ErrorContains(t, err, "dlq unavailable")
+	// сообщение, которое не удалось отправить в DLQ, не коммитится
+	require.Equal(t, int64(0), reader.committed(0))
+}
+
+func TestWorkerPoolRetriesRetryableErrorsInPlace(t *testing.T) {
+	reader := &fakeReader{msgs: []kafka.Message{msgAt(0, 0, "flaky"), msgAt(0, 1, "ok")}}
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	pool := newWorkerPool(reader, 1, func(_ context.Context, msg kafka.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[string(msg.Key)]++
+		if string(msg.Key) == "flaky" && calls["flaky"] < 3 {
+			return faults.AsRetryable(errors.New("redis down"))
+		}
+		return nil
+	})
+	pool.minBackoff = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- pool.run(ctx) }()
+
+	require.Eventually(t, func() bool { return reader.committed(0) == 1 }, time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	mu.Lock()
+	require.Equal(t, 3, calls["flaky"])
+	mu.Unlock()
+}
+
+func TestWorkerPoolPausesWhenRateLimited(t *testing.T) {
+	reader := &fakeReader{msgs: []kafka.Message{msgAt(0, 0, "a")}}
+
+	var mu sync.Mutex
+	var times []time.Time
+	pool := newWorkerPool(reader, 1, func(_ context.Context, msg kafka.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, time.Now())
+		if len(times) == 1 {
+			return faults.AsRateLimited(errors.New("too many requests"), 100*time.Millisecond)
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- pool.run(ctx) }()
+
+	require.Eventually(t, func() bool { return reader.committed(0) == 0 }, time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, times, 2)
+	require.GreaterOrEqual(t, times[1].Sub(times[0]), 90*time.Millisecond)
+}
+
+func TestWorkerPoolConcurrencyChangesAtRuntime(t *testing.T) {
+	reader := &fakeReader{}
+	for i := 0; i < 8; i++ {
+		reader.msgs = append(reader.msgs, msgAt(0, int64(i), "order-"+strconv.Itoa(i)))
+	}
+
+	var mu sync.Mutex
+	active, peak := 0, 0
+	release := make(chan struct{})
+	started := make(chan struct{}, 8)
+	pool := newWorkerPool(reader, 8, func(context.Context, kafka.Message) error {
+		mu.Lock()
+		active++
+		peak = max(peak, active)
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return nil
+	})
+	pool.limit = newLimiter(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- pool.run(ctx) }()
+
+	<-started
+	select {
+	case <-started:
+		t.Fatal("second handler started above the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// поднимаем предел: ещё два обработчика стартуют, не дожидаясь первого
+	pool.limit.setLimit(3)
+	<-started
+	<-started
+	mu.Lock()
+	require.Equal(t, 3, peak)
+	mu.Unlock()
+
+	close(release)
+	for i := 0; i < 5; i++ {
+		<-started
+	}
+	cancel()
+	require.NoError(t, <-done)
+	mu.Lock()
+	require.Equal(t, 3, peak)
+	mu.Unlock()
+}
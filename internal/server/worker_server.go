@@ -1,42 +1,114 @@
 package server
 
 import (
+	"bytes"
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"strconv"
-	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-portfolio/order-pipeline/internal/dlq"
+	"github.com/go-portfolio/order-pipeline/internal/faults"
+	"github.com/go-portfolio/order-pipeline/internal/health"
+	"github.com/go-portfolio/order-pipeline/internal/lifecycle"
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+	"github.com/go-portfolio/order-pipeline/internal/metrics"
+	"github.com/go-portfolio/order-pipeline/internal/pipeline"
+	"github.com/go-portfolio/order-pipeline/internal/tracing"
+	"github.com/go-portfolio/order-pipeline/internal/validation"
 	pb "github.com/go-portfolio/order-pipeline/proto"
 	"github.com/redis/go-redis/v9"
 	"github.com/segmentio/kafka-go"
-	"google.golang.org/protobuf/encoding/protojson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/protobuf/proto"
 )
 
-const maxRetries = 3
+// WorkerConfig задаёт параметры обработки сообщений воркером
+type WorkerConfig struct {
+	// Topic — основной топик заказов; от него строятся имена retry-топиков
+	Topic string
+	// Retry — число повторных попыток и задержки между ними
+	Retry RetryPolicy
+	// Concurrency — число параллельных обработчиков. Заказы с одним ID
+	// всегда обрабатываются одним обработчиком по очереди.
+	Concurrency int
+	// MaxConcurrency — до скольких обработчиков можно поднять Concurrency через Apply;
+	// по умолчанию равно Concurrency
+	MaxConcurrency int
+	// ProcessedTTL — сколько помнить обработанные заказы, чтобы пропускать их повторы
+	ProcessedTTL time.Duration
+	// Key — стратегия ключа, которой пользуется приёмник; по ней проверяется ключ сообщения
+	Key KeyFunc
+	// Balancer распределяет повторно отправленные сообщения по партициям по ключу
+	Balancer kafka.Balancer
+	// Validator приводит заказы старого формата к версии 2
+	Validator *validation.Validator
+	// Pipeline — стадии обработки заказа; по умолчанию pipeline.DefaultStages
+	Pipeline *pipeline.Pipeline
+	// Metrics — счётчики обработки; nil отключает метрики
+	Metrics *metrics.Metrics
+	// ClientID — идентификатор клиента Kafka; по нему проверка готовности находит воркер в группе
+	ClientID string
+	// RedisPassword — пароль Redis, если сервер требует AUTH
+	RedisPassword string
+}
+
+// WorkerSettings — параметры воркера, которые меняются на ходу через Apply
+type WorkerSettings struct {
+	// Concurrency — число параллельных обработчиков, не больше WorkerConfig.MaxConcurrency
+	Concurrency int
+	// MaxRetries — сколько раз повторять заказ через retry-топики перед DLQ
+	MaxRetries int
+	// ProcessedTTL — сколько помнить обработанные заказы
+	ProcessedTTL time.Duration
+	// Validator и Pipeline — правила проверки и стадии обработки
+	Validator *validation.Validator
+	Pipeline  *pipeline.Pipeline
+}
 
 // WorkerServer хранит зависимости через интерфейсы
 type WorkerServer struct {
-	reader    KafkaReader
-	writer    KafkaWriter
-	dlqWriter KafkaWriter
-	rdb       RedisClient
-	ctx       context.Context
-}
+	reader KafkaReader
+	// retryReaders читают retry-топики отдельно от reader: переполненный ярус задержки
+	// останавливает только своё чтение
+	retryReaders []KafkaReader
+	writer       KafkaWriter
+	dlqWriter    KafkaWriter
+	rdb          RedisClient
+	states       *lifecycle.Store
+	cfg          WorkerConfig
 
-// Конструктор с внедрением зависимостей
+	// groupCheck проверяет членство в группе потребителей; только у NewWorkerServer
+	groupCheck *health.Check
+	running    atomic.Bool
+	lastFetch  atomic.Int64
 
-func NewWorkerServer(brokers []string, topic, dlqTopic, groupID, redisAddr string) *WorkerServer {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: brokers,
-		GroupID: groupID,
-		Topic:   topic,
-	})
+	// settings меняются через Apply; сообщение обрабатывается с одним снимком
+	settings atomic.Pointer[WorkerSettings]
+	limit    *limiter
+}
+
+// NewWorkerServer создаёт воркер с подключениями к Kafka и Redis.
+// Воркер читает основной топик cfg.Topic и все retry-топики политики cfg.Retry,
+// каждый своим reader в одной группе потребителей.
+func NewWorkerServer(brokers []string, dlqTopic, groupID, redisAddr string, cfg WorkerConfig) *WorkerServer {
+	newReader := func(topic string) KafkaReader {
+		return cfg.Metrics.InstrumentReader(kafka.NewReader(kafka.ReaderConfig{
+			Brokers:     brokers,
+			GroupID:     groupID,
+			GroupTopics: []string{topic},
+			Dialer:      &kafka.Dialer{ClientID: cfg.ClientID, Timeout: 10 * time.Second, DualStack: true},
+		}))
+	}
 
+	// топик задаётся в каждом сообщении: основной или один из retry-топиков
 	writer := kafka.NewWriter(kafka.WriterConfig{
-		Brokers: brokers,
-		Topic:   topic,
+		Brokers:  brokers,
+		Balancer: cfg.Balancer,
 	})
 
 	dlqWriter := kafka.NewWriter(kafka.WriterConfig{
@@ -44,108 +116,361 @@ func NewWorkerServer(brokers []string, topic, dlqTopic, groupID, redisAddr strin
 		Topic:   dlqTopic,
 	})
 
-	rdb := redis.NewClient(&redis.Options{Addr: redisAddr})
+	rdb := redis.NewClient(&redis.Options{Addr: redisAddr, Password: cfg.RedisPassword})
+	rdb.AddHook(tracing.RedisHook())
+	if cfg.Metrics != nil {
+		rdb.AddHook(cfg.Metrics.RedisHook())
+	}
 
-	return &WorkerServer{
+	w := NewWorker(
+		newReader(cfg.Topic),
+		cfg.Metrics.InstrumentWriter(tracing.InstrumentWriter(writer, cfg.Topic+"-retry"), cfg.Topic+"-retry"),
+		cfg.Metrics.InstrumentWriter(tracing.InstrumentWriter(dlqWriter, dlqTopic), dlqTopic),
+		rdb, cfg,
+	)
+	for _, topic := range cfg.Retry.Topics(cfg.Topic) {
+		w.retryReaders = append(w.retryReaders, newReader(topic))
+	}
+	if cfg.ClientID != "" {
+		check := health.GroupMembership(brokers, groupID, cfg.ClientID)
+		w.groupCheck = &check
+	}
+	return w
+}
+
+// NewWorker конструктор с внедрением зависимостей
+func NewWorker(reader KafkaReader, writer, dlqWriter KafkaWriter, rdb RedisClient, cfg WorkerConfig) *WorkerServer {
+	if cfg.Key == nil {
+		cfg.Key = OrderIDKey
+	}
+	if cfg.Validator == nil {
+		cfg.Validator = validation.MustDefault()
+	}
+	if cfg.Pipeline == nil {
+		p, err := pipeline.Build(pipeline.DefaultStages, pipeline.Deps{Validator: cfg.Validator, SimulatedDelay: pipeline.DefaultSimulatedDelay})
+		if err != nil {
+			panic(err)
+		}
+		cfg.Pipeline = p
+	}
+	cfg.Concurrency = max(cfg.Concurrency, 1)
+	cfg.MaxConcurrency = max(cfg.MaxConcurrency, cfg.Concurrency)
+	w := &WorkerServer{
 		reader:    reader,
 		writer:    writer,
 		dlqWriter: dlqWriter,
 		rdb:       rdb,
-		ctx:       context.Background(),
+		states:    lifecycle.NewStore(rdb),
+		cfg:       cfg,
+		limit:     newLimiter(cfg.Concurrency),
+	}
+	w.settings.Store(&WorkerSettings{
+		Concurrency:  cfg.Concurrency,
+		MaxRetries:   cfg.Retry.MaxRetries,
+		ProcessedTTL: cfg.ProcessedTTL,
+		Validator:    cfg.Validator,
+		Pipeline:     cfg.Pipeline,
+	})
+	return w
+}
+
+// Apply меняет параметры обработки без перезапуска. Начатые сообщения дорабатываются
+// со старыми параметрами, следующие берут новые. Concurrency ограничивается MaxConcurrency.
+func (w *WorkerServer) Apply(s WorkerSettings) {
+	s.Concurrency = min(max(s.Concurrency, 1), w.cfg.MaxConcurrency)
+	if s.Validator == nil {
+		s.Validator = w.settings.Load().Validator
+	}
+	if s.Pipeline == nil {
+		s.Pipeline = w.settings.Load().Pipeline
 	}
+	w.settings.Store(&s)
+	w.limit.setLimit(s.Concurrency)
 }
 
-// Run запускает основной цикл обработки сообщений
-func (w *WorkerServer) Run() {
-	defer w.reader.Close()
-	defer w.writer.Close()
-	defer w.dlqWriter.Close()
+// Run запускает обработку сообщений и работает до отмены ctx.
+// Уже начатые сообщения дорабатываются до конца вместе с коммитом оффсетов,
+// после чего reader, writer и DLQ writer закрываются.
+func (w *WorkerServer) Run(ctx context.Context) error {
+	defer w.close()
 
-	for {
-		msg, err := w.reader.FetchMessage(w.ctx)
-		if err != nil {
-			log.Printf("fetch error: %v", err)
-			time.Sleep(time.Second)
-			continue
+	w.running.Store(true)
+	defer w.running.Store(false)
+	// lanes создаются с запасом до MaxConcurrency, работают одновременно не больше Concurrency
+	pool := newWorkerPool(w.reader, w.cfg.MaxConcurrency, w.handleMessage)
+	pool.retryReaders = w.retryReaders
+	pool.lastFetch = &w.lastFetch
+	pool.limit = w.limit
+	return pool.run(ctx)
+}
+
+// LivenessChecks — проверки /healthz: цикл чтения работает
+func (w *WorkerServer) LivenessChecks() []health.Check {
+	return []health.Check{w.consumerCheck()}
+}
+
+// ReadinessChecks — проверки /readyz: цикл чтения работает, Redis отвечает,
+// воркер состоит в группе потребителей
+func (w *WorkerServer) ReadinessChecks() []health.Check {
+	checks := []health.Check{w.consumerCheck(), health.RedisPing(w.rdb)}
+	if w.groupCheck != nil {
+		checks = append(checks, *w.groupCheck)
+	}
+	return checks
+}
+
+// consumerCheck сообщает время последнего успешного чтения. Простой топик не ошибка:
+// чтение ждёт новых сообщений, поэтому ошибкой считается только остановленный цикл.
+func (w *WorkerServer) consumerCheck() health.Check {
+	return health.Check{Name: "consumer", Probe: func(context.Context) (string, error) {
+		if !w.running.Load() {
+			return "", errors.New("consumer loop is not running")
+		}
+		last := w.lastFetch.Load()
+		if last == 0 {
+			return "no messages fetched yet", nil
 		}
+		at := time.Unix(0, last)
+		return fmt.Sprintf("last fetch at %s (%s ago)", at.UTC().Format(time.RFC3339), time.Since(at).Round(time.Second)), nil
+	}}
+}
+
+// handleMessage обрабатывает одно сообщение; оффсет коммитит пул.
+// Ошибка означает, что сообщение нельзя коммитить: ни повтор, ни DLQ не записаны.
+// Обработка продолжает трассу из заголовков сообщения.
+func (w *WorkerServer) handleMessage(ctx context.Context, msg kafka.Message) (err error) {
+	ctx, span := tracing.StartConsume(ctx, msg)
+	ctx = logging.With(ctx, append(logging.Message(msg), logging.KeyRetry, getRetries(msg))...)
+	defer func() {
+		tracing.Fail(span, err)
+		span.End()
+	}()
+	return w.processMessage(ctx, msg)
+}
+
+// processMessage проверяет заказ, прогоняет его через стадии и записывает итог
+func (w *WorkerServer) processMessage(ctx context.Context, msg kafka.Message) error {
+	settings := w.settings.Load()
+	var order pb.OrderRequest
+	if err := proto.Unmarshal(msg.Value, &order); err != nil {
+		slog.WarnContext(ctx, "undecodable message, sending to DLQ", logging.KeyError, err)
+		return w.sendToDLQ(ctx, dlq.New(msg, dlq.ReasonUnmarshal, err, "", getRetries(msg)))
+	}
+
+	// ключ должен совпадать с заказом, иначе порядок по партициям не гарантирован.
+	// Сообщения без ключа пишут старые продюсеры — их принимаем как есть.
+	if expected := w.cfg.Key(&order); len(msg.Key) > 0 && !bytes.Equal(msg.Key, expected) {
+		cause := fmt.Errorf("message key %q does not match order key %q", msg.Key, expected)
+		slog.WarnContext(ctx, "message key mismatch, sending to DLQ", logging.KeyOrderID, order.Id, logging.KeyError, cause)
+		return w.sendToDLQ(ctx, dlq.New(msg, dlq.ReasonKeyMismatch, cause, order.Id, getRetries(msg)))
+	}
+
+	// без ID заказу некуда записать состояние, остальные поля проверяет стадия validate
+	if order.Id == "" {
+		cause := errors.New("order id is empty")
+		slog.WarnContext(ctx, "invalid order, sending to DLQ", logging.KeyError, cause)
+		return w.sendToDLQ(ctx, dlq.New(msg, dlq.ReasonValidation, cause, "", getRetries(msg)))
+	}
+
+	ctx = logging.With(ctx, logging.KeyOrderID, order.Id)
+	processed, err := w.rdb.Exists(ctx, processedKey(order.Id)).Result()
+	if err != nil {
+		return faults.Retryablef("check processed marker of %s: %w", order.Id, err)
+	}
+	if processed > 0 {
+		slog.InfoContext(ctx, "order already processed, skipping duplicate")
+		return nil
+	}
 
-		var order pb.OrderRequest
-		if err := proto.Unmarshal(msg.Value, &order); err != nil {
-			log.Printf("invalid message -> DLQ: %v", err)
-			w.dlqWriter.WriteMessages(w.ctx, kafka.Message{Value: msg.Value})
-			w.reader.CommitMessages(w.ctx, msg)
-			continue
+	retries := getRetries(msg)
+	attempt := retries + 1
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("order.id", order.Id), attribute.Int("order.attempt", attempt))
+	// из FAILED и DEAD_LETTERED обработку снова запускает только ручной replay из DLQ
+	transition := w.states.Transition
+	if _, replay := headerValue(msg, dlq.ReplayHeader); replay {
+		transition = w.states.Replay
+	}
+	if err := transition(ctx, order.Id, stateRecord(&order, nil, pb.OrderState_ORDER_STATE_PROCESSING, attempt, nil)); err != nil {
+		var terr *lifecycle.TransitionError
+		if errors.As(err, &terr) {
+			slog.InfoContext(ctx, "order is already past processing, skipping", "state", lifecycle.StatusName(terr.From))
+			return nil
 		}
+		return faults.AsRetryable(err)
+	}
+
+	slog.DebugContext(ctx, "processing order", "attempt", attempt)
+	res, cause := settings.Pipeline.Run(ctx, &pipeline.Order{
+		Request:    &order,
+		Normalized: settings.Validator.Normalize(&order),
+		Attempt:    attempt,
+	})
 
-		log.Printf("processing order %s", order.Id)
-		time.Sleep(300 * time.Millisecond)
-
-		if strings.HasPrefix(order.Item, "fail") {
-			retries := getRetries(msg)
-			if retries < maxRetries {
-				newMsg := kafka.Message{
-					Key:     msg.Key,
-					Value:   msg.Value,
-					Headers: updateRetriesHeader(msg, retries+1),
-				}
-				if err := w.writer.WriteMessages(w.ctx, newMsg); err != nil {
-					log.Printf("requeue failed: %v", err)
-				} else {
-					log.Printf("requeued %s (retry %d)", order.Id, retries+1)
-				}
-			} else {
-				w.dlqWriter.WriteMessages(w.ctx, kafka.Message{Value: msg.Value})
-				log.Printf("sent to DLQ: %s", order.Id)
+	if cause != nil {
+		kind, _ := faults.KindOf(cause)
+		switch {
+		case pipeline.IsSkip(cause):
+			slog.InfoContext(ctx, "stage skipped the rest of the pipeline, finishing early", "reason", cause)
+			w.cfg.Metrics.OrderProcessed("skipped")
+		case kind == faults.RateLimited:
+			// заказ остаётся в PROCESSING: пул приостановит чтение и повторит сообщение
+			w.cfg.Metrics.OrderProcessed("rate_limited")
+			return cause
+		case kind == faults.Permanent:
+			// сначала DLQ: из FAILED повтор сообщения заказ уже не выведет
+			if err := w.sendToDLQ(ctx, dlq.New(msg, permanentReason(cause), cause, order.Id, retries)); err != nil {
+				return err
+			}
+			if err := w.setState(ctx, &order, res.Totals, pb.OrderState_ORDER_STATE_FAILED, attempt, cause); err != nil {
+				return faults.AsRetryable(err)
 			}
-			w.reader.CommitMessages(w.ctx, msg)
-			continue
+			slog.WarnContext(ctx, "order failed permanently, sent to DLQ", logging.KeyError, cause)
+			w.cfg.Metrics.OrderProcessed("failed")
+			return nil
+		default:
+			return w.retryOrDeadLetter(ctx, msg, &order, res, attempt, settings.MaxRetries, cause)
 		}
+	}
 
-		res := &pb.ResultResponse{
-			Item:   order.Item,
-			Price:  order.Price,
-			Status: "done",
+	// без сохранённого результата сообщение не коммитится: пул повторит его, когда Redis вернётся
+	if err := w.setState(ctx, &order, res.Totals, pb.OrderState_ORDER_STATE_DONE, attempt, nil); err != nil {
+		return faults.AsRetryable(err)
+	}
+	// результат уже сохранён: без маркера повтор остановит переход DONE → PROCESSING
+	if err := w.rdb.Set(ctx, processedKey(order.Id), 1, settings.ProcessedTTL).Err(); err != nil {
+		slog.WarnContext(ctx, "set processed marker", logging.KeyError, err)
+	}
+	if !pipeline.IsSkip(cause) {
+		w.cfg.Metrics.OrderProcessed("done")
+	}
+	return nil
+}
+
+// retryOrDeadLetter отправляет заказ после временного сбоя в retry-топик,
+// а когда maxRetries попыток исчерпаны — в DLQ
+func (w *WorkerServer) retryOrDeadLetter(ctx context.Context, msg kafka.Message, order *pb.OrderRequest, res *pipeline.Order, attempt, maxRetries int, cause error) error {
+	retries := attempt - 1
+	if retries < maxRetries {
+		if err := w.setState(ctx, order, res.Totals, pb.OrderState_ORDER_STATE_RETRYING, attempt, cause); err != nil {
+			return faults.AsRetryable(err)
 		}
-		b, _ := protojson.Marshal(res)
-		if err := w.rdb.Set(w.ctx, "order:"+order.Id, b, 0).Err(); err != nil {
-			log.Printf("redis set error: %v", err)
+		if err := w.writer.WriteMessages(ctx, w.retryMessage(msg, attempt)); err != nil {
+			return faults.Retryablef("requeue order %s: %w", order.Id, err)
 		}
+		slog.WarnContext(ctx, "order requeued", "next_retry", attempt, "delay", w.cfg.Retry.Delay(attempt).String(), logging.KeyError, cause)
+		w.cfg.Metrics.OrderProcessed("retried")
+		return nil
+	}
 
-		w.reader.CommitMessages(w.ctx, msg)
+	// сначала DLQ: из DEAD_LETTERED повтор сообщения заказ уже не выведет
+	if err := w.sendToDLQ(ctx, dlq.New(msg, dlq.ReasonRetriesExhausted, cause, order.Id, retries)); err != nil {
+		return err
 	}
+	if err := w.setState(ctx, order, res.Totals, pb.OrderState_ORDER_STATE_DEAD_LETTERED, attempt, cause); err != nil {
+		return faults.AsRetryable(err)
+	}
+	slog.ErrorContext(ctx, "order retries exhausted, sent to DLQ", logging.KeyError, cause)
+	w.cfg.Metrics.OrderProcessed("dead_lettered")
+	return nil
 }
 
-// getRetries возвращает количество повторных попыток обработки сообщения из заголовка Kafka
-func getRetries(msg kafka.Message) int {
-	for _, h := range msg.Headers {
-		if strings.ToLower(h.Key) == "retries" {
-			v, _ := strconv.Atoi(string(h.Value))
-			return v
-		}
+// permanentReason выбирает причину DLQ для постоянной ошибки стадии
+func permanentReason(err error) dlq.Reason {
+	var verr *validation.Error
+	if errors.As(err, &verr) {
+		return dlq.ReasonValidation
 	}
-	return 0
+	return dlq.ReasonBusinessRule
 }
 
-// updateRetriesHeader обновляет или добавляет заголовок retries с новым значением
-func updateRetriesHeader(msg kafka.Message, retries int) []kafka.Header {
-	headers := []kafka.Header{}
-	found := false
-	for _, h := range msg.Headers {
-		if strings.ToLower(h.Key) == "retries" {
-			headers = append(headers, kafka.Header{
-				Key:   "retries",
-				Value: []byte(strconv.Itoa(retries)),
-			})
-			found = true
-		} else {
-			headers = append(headers, h)
+// setState переводит заказ в новое состояние. RETRYING пишется до записи в retry-топик:
+// если запись не удастся, сообщение будет доставлено повторно и пройдёт PROCESSING снова.
+// FAILED и DEAD_LETTERED пишутся только после записи в DLQ: выйти из них может лишь replay,
+// поэтому неудачная запись в DLQ оставляет заказ в PROCESSING, и повтор отправит конверт.
+// Если не удастся записать само состояние, повтор отправит конверт ещё раз.
+func (w *WorkerServer) setState(ctx context.Context, order *pb.OrderRequest, totals *pb.OrderTotals, state pb.OrderState, attempt int, cause error) error {
+	return w.states.Transition(ctx, order.Id, stateRecord(order, totals, state, attempt, cause))
+}
+
+// stateRecord — запись о заказе в состоянии state
+func stateRecord(order *pb.OrderRequest, totals *pb.OrderTotals, state pb.OrderState, attempt int, cause error) *pb.ResultResponse {
+	rec := &pb.ResultResponse{
+		Item:       order.Item,
+		Price:      order.Price,
+		State:      state,
+		Attempts:   int32(attempt),
+		CustomerId: order.CustomerId,
+		Totals:     totals,
+	}
+	if cause != nil {
+		rec.LastError = cause.Error()
+	}
+	return rec
+}
+
+// processedKey — маркер заказа, который воркер уже обработал
+func processedKey(id string) string {
+	return "order-processed:" + id
+}
+
+// sendToDLQ пишет конверт с исходным сообщением и причиной сбоя в DLQ
+func (w *WorkerServer) sendToDLQ(ctx context.Context, env dlq.Envelope) error {
+	msg, err := env.Message()
+	if err != nil {
+		return err
+	}
+	if err := w.dlqWriter.WriteMessages(ctx, msg); err != nil {
+		return faults.Retryablef("write to DLQ (%s, offset %d): %w", env.Reason, env.Offset, err)
+	}
+	w.cfg.Metrics.DLQWritten(string(env.Reason))
+	return nil
+}
+
+// close освобождает все подключения воркера
+func (w *WorkerServer) close() {
+	if err := w.reader.Close(); err != nil {
+		slog.Error("close kafka reader", logging.KeyError, err)
+	}
+	for _, reader := range w.retryReaders {
+		if err := reader.Close(); err != nil {
+			slog.Error("close kafka retry reader", logging.KeyError, err)
 		}
 	}
-	if !found {
-		headers = append(headers, kafka.Header{
-			Key:   "retries",
-			Value: []byte(strconv.Itoa(retries)),
-		})
+	if err := w.writer.Close(); err != nil {
+		slog.Error("close kafka writer", logging.KeyError, err)
+	}
+	if err := w.dlqWriter.Close(); err != nil {
+		slog.Error("close kafka DLQ writer", logging.KeyError, err)
+	}
+	if err := w.rdb.Close(); err != nil {
+		slog.Error("close redis", logging.KeyError, err)
+	}
+}
+
+// retryMessage готовит копию сообщения для попытки attempt: она уходит в retry-топик
+// и не обрабатывается раньше срока из заголовка not-before. Отметка replay не копируется:
+// заказ уже вышел из FAILED или DEAD_LETTERED, и повтор не должен выводить его оттуда снова.
+func (w *WorkerServer) retryMessage(msg kafka.Message, attempt int) kafka.Message {
+	due := time.Now().Add(w.cfg.Retry.Delay(attempt))
+	headers := deleteHeader(updateRetriesHeader(msg, attempt), dlq.ReplayHeader)
+	headers = setHeader(headers, notBeforeHeader, strconv.FormatInt(due.UnixMilli(), 10))
+
+	return kafka.Message{
+		Topic:   w.cfg.Retry.TopicFor(w.cfg.Topic, attempt),
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
 	}
-	return headers
+}
+
+// getRetries возвращает количество повторных попыток обработки сообщения из заголовка Kafka
+func getRetries(msg kafka.Message) int {
+	v, _ := headerValue(msg, retriesHeader)
+	n, _ := strconv.Atoi(v)
+	return n
+}
+
+// updateRetriesHeader обновляет или добавляет заголовок retries с новым значением
+func updateRetriesHeader(msg kafka.Message, retries int) []kafka.Header {
+	return setHeader(msg.Headers, retriesHeader, strconv.Itoa(retries))
 }
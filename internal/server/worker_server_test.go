@@ -0,0 +1,481 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-portfolio/order-pipeline/internal/dlq"
+	"github.com/go-portfolio/order-pipeline/internal/faults"
+	"github.com/go-portfolio/order-pipeline/internal/health"
+	"github.com/go-portfolio/order-pipeline/internal/lifecycle"
+	"github.com/go-portfolio/order-pipeline/internal/metrics"
+	"github.com/go-portfolio/order-pipeline/internal/pipeline"
+	"github.com/go-portfolio/order-pipeline/internal/tracing"
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeWriter запоминает записанные сообщения или возвращает заданную ошибку
+type fakeWriter struct {
+	mu   sync.Mutex
+	msgs []kafka.Message
+	err  error
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func TestHandleMessageWrapsUndecodableMessageInEnvelope(t *testing.T) {
+	dlqWriter := &fakeWriter{}
+	w := NewWorker(&fakeReader{}, &fakeWriter{}, dlqWriter, nil, WorkerConfig{Topic: "orders"})
+
+	msg := kafka.Message{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    17,
+		Key:       []byte("order-1"),
+		Value:     []byte{0xff, 0xff},
+		Headers:   []kafka.Header{{Key: "retries", Value: []byte("1")}},
+	}
+	require.NoError(t, w.handleMessage(context.Background(), msg))
+
+	require.Len(t, dlqWriter.msgs, 1)
+	out := dlqWriter.msgs[0]
+	require.Equal(t, msg.Key, out.Key)
+
+	env, err := dlq.Decode(out)
+	require.NoError(t, err)
+	require.Equal(t, dlq.ReasonUnmarshal, env.Reason)
+	require.NotEmpty(t, env.Error)
+	require.Equal(t, "orders", env.Topic)
+	require.Equal(t, 2, env.Partition)
+	require.Equal(t, int64(17), env.Offset)
+	require.Equal(t, 1, env.Retries)
+	require.Equal(t, msg.Value, env.Value)
+	require.Equal(t, msg.Headers, env.Original().Headers)
+	require.False(t, env.FailedAt.IsZero())
+}
+
+func TestHandleMessageReportsDLQWriteFailure(t *testing.T) {
+	dlqWriter := &fakeWriter{err: errors.New("broker down")}
+	w := NewWorker(&fakeReader{}, &fakeWriter{}, dlqWriter, nil, WorkerConfig{Topic: "orders"})
+
+	err := w.handleMessage(context.Background(), kafka.Message{Value: []byte{0xff}})
+	require.ErrorContains(t, err, "broker down")
+}
+
+func TestHandleMessageRetriesDLQHandoff(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		cfg   WorkerConfig
+		state pb.OrderState
+	}{
+		{
+			name:  "permanent error",
+			cfg:   WorkerConfig{Topic: "orders", Pipeline: pipeline.New(rejectStage{})},
+			state: pb.OrderState_ORDER_STATE_FAILED,
+		},
+		{
+			name:  "retries exhausted",
+			cfg:   WorkerConfig{Topic: "orders", Retry: RetryPolicy{MaxRetries: 0}},
+			state: pb.OrderState_ORDER_STATE_DEAD_LETTERED,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mr, rdb := newTestRedis(t)
+			dlqWriter := &fakeWriter{err: errors.New("broker down")}
+			w := NewWorker(&fakeReader{}, &fakeWriter{}, dlqWriter, rdb, tc.cfg)
+			ctx := context.Background()
+
+			b, err := proto.Marshal(&pb.OrderRequest{Id: "order-11", Item: "fail-item", Price: 1})
+			require.NoError(t, err)
+			msg := kafka.Message{Key: []byte("order-11"), Value: b}
+
+			// DLQ недоступна — сообщение не коммитится, заказ не становится итоговым
+			require.ErrorContains(t, w.handleMessage(ctx, msg), "broker down")
+			require.Equal(t, pb.OrderState_ORDER_STATE_PROCESSING, readState(t, mr, "order-11").State)
+
+			dlqWriter.err = nil
+			require.NoError(t, w.handleMessage(ctx, msg))
+			require.Len(t, dlqWriter.msgs, 1)
+			require.Equal(t, tc.state, readState(t, mr, "order-11").State)
+		})
+	}
+}
+
+func TestHandleMessageSkipsProcessedOrders(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	w := NewWorker(&fakeReader{}, &fakeWriter{}, &fakeWriter{}, rdb, WorkerConfig{Topic: "orders", ProcessedTTL: time.Hour})
+
+	b, err := proto.Marshal(&pb.OrderRequest{Id: "order-3", Item: "book", Price: 42})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, w.handleMessage(ctx, kafka.Message{Value: b}))
+	require.True(t, mr.Exists("order:order-3"))
+	require.True(t, mr.Exists(processedKey("order-3")))
+	require.Equal(t, time.Hour, mr.TTL(processedKey("order-3")))
+
+	// повторная доставка не перезаписывает результат
+	mr.Set("order:order-3", "kept")
+	require.NoError(t, w.handleMessage(ctx, kafka.Message{Value: b}))
+	got, err := mr.Get("order:order-3")
+	require.NoError(t, err)
+	require.Equal(t, "kept", got)
+}
+
+func TestHandleMessageRejectsMismatchedKey(t *testing.T) {
+	dlqWriter := &fakeWriter{}
+	w := NewWorker(&fakeReader{}, &fakeWriter{}, dlqWriter, nil, WorkerConfig{Topic: "orders"})
+
+	b, err := proto.Marshal(&pb.OrderRequest{Id: "order-4", Item: "book", Price: 1})
+	require.NoError(t, err)
+	require.NoError(t, w.handleMessage(context.Background(), kafka.Message{Key: []byte("order-5"), Value: b}))
+
+	require.Len(t, dlqWriter.msgs, 1)
+	env, err := dlq.Decode(dlqWriter.msgs[0])
+	require.NoError(t, err)
+	require.Equal(t, dlq.ReasonKeyMismatch, env.Reason)
+	require.Equal(t, "order-4", env.OrderID)
+	require.Equal(t, []byte("order-5"), dlqWriter.msgs[0].Key)
+}
+
+func TestHandleMessageRejectsInvalidOrder(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	dlqWriter := &fakeWriter{}
+	w := NewWorker(&fakeReader{}, &fakeWriter{}, dlqWriter, rdb, WorkerConfig{Topic: "orders"})
+
+	// заказ записан в топик в обход приёмника
+	b, err := proto.Marshal(&pb.OrderRequest{Id: "order-7", Item: "", Price: -1})
+	require.NoError(t, err)
+	require.NoError(t, w.handleMessage(context.Background(), kafka.Message{Key: []byte("order-7"), Value: b}))
+
+	require.Len(t, dlqWriter.msgs, 1)
+	env, err := dlq.Decode(dlqWriter.msgs[0])
+	require.NoError(t, err)
+	require.Equal(t, dlq.ReasonValidation, env.Reason)
+	require.Contains(t, env.Error, "item: is required")
+	require.Equal(t, pb.OrderState_ORDER_STATE_FAILED, readState(t, mr, "order-7").State)
+}
+
+func TestHandleMessageRecordsRetryAndDeadLetterStates(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	writer, dlqWriter := &fakeWriter{}, &fakeWriter{}
+	w := NewWorker(&fakeReader{}, writer, dlqWriter, rdb, WorkerConfig{
+		Topic: "orders",
+		Retry: RetryPolicy{MaxRetries: 1, Backoff: []time.Duration{time.Second}},
+	})
+	ctx := context.Background()
+
+	b, err := proto.Marshal(&pb.OrderRequest{Id: "order-6", Item: "fail-item", Price: 7})
+	require.NoError(t, err)
+	msg := kafka.Message{Key: []byte("order-6"), Value: b}
+
+	require.NoError(t, w.handleMessage(ctx, msg))
+	state := readState(t, mr, "order-6")
+	require.Equal(t, pb.OrderState_ORDER_STATE_RETRYING, state.State)
+	require.Equal(t, int32(1), state.Attempts)
+	require.Contains(t, state.LastError, "fail-item")
+	require.Len(t, writer.msgs, 1)
+
+	require.NoError(t, w.handleMessage(ctx, writer.msgs[0]))
+	state = readState(t, mr, "order-6")
+	require.Equal(t, pb.OrderState_ORDER_STATE_DEAD_LETTERED, state.State)
+	require.Equal(t, int32(2), state.Attempts)
+	require.Len(t, dlqWriter.msgs, 1)
+
+	// повторная доставка не выводит заказ из DEAD_LETTERED, ручной replay — выводит
+	require.NoError(t, w.handleMessage(ctx, writer.msgs[0]))
+	require.Len(t, dlqWriter.msgs, 1)
+	env, err := dlq.Decode(dlqWriter.msgs[0])
+	require.NoError(t, err)
+	require.NoError(t, w.handleMessage(ctx, dlq.ReplayMessage(env, "orders")))
+	state = readState(t, mr, "order-6")
+	require.Equal(t, pb.OrderState_ORDER_STATE_RETRYING, state.State)
+	require.Equal(t, int32(1), state.Attempts)
+	_, replay := headerValue(writer.msgs[1], dlq.ReplayHeader)
+	require.False(t, replay, "retry copies do not carry the replay mark")
+}
+
+func TestApplyChangesMaxRetriesForNextMessages(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	writer, dlqWriter := &fakeWriter{}, &fakeWriter{}
+	w := NewWorker(&fakeReader{}, writer, dlqWriter, rdb, WorkerConfig{
+		Topic: "orders",
+		Retry: RetryPolicy{MaxRetries: 3, Backoff: []time.Duration{time.Second}},
+	})
+	w.Apply(WorkerSettings{Concurrency: 1, MaxRetries: 0, ProcessedTTL: time.Hour})
+
+	b, err := proto.Marshal(&pb.OrderRequest{Id: "order-8", Item: "fail-item", Price: 7})
+	require.NoError(t, err)
+	require.NoError(t, w.handleMessage(context.Background(), kafka.Message{Key: []byte("order-8"), Value: b}))
+
+	// повторов больше нет: заказ сразу уходит в DLQ, правила и стадии остались прежними
+	require.Empty(t, writer.msgs)
+	require.Len(t, dlqWriter.msgs, 1)
+	require.Equal(t, pb.OrderState_ORDER_STATE_DEAD_LETTERED, readState(t, mr, "order-8").State)
+}
+
+// readState читает запись о заказе из Redis
+func readState(t *testing.T, mr *miniredis.Miniredis, id string) *pb.ResultResponse {
+	t.Helper()
+	raw, err := mr.Get(lifecycle.Key(id))
+	require.NoError(t, err)
+	var res pb.ResultResponse
+	require.NoError(t, protojson.Unmarshal([]byte(raw), &res))
+	return &res
+}
+
+func TestHandleMessageRecordsTotals(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	w := NewWorker(&fakeReader{}, &fakeWriter{}, &fakeWriter{}, rdb, WorkerConfig{Topic: "orders"})
+	ctx := context.Background()
+
+	order := &pb.OrderRequest{
+		Id:            "order-8",
+		SchemaVersion: 2,
+		Currency:      "EUR",
+		CustomerId:    "cust-1",
+		LineItems: []*pb.LineItem{
+			{Sku: "book", Quantity: 2, UnitPrice: &pb.Money{Currency: "EUR", MinorUnits: 1250}},
+			{Sku: "pen", Quantity: 1, UnitPrice: &pb.Money{Currency: "EUR", MinorUnits: 199}},
+		},
+	}
+	b, err := proto.Marshal(order)
+	require.NoError(t, err)
+	require.NoError(t, w.handleMessage(ctx, kafka.Message{Key: []byte("order-8"), Value: b}))
+
+	state := readState(t, mr, "order-8")
+	require.Equal(t, pb.OrderState_ORDER_STATE_DONE, state.State)
+	require.Equal(t, "cust-1", state.CustomerId)
+	require.Equal(t, int64(2500), state.Totals.Lines[0].Subtotal.MinorUnits)
+	require.True(t, proto.Equal(&pb.Money{Currency: "EUR", MinorUnits: 2699}, state.Totals.Total), state.Totals.Total)
+
+	// сообщение старого формата считается как одна строка в валюте по умолчанию
+	b, err = proto.Marshal(&pb.OrderRequest{Id: "order-9", Item: "book", Price: 42})
+	require.NoError(t, err)
+	require.NoError(t, w.handleMessage(ctx, kafka.Message{Key: []byte("order-9"), Value: b}))
+	state = readState(t, mr, "order-9")
+	require.Equal(t, int32(42), state.Price)
+	require.True(t, proto.Equal(&pb.Money{Currency: "USD", MinorUnits: 4200}, state.Totals.Total), state.Totals.Total)
+}
+
+// rejectStage — стадия, которая отклоняет любой заказ
+type rejectStage struct{}
+
+func (rejectStage) Name() string { return "reject" }
+
+func (rejectStage) Process(context.Context, *pipeline.Order) (*pipeline.Order, error) {
+	return nil, pipeline.Permanent(errors.New("out of stock"))
+}
+
+func TestHandleMessageRoutesPermanentStageErrorToDLQ(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	dlqWriter := &fakeWriter{}
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg)
+	w := NewWorker(&fakeReader{}, &fakeWriter{}, dlqWriter, rdb, WorkerConfig{
+		Topic:    "orders",
+		Retry:    RetryPolicy{MaxRetries: 3, Backoff: []time.Duration{time.Second}},
+		Pipeline: pipeline.New(pipeline.PriceStage{}, rejectStage{}).Observe(m.ObserveStage),
+		Metrics:  m,
+	})
+
+	b, err := proto.Marshal(&pb.OrderRequest{Id: "order-10", Item: "book", Price: 1})
+	require.NoError(t, err)
+	require.NoError(t, w.handleMessage(context.Background(), kafka.Message{Key: []byte("order-10"), Value: b}))
+
+	// постоянная ошибка не повторяется
+	require.Len(t, dlqWriter.msgs, 1)
+	env, err := dlq.Decode(dlqWriter.msgs[0])
+	require.NoError(t, err)
+	require.Equal(t, dlq.ReasonBusinessRule, env.Reason)
+	state := readState(t, mr, "order-10")
+	require.Equal(t, pb.OrderState_ORDER_STATE_FAILED, state.State)
+	require.Equal(t, "stage reject: out of stock", state.LastError)
+	require.NotNil(t, state.Totals)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP orders_processed_total Orders handled by the worker by result: done, retried, dead_lettered, failed, skipped, rate_limited.
+# TYPE orders_processed_total counter
+orders_processed_total{result="failed"} 1
+# HELP orders_dlq_messages_total Messages written to the DLQ by reason.
+# TYPE orders_dlq_messages_total counter
+orders_dlq_messages_total{reason="business_rule"} 1
+`), "orders_processed_total", "orders_dlq_messages_total"))
+	// время учтено для каждой стадии со своим исходом
+	require.Equal(t, 2, testutil.CollectAndCount(reg, "orders_stage_duration_seconds"))
+}
+
+// breakRedisStage ломает Redis посреди обработки, как при обрыве соединения
+type breakRedisStage struct{ mr *miniredis.Miniredis }
+
+func (breakRedisStage) Name() string { return "break_redis" }
+
+func (s breakRedisStage) Process(_ context.Context, o *pipeline.Order) (*pipeline.Order, error) {
+	s.mr.SetError("LOADING Redis is loading the dataset in memory")
+	return o, nil
+}
+
+func TestHandleMessageDoesNotFinishWithoutStoredResult(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	w := NewWorker(&fakeReader{}, &fakeWriter{}, &fakeWriter{}, rdb, WorkerConfig{
+		Topic:    "orders",
+		Pipeline: pipeline.New(pipeline.PriceStage{}, breakRedisStage{mr}),
+	})
+	ctx := context.Background()
+
+	b, err := proto.Marshal(&pb.OrderRequest{Id: "order-11", Item: "book", Price: 1})
+	require.NoError(t, err)
+	msg := kafka.Message{Key: []byte("order-11"), Value: b}
+
+	// результат не записан — ошибка временная, пул не закоммитит сообщение
+	err = w.handleMessage(ctx, msg)
+	kind, ok := faults.KindOf(err)
+	require.True(t, ok, "unclassified error: %v", err)
+	require.Equal(t, faults.Retryable, kind)
+	mr.SetError("")
+	require.Equal(t, pb.OrderState_ORDER_STATE_PROCESSING, readState(t, mr, "order-11").State)
+
+	// повтор после восстановления Redis доводит заказ до конца
+	w.Apply(WorkerSettings{Pipeline: pipeline.New(pipeline.PriceStage{})})
+	require.NoError(t, w.handleMessage(ctx, msg))
+	require.Equal(t, pb.OrderState_ORDER_STATE_DONE, readState(t, mr, "order-11").State)
+}
+
+func TestHandleMessageContinuesTraceAcrossRetries(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	_, rdb := newTestRedis(t)
+	rdb.AddHook(tracing.RedisHook())
+	retryWriter, dlqWriter := &fakeWriter{}, &fakeWriter{}
+	w := NewWorker(&fakeReader{}, tracing.InstrumentWriter(retryWriter, "orders-retry"), tracing.InstrumentWriter(dlqWriter, "orders-dlq"), rdb, WorkerConfig{
+		Topic: "orders",
+		Retry: RetryPolicy{MaxRetries: 1, Backoff: []time.Duration{time.Second}},
+	})
+
+	// заказ публикует приёмник внутри своего запроса
+	ctx, root := tracing.Tracer().Start(context.Background(), "CreateOrder")
+	b, err := proto.Marshal(&pb.OrderRequest{Id: "order-11", Item: "fail-item", Price: 7})
+	require.NoError(t, err)
+	published := &fakeWriter{}
+	require.NoError(t, tracing.InstrumentWriter(published, "orders").WriteMessages(ctx, kafka.Message{Key: []byte("order-11"), Value: b}))
+	root.End()
+
+	require.NoError(t, w.handleMessage(context.Background(), published.msgs[0]))
+	require.Len(t, retryWriter.msgs, 1)
+	require.NoError(t, w.handleMessage(context.Background(), retryWriter.msgs[0]))
+	require.Len(t, dlqWriter.msgs, 1)
+
+	byName := map[string][]sdktrace.ReadOnlySpan{}
+	for _, s := range rec.Ended() {
+		// все спаны — от gRPC-запроса до записи в DLQ — в одной трассе
+		require.Equal(t, root.SpanContext().TraceID(), s.SpanContext().TraceID(), s.Name())
+		byName[s.Name()] = append(byName[s.Name()], s)
+	}
+	origin := byName["publish orders"][0]
+	processed := byName["orders process"]
+	require.Len(t, processed, 2)
+	require.Equal(t, origin.SpanContext().SpanID(), processed[0].Parent().SpanID())
+	require.Empty(t, processed[0].Links())
+
+	// повтор продолжает трассу от спана повтора и ссылается на исходную публикацию
+	retry := byName["publish orders-retry"][0]
+	require.Equal(t, retry.SpanContext().SpanID(), processed[1].Parent().SpanID())
+	require.Len(t, processed[1].Links(), 1)
+	require.Equal(t, origin.SpanContext().SpanID(), processed[1].Links()[0].SpanContext.SpanID())
+
+	require.Len(t, byName["publish orders-dlq"], 1)
+	require.Len(t, byName["stage simulate"], 2)
+	require.Equal(t, processed[0].SpanContext().SpanID(), byName["stage simulate"][0].Parent().SpanID())
+	require.NotEmpty(t, byName["redis evalsha"])
+}
+
+func TestWorkerHealthChecksReportConsumerLoop(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	require.NoError(t, mr.Set(processedKey("order-12"), "1"))
+	b, err := proto.Marshal(&pb.OrderRequest{Id: "order-12", Item: "book", Price: 1})
+	require.NoError(t, err)
+	reader := &fakeReader{msgs: []kafka.Message{{Key: []byte("order-12"), Value: b}}}
+	w := NewWorker(reader, &fakeWriter{}, &fakeWriter{}, rdb, WorkerConfig{Topic: "orders"})
+	ready := health.NewMonitor(time.Minute, time.Second, w.ReadinessChecks()...)
+
+	report := ready.CheckNow(context.Background())
+	require.False(t, report.Ready, "consumer loop is not running yet")
+	require.Equal(t, "consumer loop is not running", report.Checks[0].Error)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+	require.Eventually(t, func() bool { return reader.committed(0) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	report = ready.CheckNow(context.Background())
+	require.True(t, report.Ready, "%+v", report.Checks)
+	require.Contains(t, report.Checks[0].Detail, "last fetch at")
+
+	cancel()
+	require.NoError(t, <-done)
+	require.False(t, health.NewMonitor(time.Minute, time.Second, w.LivenessChecks()...).CheckNow(context.Background()).Ready)
+}
+
+func TestCustomerIDKeyKeepsCustomerOnOneLane(t *testing.T) {
+	key, err := KeyFuncByName("customer_id")
+	require.NoError(t, err)
+	pool := newWorkerPool(&fakeReader{}, 8, nil)
+
+	var lanes []int
+	for i := range 10 {
+		order := &pb.OrderRequest{Id: "order-" + strconv.Itoa(i), CustomerId: "cust-1"}
+		lanes = append(lanes, pool.laneFor(kafka.Message{Topic: "orders", Partition: i % 3, Key: key(order)}))
+	}
+	for _, lane := range lanes {
+		require.Equal(t, lanes[0], lane, "orders of one customer are handled by one lane in order")
+	}
+	require.Equal(t, []byte("order-x"), key(&pb.OrderRequest{Id: "order-x"}), "orders without a customer fall back to the order id")
+
+	// воркер с той же стратегией принимает такой ключ, а не отправляет заказ в DLQ
+	mr, rdb := newTestRedis(t)
+	dlqWriter := &fakeWriter{}
+	w := NewWorker(&fakeReader{}, &fakeWriter{}, dlqWriter, rdb, WorkerConfig{
+		Topic:    "orders",
+		Key:      key,
+		Pipeline: pipeline.New(pipeline.PriceStage{}),
+	})
+	order := &pb.OrderRequest{Id: "order-12", Item: "book", Price: 3, CustomerId: "cust-1"}
+	b, err := proto.Marshal(order)
+	require.NoError(t, err)
+	require.NoError(t, w.handleMessage(context.Background(), kafka.Message{Key: key(order), Value: b}))
+	require.Empty(t, dlqWriter.msgs)
+	require.Equal(t, pb.OrderState_ORDER_STATE_DONE, readState(t, mr, "order-12").State)
+}
@@ -0,0 +1,87 @@
+package tlsutil
+
+import (
+	"context"
+
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Identity — клиент, подтверждённый сертификатом при mTLS
+type Identity struct {
+	// Subject — subject сертификата в виде RFC 2253, например "CN=billing,O=acme"
+	Subject string
+	// CommonName — CN из subject, обычно имя сервиса-клиента
+	CommonName string
+	// Organizations — O из subject
+	Organizations []string
+	// SerialNumber — серийный номер сертификата в десятичном виде
+	SerialNumber string
+}
+
+// PeerIdentity достаёт личность клиента из проверенной цепочки сертификатов соединения.
+// false — соединение без TLS или клиент не предъявил проверенный сертификат.
+func PeerIdentity(ctx context.Context) (Identity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return Identity{}, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return Identity{}, false
+	}
+	leaf := info.State.VerifiedChains[0][0]
+	return Identity{
+		Subject:       leaf.Subject.String(),
+		CommonName:    leaf.Subject.CommonName,
+		Organizations: leaf.Subject.Organization,
+		SerialNumber:  leaf.SerialNumber.String(),
+	}, true
+}
+
+type identityKey struct{}
+
+// WithIdentity сохраняет личность клиента в контексте запроса
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// ClientIdentity возвращает личность клиента, сохранённую интерсептором
+func ClientIdentity(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// withPeer кладёт личность клиента в контекст и добавляет её в поля логов
+func withPeer(ctx context.Context) context.Context {
+	id, ok := PeerIdentity(ctx)
+	if !ok {
+		return ctx
+	}
+	return logging.With(WithIdentity(ctx, id), logging.KeyClient, id.Subject)
+}
+
+// UnaryServerInterceptor делает личность клиента из сертификата доступной обработчикам
+// через ClientIdentity и добавляет её в логи запроса
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withPeer(ctx), req)
+	}
+}
+
+// StreamServerInterceptor делает то же для потоковых вызовов
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &identityStream{ServerStream: ss, ctx: withPeer(ss.Context())})
+	}
+}
+
+// identityStream подменяет контекст потока
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,190 @@
+// Package tlsutil включает TLS и mTLS для gRPC-серверов: сертификаты читаются из файлов
+// и подменяются при ротации без перезапуска, а личность клиента берётся из его сертификата.
+package tlsutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-portfolio/order-pipeline/internal/logging"
+	"google.golang.org/grpc/credentials"
+)
+
+// Config — пути к файлам сертификатов сервера
+type Config struct {
+	// CertFile и KeyFile — сертификат и ключ сервера в PEM; пустой CertFile выключает TLS
+	CertFile string
+	KeyFile  string
+	// ClientCAFile — CA клиентских сертификатов; если задан, включён mTLS
+	// и клиент без сертификата, подписанного этим CA, не подключится
+	ClientCAFile string
+}
+
+// Enabled сообщает, включён ли TLS
+func (c Config) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// Server отдаёт текущие сертификат и CA клиентов каждому новому соединению.
+// Reload перечитывает файлы; установленные соединения продолжают работать со старыми.
+type Server struct {
+	cfg Config
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	// files — содержимое файлов последней удачной загрузки
+	files [][]byte
+}
+
+// NewServer читает сертификаты; ошибка — файлы не читаются или не подходят друг другу
+func NewServer(cfg Config) (*Server, error) {
+	s := &Server{cfg: cfg}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Credentials — транспорт gRPC-сервера с текущими сертификатами
+func (s *Server) Credentials() credentials.TransportCredentials {
+	return credentials.NewTLS(&tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: s.configForClient,
+	})
+}
+
+// configForClient собирает настройки одного соединения из текущих сертификатов
+func (s *Server) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*s.cert},
+		// без h2 в ALPN клиенты gRPC разрывают соединение
+		NextProtos: []string{"h2"},
+	}
+	if s.clientCAs != nil {
+		cfg.ClientCAs = s.clientCAs
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// Reload перечитывает файлы и, если они изменились, подменяет сертификаты.
+// При ошибке остаются прежние сертификаты — например, если ключ ещё не дописан.
+func (s *Server) Reload() (bool, error) {
+	paths := []string{s.cfg.CertFile, s.cfg.KeyFile}
+	if s.cfg.ClientCAFile != "" {
+		paths = append(paths, s.cfg.ClientCAFile)
+	}
+	files := make([][]byte, len(paths))
+	for i, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return false, fmt.Errorf("tls: %w", err)
+		}
+		files[i] = data
+	}
+
+	s.mu.RLock()
+	unchanged := s.files != nil && equalFiles(s.files, files)
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(files[0], files[1])
+	if err != nil {
+		return false, fmt.Errorf("tls: load %s and %s: %w", s.cfg.CertFile, s.cfg.KeyFile, err)
+	}
+	var pool *x509.CertPool
+	if s.cfg.ClientCAFile != "" {
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(files[2]) {
+			return false, fmt.Errorf("tls: no certificates in %s", s.cfg.ClientCAFile)
+		}
+	}
+
+	s.mu.Lock()
+	s.cert, s.clientCAs, s.files = &cert, pool, files
+	s.mu.Unlock()
+	return true, nil
+}
+
+// Run проверяет файлы каждые interval до отмены ctx и подменяет сертификаты после ротации
+func (s *Server) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := s.Reload()
+		switch {
+		case err != nil:
+			slog.Error("reload TLS certificates, keeping the previous ones", logging.KeyError, err)
+		case changed:
+			slog.Info("TLS certificates reloaded", "cert", s.cfg.CertFile, "client_ca", s.cfg.ClientCAFile, "not_after", s.NotAfter())
+		}
+	}
+}
+
+// NotAfter — срок действия текущего сертификата сервера
+func (s *Server) NotAfter() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cert.Leaf == nil {
+		return time.Time{}
+	}
+	return s.cert.Leaf.NotAfter
+}
+
+func equalFiles(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ClientConfig — настройки TLS клиента: caFile проверяет сервер (пусто — системные CA),
+// certFile и keyFile — сертификат клиента для mTLS (пусто — без него)
+func ClientConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates in %s", caFile)
+		}
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("tls: client certificate and key must be set together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,193 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testCA — одноразовый удостоверяющий центр, живущий только в памяти теста
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue выпускает сертификат сервера (для 127.0.0.1) или клиента; возвращает PEM сертификата и ключа
+func (ca *testCA) issue(t *testing.T, serial int64, subject pkix.Name, client bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if client {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		tmpl.IPAddresses = nil
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFiles(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+	}
+}
+
+func TestMutualTLSExposesClientIdentity(t *testing.T) {
+	ca := newCA(t)
+	dir := t.TempDir()
+	serverCert, serverKey := ca.issue(t, 2, pkix.Name{CommonName: "ordercache"}, false)
+	clientCert, clientKey := ca.issue(t, 3, pkix.Name{CommonName: "billing", Organization: []string{"acme"}}, true)
+	writeFiles(t, dir, map[string][]byte{
+		"ca.pem": ca.pem, "server.pem": serverCert, "server-key.pem": serverKey,
+		"client.pem": clientCert, "client-key.pem": clientKey,
+	})
+
+	certs, err := NewServer(Config{
+		CertFile:     filepath.Join(dir, "server.pem"),
+		KeyFile:      filepath.Join(dir, "server-key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	})
+	require.NoError(t, err)
+
+	seen := make(chan Identity, 1)
+	srv := grpc.NewServer(
+		grpc.Creds(certs.Credentials()),
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(), func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			id, _ := ClientIdentity(ctx)
+			seen <- id
+			return handler(ctx, req)
+		}),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	check := func(certFile, keyFile string) error {
+		cfg, err := ClientConfig(filepath.Join(dir, "ca.pem"), certFile, keyFile)
+		require.NoError(t, err)
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(cfg)))
+		require.NoError(t, err)
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	require.NoError(t, check(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")))
+	id := <-seen
+	require.Equal(t, "billing", id.CommonName)
+	require.Equal(t, "CN=billing,O=acme", id.Subject)
+	require.Equal(t, []string{"acme"}, id.Organizations)
+	require.Equal(t, "3", id.SerialNumber)
+
+	// без клиентского сертификата рукопожатие не проходит
+	require.Error(t, check("", ""))
+}
+
+func TestServerPicksUpRotatedCertificate(t *testing.T) {
+	ca := newCA(t)
+	dir := t.TempDir()
+	cert, key := ca.issue(t, 10, pkix.Name{CommonName: "orderreceiver"}, false)
+	writeFiles(t, dir, map[string][]byte{"server.pem": cert, "server-key.pem": key})
+
+	certs, err := NewServer(Config{CertFile: filepath.Join(dir, "server.pem"), KeyFile: filepath.Join(dir, "server-key.pem")})
+	require.NoError(t, err)
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetConfigForClient: certs.configForClient})
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	// serial возвращает серийный номер сертификата, который сервер предъявляет новому соединению
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	serial := func() int64 {
+		conn, err := tls.Dial("tcp", lis.Addr().String(), &tls.Config{RootCAs: pool})
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+	require.EqualValues(t, 10, serial())
+
+	changed, err := certs.Reload()
+	require.NoError(t, err)
+	require.False(t, changed, "files did not change")
+
+	cert, key = ca.issue(t, 11, pkix.Name{CommonName: "orderreceiver"}, false)
+	writeFiles(t, dir, map[string][]byte{"server.pem": cert, "server-key.pem": key})
+	changed, err = certs.Reload()
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.EqualValues(t, 11, serial())
+
+	// ключ от другого сертификата — ротация не закончена, остаётся прежний
+	_, otherKey := ca.issue(t, 12, pkix.Name{CommonName: "orderreceiver"}, false)
+	writeFiles(t, dir, map[string][]byte{"server-key.pem": otherKey})
+	_, err = certs.Reload()
+	require.Error(t, err)
+	require.EqualValues(t, 11, serial())
+}
@@ -0,0 +1,165 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OriginHeader хранит traceparent спана, который впервые опубликовал заказ.
+// Повторные попытки продолжают трассу от спана повтора и ссылаются на исходный спан через него.
+const OriginHeader = "origin-traceparent"
+
+// traceparentHeader — заголовок W3C Trace Context
+const traceparentHeader = "traceparent"
+
+// HeaderCarrier переносит контекст трассировки в заголовках сообщения Kafka
+type HeaderCarrier struct {
+	Headers *[]kafka.Header
+}
+
+var _ propagation.TextMapCarrier = HeaderCarrier{}
+
+func (c HeaderCarrier) Get(key string) string {
+	for _, h := range *c.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set заменяет заголовок key; заголовки копируются, чтобы не менять исходное сообщение
+func (c HeaderCarrier) Set(key, value string) {
+	headers := make([]kafka.Header, 0, len(*c.Headers)+1)
+	for _, h := range *c.Headers {
+		if h.Key != key {
+			headers = append(headers, h)
+		}
+	}
+	*c.Headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, len(*c.Headers))
+	for i, h := range *c.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// Inject записывает контекст спана из ctx в заголовки msg. При первой публикации
+// тот же контекст запоминается в OriginHeader.
+func Inject(ctx context.Context, msg *kafka.Message) {
+	carrier := HeaderCarrier{Headers: &msg.Headers}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if carrier.Get(OriginHeader) == "" {
+		if tp := carrier.Get(traceparentHeader); tp != "" {
+			carrier.Set(OriginHeader, tp)
+		}
+	}
+}
+
+// Extract возвращает ctx с удалённым контекстом спана из заголовков msg
+func Extract(ctx context.Context, msg kafka.Message) context.Context {
+	headers := msg.Headers
+	return otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier{Headers: &headers})
+}
+
+// originLink — ссылка на спан первой публикации, если сообщение уже повторялось
+func originLink(msg kafka.Message) (trace.Link, bool) {
+	headers := msg.Headers
+	carrier := HeaderCarrier{Headers: &headers}
+	origin := carrier.Get(OriginHeader)
+	if origin == "" || origin == carrier.Get(traceparentHeader) {
+		return trace.Link{}, false
+	}
+	sc := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(),
+		propagation.MapCarrier{traceparentHeader: origin}))
+	if !sc.IsValid() {
+		return trace.Link{}, false
+	}
+	return trace.Link{SpanContext: sc, Attributes: []attribute.KeyValue{attribute.String("link.kind", "origin")}}, true
+}
+
+// messageAttributes — атрибуты спана о сообщении Kafka
+func messageAttributes(msg kafka.Message) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", msg.Topic),
+		attribute.Int("messaging.kafka.destination.partition", msg.Partition),
+		attribute.Int64("messaging.kafka.message.offset", msg.Offset),
+	}
+}
+
+// StartConsume начинает спан обработки сообщения как дочерний к контексту из его
+// заголовков. Повтор ссылается на спан первой публикации заказа.
+func StartConsume(ctx context.Context, msg kafka.Message) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(messageAttributes(msg)...),
+	}
+	if link, ok := originLink(msg); ok {
+		opts = append(opts, trace.WithLinks(link))
+	}
+	return Tracer().Start(Extract(ctx, msg), "orders process", opts...)
+}
+
+// StartPublish начинает спан записи в топик; контекст спана затем передаётся в Inject
+func StartPublish(ctx context.Context, topic string, count int) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, "publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", topic),
+			attribute.Int("messaging.batch.message_count", count),
+		))
+}
+
+// RecordFetch записывает спан получения сообщения из Kafka, начатого в start
+func RecordFetch(ctx context.Context, msg kafka.Message, start time.Time) {
+	_, span := Tracer().Start(Extract(ctx, msg), "fetch "+msg.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(messageAttributes(msg)...),
+		trace.WithAttributes(attribute.String("messaging.kafka.message.key", string(msg.Key))),
+	)
+	span.End()
+}
+
+// Writer — запись в Kafka, как server.KafkaWriter
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type tracedWriter struct {
+	Writer
+	topic string
+}
+
+// InstrumentWriter пишет спан публикации на каждый вызов WriteMessages и передаёт
+// его контекст в заголовках всех сообщений; topic — имя топика для спана
+func InstrumentWriter(w Writer, topic string) Writer {
+	return &tracedWriter{Writer: w, topic: topic}
+}
+
+func (w *tracedWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	ctx, span := StartPublish(ctx, w.topic, len(msgs))
+	defer span.End()
+
+	// сообщения копируются: заголовки вызывающего не меняются
+	traced := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		Inject(ctx, &msg)
+		traced[i] = msg
+	}
+	err := w.Writer.WriteMessages(ctx, traced...)
+	Fail(span, err)
+	return err
+}
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redisHook пишет спан на каждую команду Redis; подключается через rdb.AddHook(tracing.RedisHook())
+type redisHook struct{}
+
+// RedisHook возвращает хук go-redis для трассировки команд
+func RedisHook() redis.Hook {
+	return redisHook{}
+}
+
+func (redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := startRedis(ctx, cmd.Name(), 1)
+		defer span.End()
+		err := next(ctx, cmd)
+		failRedis(span, err)
+		return err
+	}
+}
+
+func (redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := startRedis(ctx, "pipeline", len(cmds))
+		defer span.End()
+		err := next(ctx, cmds)
+		failRedis(span, err)
+		return err
+	}
+}
+
+func startRedis(ctx context.Context, command string, count int) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, "redis "+strings.ToLower(command),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation.name", command),
+			attribute.Int("db.operation.batch.size", count),
+		))
+}
+
+// failRedis отмечает ошибку команды; отсутствующий ключ ошибкой не считается
+func failRedis(span trace.Span, err error) {
+	if errors.Is(err, redis.Nil) {
+		return
+	}
+	Fail(span, err)
+}
@@ -0,0 +1,99 @@
+// Package tracing настраивает OpenTelemetry и переносит контекст трассировки
+// через gRPC, заголовки Kafka и команды Redis.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName — имя трейсера всех спанов конвейера
+const instrumentationName = "github.com/go-portfolio/order-pipeline"
+
+// Экспортёры спанов
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// Config задаёт экспорт спанов сервиса
+type Config struct {
+	// ServiceName — значение service.name в каждом спане
+	ServiceName string
+	// Exporter — none, stdout или file; пусто означает none
+	Exporter string
+	// File — путь файла для экспортёра file; спаны дописываются в конец по одному JSON в строке
+	File string
+}
+
+// Setup регистрирует глобальные пропагатор W3C Trace Context и провайдер спанов.
+// Пропагатор ставится всегда, поэтому контекст входящих запросов передаётся дальше
+// даже без экспорта. Возвращённая функция сбрасывает накопленные спаны и закрывает файл.
+func Setup(cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var out io.Writer
+	closeOut := func() error { return nil }
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		out = os.Stdout
+	case ExporterFile:
+		if cfg.File == "" {
+			return nil, fmt.Errorf("trace exporter %q requires a file path", cfg.Exporter)
+		}
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open trace file: %w", err)
+		}
+		out, closeOut = f, f.Close
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q (want none, stdout or file)", cfg.Exporter)
+	}
+
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(out))
+	if err != nil {
+		closeOut()
+		return nil, fmt.Errorf("create trace exporter: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if cerr := closeOut(); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+}
+
+// Tracer — трейсер конвейера из глобального провайдера
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Fail отмечает спан ошибкой err; nil ничего не меняет
+func Fail(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans подменяет глобальный провайдер на запоминающий спаны до конца теста
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	return rec
+}
+
+type captureWriter struct {
+	msgs []kafka.Message
+	err  error
+}
+
+func (w *captureWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.msgs = append(w.msgs, msgs...)
+	return w.err
+}
+
+func (w *captureWriter) Close() error { return nil }
+
+func TestInstrumentWriterInjectsPublishSpan(t *testing.T) {
+	rec := recordSpans(t)
+	ctx, root := Tracer().Start(context.Background(), "CreateOrder")
+
+	out := &captureWriter{}
+	orig := kafka.Message{Key: []byte("order-1"), Headers: []kafka.Header{{Key: "retries", Value: []byte("0")}}}
+	require.NoError(t, InstrumentWriter(out, "orders").WriteMessages(ctx, orig))
+	root.End()
+
+	// исходное сообщение не меняется
+	require.Len(t, orig.Headers, 1)
+
+	spans := rec.Ended()
+	require.Len(t, spans, 2)
+	publish := spans[0]
+	require.Equal(t, "publish orders", publish.Name())
+	require.Equal(t, root.SpanContext().SpanID(), publish.Parent().SpanID())
+
+	sc := trace.SpanContextFromContext(Extract(context.Background(), out.msgs[0]))
+	require.Equal(t, publish.SpanContext().TraceID(), sc.TraceID())
+	require.Equal(t, publish.SpanContext().SpanID(), sc.SpanID())
+	require.Equal(t, HeaderCarrier{Headers: &out.msgs[0].Headers}.Get(traceparentHeader),
+		HeaderCarrier{Headers: &out.msgs[0].Headers}.Get(OriginHeader))
+}
+
+func TestStartConsumeLinksRetryToOrigin(t *testing.T) {
+	rec := recordSpans(t)
+	out := &captureWriter{}
+	w := InstrumentWriter(out, "orders")
+
+	require.NoError(t, w.WriteMessages(context.Background(), kafka.Message{Key: []byte("order-1")}))
+	ctx, first := StartConsume(context.Background(), out.msgs[0])
+	require.NoError(t, w.WriteMessages(ctx, out.msgs[0]))
+	first.End()
+	_, second := StartConsume(context.Background(), out.msgs[1])
+	second.End()
+
+	spans := rec.Ended()
+	origin := spans[0]
+	retry := spans[1]
+	require.Equal(t, "publish orders", retry.Name())
+
+	processed := spans[3]
+	require.Equal(t, "orders process", processed.Name())
+	require.Equal(t, retry.SpanContext().SpanID(), processed.Parent().SpanID())
+	require.Len(t, processed.Links(), 1)
+	require.Equal(t, origin.SpanContext().SpanID(), processed.Links()[0].SpanContext.SpanID())
+	require.Equal(t, origin.SpanContext().TraceID(), processed.SpanContext().TraceID())
+}
+
+func TestInstrumentWriterMarksFailedPublish(t *testing.T) {
+	rec := recordSpans(t)
+	err := InstrumentWriter(&captureWriter{err: errors.New("broker down")}, "orders").
+		WriteMessages(context.Background(), kafka.Message{})
+	require.Error(t, err)
+	require.Equal(t, "broker down", rec.Ended()[0].Status().Description)
+}
+
+func TestSetupFileExporter(t *testing.T) {
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(Config{ServiceName: "test", Exporter: ExporterFile, File: path})
+	require.NoError(t, err)
+	_, span := Tracer().Start(context.Background(), "work")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"Name":"work"`)
+	require.Contains(t, string(b), `"Value":"test"`)
+
+	_, err = Setup(Config{Exporter: "jaeger"})
+	require.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package validation
+
+import (
+	"github.com/go-portfolio/order-pipeline/internal/money"
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// SchemaVersion — текущая версия OrderRequest: строки заказа и валюта
+const SchemaVersion = 2
+
+// IsLegacy сообщает, что заказ записан в формате версии 1: один item и price
+func IsLegacy(o *pb.OrderRequest) bool {
+	return o.SchemaVersion < SchemaVersion && len(o.LineItems) == 0
+}
+
+// Normalize возвращает копию заказа в формате версии 2. Заказ версии 1 превращается
+// в одну строку: SKU = item, количество 1, price в целых единицах валюты.
+// Пустые валюты заполняются валютой заказа, а она — defaultCurrency.
+func Normalize(o *pb.OrderRequest, defaultCurrency string) *pb.OrderRequest {
+	n := proto.Clone(o).(*pb.OrderRequest)
+	n.Currency = money.NormalizeCurrency(n.Currency)
+	if n.Currency == "" {
+		n.Currency = money.NormalizeCurrency(defaultCurrency)
+	}
+
+	if IsLegacy(o) && (o.Item != "" || o.Price != 0) {
+		price, err := money.FromMajor(int64(o.Price), n.Currency)
+		if err != nil {
+			// неизвестную валюту отклонит проверка currency
+			price = &pb.Money{Currency: n.Currency, MinorUnits: int64(o.Price)}
+		}
+		n.LineItems = []*pb.LineItem{{Sku: o.Item, Quantity: 1, UnitPrice: price}}
+	}
+	for _, li := range n.LineItems {
+		if li.UnitPrice == nil {
+			continue
+		}
+		li.UnitPrice.Currency = money.NormalizeCurrency(li.UnitPrice.Currency)
+		if li.UnitPrice.Currency == "" {
+			li.UnitPrice.Currency = n.Currency
+		}
+	}
+	n.SchemaVersion = SchemaVersion
+	return n
+}
@@ -0,0 +1,306 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-portfolio/order-pipeline/internal/config"
+	"github.com/go-portfolio/order-pipeline/internal/money"
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Ограничения, которые не вынесены в конфигурацию
+const (
+	maxCustomerIDLen = 64
+	maxMetadata      = 32
+	maxMetadataKey   = 64
+	maxMetadataValue = 512
+)
+
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Rules — ограничения на поля заказа
+type Rules struct {
+	// IDPattern — допустимый формат ID заказа
+	IDPattern string
+	MaxIDLen  int
+	// ItemAllowlist — разрешённые SKU; пустой список разрешает любой
+	ItemAllowlist []string
+	MaxItemLen    int
+	// MinPrice и MaxPrice — допустимый диапазон цены за единицу в минимальных единицах валюты
+	MinPrice int64
+	MaxPrice int64
+	// MaxLineItems и MaxQuantity ограничивают число строк и количество в строке
+	MaxLineItems int
+	MaxQuantity  int32
+	// DefaultCurrency — валюта заказов без currency, в том числе заказов версии 1
+	DefaultCurrency string
+}
+
+// FromConfig берёт правила из секции orders конфигурации сервиса
+func FromConfig(cfg *config.Orders) Rules {
+	return Rules{
+		IDPattern:       cfg.IDPattern,
+		MaxIDLen:        cfg.IDMaxLen,
+		ItemAllowlist:   cfg.ItemAllowlist,
+		MaxItemLen:      cfg.ItemMaxLen,
+		MinPrice:        cfg.PriceMin,
+		MaxPrice:        cfg.PriceMax,
+		MaxLineItems:    cfg.MaxLineItems,
+		MaxQuantity:     cfg.MaxQuantity,
+		DefaultCurrency: cfg.DefaultCurrency,
+	}
+}
+
+// DefaultRules — правила по умолчанию для приёмника и воркера
+func DefaultRules() Rules {
+	return Rules{
+		IDPattern:       `^[A-Za-z0-9][A-Za-z0-9._:-]*$`,
+		MaxIDLen:        64,
+		MaxItemLen:      128,
+		MinPrice:        1,
+		MaxPrice:        1_000_000_000,
+		MaxLineItems:    100,
+		MaxQuantity:     10_000,
+		DefaultCurrency: "USD",
+	}
+}
+
+// fieldRule — одна проверка поля заказа: возвращает описание нарушения или пустую строку
+type fieldRule struct {
+	field string
+	check func(*pb.OrderRequest) string
+}
+
+// lineRule — проверка поля строки заказа; field дополняется индексом строки
+type lineRule struct {
+	field string
+	check func(*pb.OrderRequest, *pb.LineItem) string
+}
+
+// Validator проверяет заказ по списку правил и собирает все нарушения сразу
+type Validator struct {
+	rules           []fieldRule
+	lineRules       []lineRule
+	defaultCurrency string
+}
+
+// New строит валидатор по правилам
+func New(r Rules) (*Validator, error) {
+	idPattern, err := regexp.Compile(r.IDPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id pattern %q: %w", r.IDPattern, err)
+	}
+	if r.MinPrice > r.MaxPrice {
+		return nil, fmt.Errorf("min price %d is greater than max price %d", r.MinPrice, r.MaxPrice)
+	}
+	currency := money.NormalizeCurrency(r.DefaultCurrency)
+	if _, ok := money.Exponent(currency); !ok {
+		return nil, fmt.Errorf("unknown default currency %q", r.DefaultCurrency)
+	}
+	allowed := map[string]bool{}
+	for _, item := range r.ItemAllowlist {
+		allowed[item] = true
+	}
+
+	v := &Validator{defaultCurrency: currency}
+	v.add("id", required(func(o *pb.OrderRequest) string { return o.Id }))
+	v.add("id", maxLen(r.MaxIDLen, func(o *pb.OrderRequest) string { return o.Id }))
+	v.add("id", func(o *pb.OrderRequest) string {
+		if o.Id != "" && !idPattern.MatchString(o.Id) {
+			return "must match " + r.IDPattern
+		}
+		return ""
+	})
+	v.add("currency", func(o *pb.OrderRequest) string {
+		if _, ok := money.Exponent(o.Currency); !ok {
+			return fmt.Sprintf("%q is not a supported ISO 4217 currency", o.Currency)
+		}
+		return ""
+	})
+	v.add("line_items", func(o *pb.OrderRequest) string {
+		switch {
+		case len(o.LineItems) == 0:
+			return "is required"
+		case r.MaxLineItems > 0 && len(o.LineItems) > r.MaxLineItems:
+			return fmt.Sprintf("must have at most %d lines", r.MaxLineItems)
+		}
+		return ""
+	})
+	v.add("customer_id", maxLen(maxCustomerIDLen, func(o *pb.OrderRequest) string { return o.CustomerId }))
+	v.add("shipping_address", func(o *pb.OrderRequest) string {
+		a := o.ShippingAddress
+		switch {
+		case a == nil:
+			return ""
+		case strings.TrimSpace(a.Line1) == "" || strings.TrimSpace(a.City) == "":
+			return "line1 and city are required"
+		case !countryPattern.MatchString(a.Country):
+			return "country must be an ISO 3166-1 alpha-2 code"
+		}
+		return ""
+	})
+	v.add("metadata", func(o *pb.OrderRequest) string {
+		if len(o.Metadata) > maxMetadata {
+			return fmt.Sprintf("must have at most %d entries", maxMetadata)
+		}
+		for k, val := range o.Metadata {
+			if k == "" || len(k) > maxMetadataKey || len(val) > maxMetadataValue {
+				return fmt.Sprintf("keys must be 1..%d bytes and values at most %d bytes", maxMetadataKey, maxMetadataValue)
+			}
+		}
+		return ""
+	})
+
+	v.addLine("sku", func(_ *pb.OrderRequest, li *pb.LineItem) string {
+		if strings.TrimSpace(li.Sku) == "" {
+			return "is required"
+		}
+		return ""
+	})
+	v.addLine("sku", func(_ *pb.OrderRequest, li *pb.LineItem) string {
+		if r.MaxItemLen > 0 && utf8.RuneCountInString(li.Sku) > r.MaxItemLen {
+			return fmt.Sprintf("must be at most %d characters", r.MaxItemLen)
+		}
+		return ""
+	})
+	if len(allowed) > 0 {
+		v.addLine("sku", func(_ *pb.OrderRequest, li *pb.LineItem) string {
+			if !allowed[li.Sku] {
+				return fmt.Sprintf("%q is not an allowed item", li.Sku)
+			}
+			return ""
+		})
+	}
+	v.addLine("quantity", func(_ *pb.OrderRequest, li *pb.LineItem) string {
+		if li.Quantity < 1 || (r.MaxQuantity > 0 && li.Quantity > r.MaxQuantity) {
+			return fmt.Sprintf("must be between 1 and %d", r.MaxQuantity)
+		}
+		return ""
+	})
+	v.addLine("unit_price", func(o *pb.OrderRequest, li *pb.LineItem) string {
+		switch p := li.UnitPrice; {
+		case p == nil:
+			return "is required"
+		case p.Currency != o.Currency:
+			return fmt.Sprintf("currency %s differs from order currency %s", p.Currency, o.Currency)
+		case p.MinorUnits < r.MinPrice || p.MinorUnits > r.MaxPrice:
+			return fmt.Sprintf("must be between %d and %d minor units", r.MinPrice, r.MaxPrice)
+		}
+		return ""
+	})
+	return v, nil
+}
+
+// MustDefault — валидатор с правилами по умолчанию
+func MustDefault() *Validator {
+	v, err := New(DefaultRules())
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (v *Validator) add(field string, check func(*pb.OrderRequest) string) {
+	v.rules = append(v.rules, fieldRule{field: field, check: check})
+}
+
+func (v *Validator) addLine(field string, check func(*pb.OrderRequest, *pb.LineItem) string) {
+	v.lineRules = append(v.lineRules, lineRule{field: field, check: check})
+}
+
+// Normalize приводит заказ к версии 2, см. Normalize
+func (v *Validator) Normalize(o *pb.OrderRequest) *pb.OrderRequest {
+	return Normalize(o, v.defaultCurrency)
+}
+
+// Validate проверяет заказ любой версии и возвращает *Error со всеми нарушениями или nil.
+// Нарушения заказа версии 1 называют его собственные поля item и price.
+func (v *Validator) Validate(o *pb.OrderRequest) error {
+	n := v.Normalize(o)
+	legacy := IsLegacy(o)
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	reported := map[string]bool{}
+	report := func(field, msg string) {
+		if legacy {
+			field = legacyField(field)
+		}
+		// на поле достаточно первого нарушения: пустой ID не нужно ещё и сверять с шаблоном
+		if msg == "" || reported[field] {
+			return
+		}
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: field, Description: msg})
+		reported[field] = true
+	}
+
+	for _, r := range v.rules {
+		report(r.field, r.check(n))
+	}
+	for i, li := range n.LineItems {
+		for _, r := range v.lineRules {
+			report(fmt.Sprintf("line_items[%d].%s", i, r.field), r.check(n, li))
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return &Error{Violations: violations}
+}
+
+// legacyField переводит путь поля версии 2 в поле заказа версии 1
+func legacyField(field string) string {
+	switch field {
+	case "line_items", "line_items[0].sku":
+		return "item"
+	case "line_items[0].unit_price":
+		return "price"
+	}
+	return field
+}
+
+// Error — заказ не прошёл проверку. Как gRPC-ошибка это InvalidArgument с errdetails.BadRequest.
+type Error struct {
+	Violations []*errdetails.BadRequest_FieldViolation
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, fv := range e.Violations {
+		parts[i] = fv.Field + ": " + fv.Description
+	}
+	return "invalid order: " + strings.Join(parts, "; ")
+}
+
+// GRPCStatus позволяет вернуть ошибку из обработчика gRPC как есть
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(codes.InvalidArgument, e.Error())
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: e.Violations}); err == nil {
+		return detailed
+	}
+	return st
+}
+
+func required(get func(*pb.OrderRequest) string) func(*pb.OrderRequest) string {
+	return func(o *pb.OrderRequest) string {
+		if strings.TrimSpace(get(o)) == "" {
+			return "is required"
+		}
+		return ""
+	}
+}
+
+func maxLen(n int, get func(*pb.OrderRequest) string) func(*pb.OrderRequest) string {
+	return func(o *pb.OrderRequest) string {
+		if n > 0 && utf8.RuneCountInString(get(o)) > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+		return ""
+	}
+}
@@ -0,0 +1,96 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	pb "github.com/go-portfolio/order-pipeline/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateCollectsFieldViolations(t *testing.T) {
+	rules := DefaultRules()
+	rules.ItemAllowlist = []string{"book", "pen"}
+	v, err := New(rules)
+	require.NoError(t, err)
+
+	require.NoError(t, v.Validate(&pb.OrderRequest{Id: "order-1", Item: "book", Price: 42}))
+
+	err = v.Validate(&pb.OrderRequest{Id: "", Item: "lamp", Price: -5})
+	var verr *Error
+	require.True(t, errors.As(err, &verr))
+	fields := map[string]string{}
+	for _, fv := range verr.Violations {
+		fields[fv.Field] = fv.Description
+	}
+	require.Equal(t, map[string]string{
+		"id":    "is required",
+		"item":  `"lamp" is not an allowed item`,
+		"price": "must be between 1 and 1000000000 minor units",
+	}, fields)
+
+	err = v.Validate(&pb.OrderRequest{Id: "bad id!", Item: "pen", Price: 1})
+	require.ErrorContains(t, err, "id: must match")
+}
+
+func TestErrorCarriesBadRequestDetails(t *testing.T) {
+	err := MustDefault().Validate(&pb.OrderRequest{Id: "o-1", Item: "book", Price: 0})
+
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	br, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	require.Equal(t, "price", br.FieldViolations[0].Field)
+}
+
+func TestNewRejectsBadRules(t *testing.T) {
+	_, err := New(Rules{IDPattern: "("})
+	require.Error(t, err)
+	_, err = New(Rules{IDPattern: ".*", MinPrice: 10, MaxPrice: 1})
+	require.Error(t, err)
+}
+
+func TestValidateLineItems(t *testing.T) {
+	v := MustDefault()
+	order := &pb.OrderRequest{
+		Id:            "o-2",
+		SchemaVersion: SchemaVersion,
+		Currency:      "eur",
+		LineItems: []*pb.LineItem{
+			{Sku: "book", Quantity: 2, UnitPrice: &pb.Money{MinorUnits: 1250}},
+			{Sku: "pen", Quantity: 0, UnitPrice: &pb.Money{Currency: "USD", MinorUnits: 99}},
+		},
+		ShippingAddress: &pb.Address{Line1: "Main st 1", City: "Berlin", Country: "Germany"},
+	}
+
+	err := v.Validate(order)
+	var verr *Error
+	require.True(t, errors.As(err, &verr))
+	fields := map[string]string{}
+	for _, fv := range verr.Violations {
+		fields[fv.Field] = fv.Description
+	}
+	require.Equal(t, map[string]string{
+		"line_items[1].quantity":   "must be between 1 and 10000",
+		"line_items[1].unit_price": "currency USD differs from order currency EUR",
+		"shipping_address":         "country must be an ISO 3166-1 alpha-2 code",
+	}, fields)
+}
+
+func TestNormalizeLegacyOrder(t *testing.T) {
+	legacy := &pb.OrderRequest{Id: "o-3", Item: "book", Price: 42}
+	n := Normalize(legacy, "usd")
+
+	require.Equal(t, int32(SchemaVersion), n.SchemaVersion)
+	require.Equal(t, "USD", n.Currency)
+	require.Len(t, n.LineItems, 1)
+	require.Equal(t, "book", n.LineItems[0].Sku)
+	require.Equal(t, int32(1), n.LineItems[0].Quantity)
+	require.Equal(t, int64(4200), n.LineItems[0].UnitPrice.MinorUnits)
+	// исходный заказ не меняется
+	require.Empty(t, legacy.LineItems)
+}
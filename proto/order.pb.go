@@ -21,13 +21,141 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Итог приёма одного заказа из пакета
+type BatchItemStatus int32
+
+const (
+	BatchItemStatus_BATCH_ITEM_STATUS_UNSPECIFIED BatchItemStatus = 0
+	BatchItemStatus_BATCH_ITEM_STATUS_ACCEPTED    BatchItemStatus = 1
+	// заказ с тем же ID и содержимым уже принят раньше
+	BatchItemStatus_BATCH_ITEM_STATUS_DUPLICATE BatchItemStatus = 2
+	BatchItemStatus_BATCH_ITEM_STATUS_REJECTED  BatchItemStatus = 3
+)
+
+// Enum value maps for BatchItemStatus.
+var (
+	BatchItemStatus_name = map[int32]string{
+		0: "BATCH_ITEM_STATUS_UNSPECIFIED",
+		1: "BATCH_ITEM_STATUS_ACCEPTED",
+		2: "BATCH_ITEM_STATUS_DUPLICATE",
+		3: "BATCH_ITEM_STATUS_REJECTED",
+	}
+	BatchItemStatus_value = map[string]int32{
+		"BATCH_ITEM_STATUS_UNSPECIFIED": 0,
+		"BATCH_ITEM_STATUS_ACCEPTED":    1,
+		"BATCH_ITEM_STATUS_DUPLICATE":   2,
+		"BATCH_ITEM_STATUS_REJECTED":    3,
+	}
+)
+
+func (x BatchItemStatus) Enum() *BatchItemStatus {
+	p := new(BatchItemStatus)
+	*p = x
+	return p
+}
+
+func (x BatchItemStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (BatchItemStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_order_proto_enumTypes[0].Descriptor()
+}
+
+func (BatchItemStatus) Type() protoreflect.EnumType {
+	return &file_proto_order_proto_enumTypes[0]
+}
+
+func (x BatchItemStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use BatchItemStatus.Descriptor instead.
+func (BatchItemStatus) EnumDescriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{0}
+}
+
+// Состояние заказа в конвейере обработки
+type OrderState int32
+
+const (
+	OrderState_ORDER_STATE_UNSPECIFIED   OrderState = 0
+	OrderState_ORDER_STATE_ACCEPTED      OrderState = 1
+	OrderState_ORDER_STATE_PROCESSING    OrderState = 2
+	OrderState_ORDER_STATE_RETRYING      OrderState = 3
+	OrderState_ORDER_STATE_DONE          OrderState = 4
+	OrderState_ORDER_STATE_FAILED        OrderState = 5
+	OrderState_ORDER_STATE_DEAD_LETTERED OrderState = 6
+)
+
+// Enum value maps for OrderState.
+var (
+	OrderState_name = map[int32]string{
+		0: "ORDER_STATE_UNSPECIFIED",
+		1: "ORDER_STATE_ACCEPTED",
+		2: "ORDER_STATE_PROCESSING",
+		3: "ORDER_STATE_RETRYING",
+		4: "ORDER_STATE_DONE",
+		5: "ORDER_STATE_FAILED",
+		6: "ORDER_STATE_DEAD_LETTERED",
+	}
+	OrderState_value = map[string]int32{
+		"ORDER_STATE_UNSPECIFIED":   0,
+		"ORDER_STATE_ACCEPTED":      1,
+		"ORDER_STATE_PROCESSING":    2,
+		"ORDER_STATE_RETRYING":      3,
+		"ORDER_STATE_DONE":          4,
+		"ORDER_STATE_FAILED":        5,
+		"ORDER_STATE_DEAD_LETTERED": 6,
+	}
+)
+
+func (x OrderState) Enum() *OrderState {
+	p := new(OrderState)
+	*p = x
+	return p
+}
+
+func (x OrderState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OrderState) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_order_proto_enumTypes[1].Descriptor()
+}
+
+func (OrderState) Type() protoreflect.EnumType {
+	return &file_proto_order_proto_enumTypes[1]
+}
+
+func (x OrderState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OrderState.Descriptor instead.
+func (OrderState) EnumDescriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{1}
+}
+
+// OrderRequest версии 2 описывает заказ строками line_items в валюте currency.
+// Версия 1 (schema_version = 0) содержала один товар item с ценой price в целых
+// единицах валюты; такие сообщения по-прежнему принимаются и приводятся к версии 2.
 type OrderRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Item          string                 `protobuf:"bytes,2,opt,name=item,proto3" json:"item,omitempty"`
-	Price         int32                  `protobuf:"varint,3,opt,name=price,proto3" json:"price,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// устарело: используйте line_items
+	Item string `protobuf:"bytes,2,opt,name=item,proto3" json:"item,omitempty"`
+	// устарело: используйте line_items
+	Price         int32       `protobuf:"varint,3,opt,name=price,proto3" json:"price,omitempty"`
+	SchemaVersion int32       `protobuf:"varint,4,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	LineItems     []*LineItem `protobuf:"bytes,5,rep,name=line_items,json=lineItems,proto3" json:"line_items,omitempty"`
+	// код валюты ISO 4217, например USD
+	Currency        string            `protobuf:"bytes,6,opt,name=currency,proto3" json:"currency,omitempty"`
+	CustomerId      string            `protobuf:"bytes,7,opt,name=customer_id,json=customerId,proto3" json:"customer_id,omitempty"`
+	ShippingAddress *Address          `protobuf:"bytes,8,opt,name=shipping_address,json=shippingAddress,proto3" json:"shipping_address,omitempty"`
+	Metadata        map[string]string `protobuf:"bytes,9,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *OrderRequest) Reset() {
@@ -81,6 +209,385 @@ func (x *OrderRequest) GetPrice() int32 {
 	return 0
 }
 
+func (x *OrderRequest) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *OrderRequest) GetLineItems() []*LineItem {
+	if x != nil {
+		return x.LineItems
+	}
+	return nil
+}
+
+func (x *OrderRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *OrderRequest) GetCustomerId() string {
+	if x != nil {
+		return x.CustomerId
+	}
+	return ""
+}
+
+func (x *OrderRequest) GetShippingAddress() *Address {
+	if x != nil {
+		return x.ShippingAddress
+	}
+	return nil
+}
+
+func (x *OrderRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// Money — сумма в минимальных единицах валюты (центах, копейках), без округлений
+type Money struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Currency      string                 `protobuf:"bytes,1,opt,name=currency,proto3" json:"currency,omitempty"`
+	MinorUnits    int64                  `protobuf:"varint,2,opt,name=minor_units,json=minorUnits,proto3" json:"minor_units,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Money) Reset() {
+	*x = Money{}
+	mi := &file_proto_order_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Money) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Money) ProtoMessage() {}
+
+func (x *Money) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Money.ProtoReflect.Descriptor instead.
+func (*Money) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Money) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Money) GetMinorUnits() int64 {
+	if x != nil {
+		return x.MinorUnits
+	}
+	return 0
+}
+
+type LineItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sku           string                 `protobuf:"bytes,1,opt,name=sku,proto3" json:"sku,omitempty"`
+	Quantity      int32                  `protobuf:"varint,2,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	UnitPrice     *Money                 `protobuf:"bytes,3,opt,name=unit_price,json=unitPrice,proto3" json:"unit_price,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LineItem) Reset() {
+	*x = LineItem{}
+	mi := &file_proto_order_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LineItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LineItem) ProtoMessage() {}
+
+func (x *LineItem) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LineItem.ProtoReflect.Descriptor instead.
+func (*LineItem) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *LineItem) GetSku() string {
+	if x != nil {
+		return x.Sku
+	}
+	return ""
+}
+
+func (x *LineItem) GetQuantity() int32 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *LineItem) GetUnitPrice() *Money {
+	if x != nil {
+		return x.UnitPrice
+	}
+	return nil
+}
+
+type Address struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Line1      string                 `protobuf:"bytes,1,opt,name=line1,proto3" json:"line1,omitempty"`
+	Line2      string                 `protobuf:"bytes,2,opt,name=line2,proto3" json:"line2,omitempty"`
+	City       string                 `protobuf:"bytes,3,opt,name=city,proto3" json:"city,omitempty"`
+	Region     string                 `protobuf:"bytes,4,opt,name=region,proto3" json:"region,omitempty"`
+	PostalCode string                 `protobuf:"bytes,5,opt,name=postal_code,json=postalCode,proto3" json:"postal_code,omitempty"`
+	// код страны ISO 3166-1 alpha-2
+	Country       string `protobuf:"bytes,6,opt,name=country,proto3" json:"country,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Address) Reset() {
+	*x = Address{}
+	mi := &file_proto_order_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Address) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Address) ProtoMessage() {}
+
+func (x *Address) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Address.ProtoReflect.Descriptor instead.
+func (*Address) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Address) GetLine1() string {
+	if x != nil {
+		return x.Line1
+	}
+	return ""
+}
+
+func (x *Address) GetLine2() string {
+	if x != nil {
+		return x.Line2
+	}
+	return ""
+}
+
+func (x *Address) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *Address) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *Address) GetPostalCode() string {
+	if x != nil {
+		return x.PostalCode
+	}
+	return ""
+}
+
+func (x *Address) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+// Расчёт заказа, выполненный воркером
+type OrderTotals struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Lines []*LineTotal           `protobuf:"bytes,1,rep,name=lines,proto3" json:"lines,omitempty"`
+	// total — сумма строк без налога
+	Total *Money `protobuf:"bytes,2,opt,name=total,proto3" json:"total,omitempty"`
+	Tax   *Money `protobuf:"bytes,3,opt,name=tax,proto3" json:"tax,omitempty"`
+	// grand_total = total + tax
+	GrandTotal    *Money `protobuf:"bytes,4,opt,name=grand_total,json=grandTotal,proto3" json:"grand_total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderTotals) Reset() {
+	*x = OrderTotals{}
+	mi := &file_proto_order_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderTotals) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderTotals) ProtoMessage() {}
+
+func (x *OrderTotals) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderTotals.ProtoReflect.Descriptor instead.
+func (*OrderTotals) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *OrderTotals) GetLines() []*LineTotal {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+func (x *OrderTotals) GetTotal() *Money {
+	if x != nil {
+		return x.Total
+	}
+	return nil
+}
+
+func (x *OrderTotals) GetTax() *Money {
+	if x != nil {
+		return x.Tax
+	}
+	return nil
+}
+
+func (x *OrderTotals) GetGrandTotal() *Money {
+	if x != nil {
+		return x.GrandTotal
+	}
+	return nil
+}
+
+type LineTotal struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sku           string                 `protobuf:"bytes,1,opt,name=sku,proto3" json:"sku,omitempty"`
+	Quantity      int32                  `protobuf:"varint,2,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	UnitPrice     *Money                 `protobuf:"bytes,3,opt,name=unit_price,json=unitPrice,proto3" json:"unit_price,omitempty"`
+	Subtotal      *Money                 `protobuf:"bytes,4,opt,name=subtotal,proto3" json:"subtotal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LineTotal) Reset() {
+	*x = LineTotal{}
+	mi := &file_proto_order_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LineTotal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LineTotal) ProtoMessage() {}
+
+func (x *LineTotal) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LineTotal.ProtoReflect.Descriptor instead.
+func (*LineTotal) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *LineTotal) GetSku() string {
+	if x != nil {
+		return x.Sku
+	}
+	return ""
+}
+
+func (x *LineTotal) GetQuantity() int32 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *LineTotal) GetUnitPrice() *Money {
+	if x != nil {
+		return x.UnitPrice
+	}
+	return nil
+}
+
+func (x *LineTotal) GetSubtotal() *Money {
+	if x != nil {
+		return x.Subtotal
+	}
+	return nil
+}
+
 type OrderResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
@@ -90,7 +597,7 @@ type OrderResponse struct {
 
 func (x *OrderResponse) Reset() {
 	*x = OrderResponse{}
-	mi := &file_proto_order_proto_msgTypes[1]
+	mi := &file_proto_order_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -102,7 +609,7 @@ func (x *OrderResponse) String() string {
 func (*OrderResponse) ProtoMessage() {}
 
 func (x *OrderResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_order_proto_msgTypes[1]
+	mi := &file_proto_order_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -115,7 +622,7 @@ func (x *OrderResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use OrderResponse.ProtoReflect.Descriptor instead.
 func (*OrderResponse) Descriptor() ([]byte, []int) {
-	return file_proto_order_proto_rawDescGZIP(), []int{1}
+	return file_proto_order_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *OrderResponse) GetStatus() string {
@@ -125,6 +632,164 @@ func (x *OrderResponse) GetStatus() string {
 	return ""
 }
 
+type BatchOrderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Orders        []*OrderRequest        `protobuf:"bytes,1,rep,name=orders,proto3" json:"orders,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchOrderRequest) Reset() {
+	*x = BatchOrderRequest{}
+	mi := &file_proto_order_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchOrderRequest) ProtoMessage() {}
+
+func (x *BatchOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchOrderRequest.ProtoReflect.Descriptor instead.
+func (*BatchOrderRequest) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *BatchOrderRequest) GetOrders() []*OrderRequest {
+	if x != nil {
+		return x.Orders
+	}
+	return nil
+}
+
+type BatchItemResult struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Id     string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status BatchItemStatus        `protobuf:"varint,2,opt,name=status,proto3,enum=order.BatchItemStatus" json:"status,omitempty"`
+	// gRPC-код и причина отказа, только для REJECTED
+	Code          int32  `protobuf:"varint,3,opt,name=code,proto3" json:"code,omitempty"`
+	Reason        string `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchItemResult) Reset() {
+	*x = BatchItemResult{}
+	mi := &file_proto_order_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchItemResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchItemResult) ProtoMessage() {}
+
+func (x *BatchItemResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchItemResult.ProtoReflect.Descriptor instead.
+func (*BatchItemResult) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *BatchItemResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *BatchItemResult) GetStatus() BatchItemStatus {
+	if x != nil {
+		return x.Status
+	}
+	return BatchItemStatus_BATCH_ITEM_STATUS_UNSPECIFIED
+}
+
+func (x *BatchItemResult) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *BatchItemResult) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// results идут в том же порядке, что и orders в запросе
+type BatchOrderResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*BatchItemResult     `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchOrderResponse) Reset() {
+	*x = BatchOrderResponse{}
+	mi := &file_proto_order_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchOrderResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchOrderResponse) ProtoMessage() {}
+
+func (x *BatchOrderResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchOrderResponse.ProtoReflect.Descriptor instead.
+func (*BatchOrderResponse) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *BatchOrderResponse) GetResults() []*BatchItemResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
 type ResultRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -134,7 +799,7 @@ type ResultRequest struct {
 
 func (x *ResultRequest) Reset() {
 	*x = ResultRequest{}
-	mi := &file_proto_order_proto_msgTypes[2]
+	mi := &file_proto_order_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -146,7 +811,7 @@ func (x *ResultRequest) String() string {
 func (*ResultRequest) ProtoMessage() {}
 
 func (x *ResultRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_order_proto_msgTypes[2]
+	mi := &file_proto_order_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -159,7 +824,7 @@ func (x *ResultRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ResultRequest.ProtoReflect.Descriptor instead.
 func (*ResultRequest) Descriptor() ([]byte, []int) {
-	return file_proto_order_proto_rawDescGZIP(), []int{2}
+	return file_proto_order_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *ResultRequest) GetId() string {
@@ -170,17 +835,23 @@ func (x *ResultRequest) GetId() string {
 }
 
 type ResultResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Item          string                 `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
-	Price         int32                  `protobuf:"varint,2,opt,name=price,proto3" json:"price,omitempty"`
-	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Item   string                 `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	Price  int32                  `protobuf:"varint,2,opt,name=price,proto3" json:"price,omitempty"`
+	Status string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	State  OrderState             `protobuf:"varint,4,opt,name=state,proto3,enum=order.OrderState" json:"state,omitempty"`
+	// номер текущей (или последней) попытки обработки, начиная с 1
+	Attempts      int32        `protobuf:"varint,5,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	LastError     string       `protobuf:"bytes,6,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	CustomerId    string       `protobuf:"bytes,7,opt,name=customer_id,json=customerId,proto3" json:"customer_id,omitempty"`
+	Totals        *OrderTotals `protobuf:"bytes,8,opt,name=totals,proto3" json:"totals,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ResultResponse) Reset() {
 	*x = ResultResponse{}
-	mi := &file_proto_order_proto_msgTypes[3]
+	mi := &file_proto_order_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -192,7 +863,7 @@ func (x *ResultResponse) String() string {
 func (*ResultResponse) ProtoMessage() {}
 
 func (x *ResultResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_order_proto_msgTypes[3]
+	mi := &file_proto_order_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -205,7 +876,7 @@ func (x *ResultResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ResultResponse.ProtoReflect.Descriptor instead.
 func (*ResultResponse) Descriptor() ([]byte, []int) {
-	return file_proto_order_proto_rawDescGZIP(), []int{3}
+	return file_proto_order_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *ResultResponse) GetItem() string {
@@ -229,27 +900,191 @@ func (x *ResultResponse) GetStatus() string {
 	return ""
 }
 
+func (x *ResultResponse) GetState() OrderState {
+	if x != nil {
+		return x.State
+	}
+	return OrderState_ORDER_STATE_UNSPECIFIED
+}
+
+func (x *ResultResponse) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *ResultResponse) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *ResultResponse) GetCustomerId() string {
+	if x != nil {
+		return x.CustomerId
+	}
+	return ""
+}
+
+func (x *ResultResponse) GetTotals() *OrderTotals {
+	if x != nil {
+		return x.Totals
+	}
+	return nil
+}
+
+type OrderEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Result        *ResultResponse        `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderEvent) Reset() {
+	*x = OrderEvent{}
+	mi := &file_proto_order_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderEvent) ProtoMessage() {}
+
+func (x *OrderEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderEvent.ProtoReflect.Descriptor instead.
+func (*OrderEvent) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *OrderEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *OrderEvent) GetResult() *ResultResponse {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
 var File_proto_order_proto protoreflect.FileDescriptor
 
 const file_proto_order_proto_rawDesc = "" +
 	"\n" +
-	"\x11proto/order.proto\x12\x05order\"H\n" +
+	"\x11proto/order.proto\x12\x05order\"\x93\x03\n" +
 	"\fOrderRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04item\x18\x02 \x01(\tR\x04item\x12\x14\n" +
-	"\x05price\x18\x03 \x01(\x05R\x05price\"'\n" +
+	"\x05price\x18\x03 \x01(\x05R\x05price\x12%\n" +
+	"\x0eschema_version\x18\x04 \x01(\x05R\rschemaVersion\x12.\n" +
+	"\n" +
+	"line_items\x18\x05 \x03(\v2\x0f.order.LineItemR\tlineItems\x12\x1a\n" +
+	"\bcurrency\x18\x06 \x01(\tR\bcurrency\x12\x1f\n" +
+	"\vcustomer_id\x18\a \x01(\tR\n" +
+	"customerId\x129\n" +
+	"\x10shipping_address\x18\b \x01(\v2\x0e.order.AddressR\x0fshippingAddress\x12=\n" +
+	"\bmetadata\x18\t \x03(\v2!.order.OrderRequest.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"D\n" +
+	"\x05Money\x12\x1a\n" +
+	"\bcurrency\x18\x01 \x01(\tR\bcurrency\x12\x1f\n" +
+	"\vminor_units\x18\x02 \x01(\x03R\n" +
+	"minorUnits\"e\n" +
+	"\bLineItem\x12\x10\n" +
+	"\x03sku\x18\x01 \x01(\tR\x03sku\x12\x1a\n" +
+	"\bquantity\x18\x02 \x01(\x05R\bquantity\x12+\n" +
+	"\n" +
+	"unit_price\x18\x03 \x01(\v2\f.order.MoneyR\tunitPrice\"\x9c\x01\n" +
+	"\aAddress\x12\x14\n" +
+	"\x05line1\x18\x01 \x01(\tR\x05line1\x12\x14\n" +
+	"\x05line2\x18\x02 \x01(\tR\x05line2\x12\x12\n" +
+	"\x04city\x18\x03 \x01(\tR\x04city\x12\x16\n" +
+	"\x06region\x18\x04 \x01(\tR\x06region\x12\x1f\n" +
+	"\vpostal_code\x18\x05 \x01(\tR\n" +
+	"postalCode\x12\x18\n" +
+	"\acountry\x18\x06 \x01(\tR\acountry\"\xa8\x01\n" +
+	"\vOrderTotals\x12&\n" +
+	"\x05lines\x18\x01 \x03(\v2\x10.order.LineTotalR\x05lines\x12\"\n" +
+	"\x05total\x18\x02 \x01(\v2\f.order.MoneyR\x05total\x12\x1e\n" +
+	"\x03tax\x18\x03 \x01(\v2\f.order.MoneyR\x03tax\x12-\n" +
+	"\vgrand_total\x18\x04 \x01(\v2\f.order.MoneyR\n" +
+	"grandTotal\"\x90\x01\n" +
+	"\tLineTotal\x12\x10\n" +
+	"\x03sku\x18\x01 \x01(\tR\x03sku\x12\x1a\n" +
+	"\bquantity\x18\x02 \x01(\x05R\bquantity\x12+\n" +
+	"\n" +
+	"unit_price\x18\x03 \x01(\v2\f.order.MoneyR\tunitPrice\x12(\n" +
+	"\bsubtotal\x18\x04 \x01(\v2\f.order.MoneyR\bsubtotal\"'\n" +
 	"\rOrderResponse\x12\x16\n" +
-	"\x06status\x18\x01 \x01(\tR\x06status\"\x1f\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\"@\n" +
+	"\x11BatchOrderRequest\x12+\n" +
+	"\x06orders\x18\x01 \x03(\v2\x13.order.OrderRequestR\x06orders\"}\n" +
+	"\x0fBatchItemResult\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12.\n" +
+	"\x06status\x18\x02 \x01(\x0e2\x16.order.BatchItemStatusR\x06status\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\x05R\x04code\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\"F\n" +
+	"\x12BatchOrderResponse\x120\n" +
+	"\aresults\x18\x01 \x03(\v2\x16.order.BatchItemResultR\aresults\"\x1f\n" +
 	"\rResultRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\"R\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x83\x02\n" +
 	"\x0eResultResponse\x12\x12\n" +
 	"\x04item\x18\x01 \x01(\tR\x04item\x12\x14\n" +
 	"\x05price\x18\x02 \x01(\x05R\x05price\x12\x16\n" +
-	"\x06status\x18\x03 \x01(\tR\x06status2H\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12'\n" +
+	"\x05state\x18\x04 \x01(\x0e2\x11.order.OrderStateR\x05state\x12\x1a\n" +
+	"\battempts\x18\x05 \x01(\x05R\battempts\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x06 \x01(\tR\tlastError\x12\x1f\n" +
+	"\vcustomer_id\x18\a \x01(\tR\n" +
+	"customerId\x12*\n" +
+	"\x06totals\x18\b \x01(\v2\x12.order.OrderTotalsR\x06totals\"K\n" +
+	"\n" +
+	"OrderEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12-\n" +
+	"\x06result\x18\x02 \x01(\v2\x15.order.ResultResponseR\x06result*\x95\x01\n" +
+	"\x0fBatchItemStatus\x12!\n" +
+	"\x1dBATCH_ITEM_STATUS_UNSPECIFIED\x10\x00\x12\x1e\n" +
+	"\x1aBATCH_ITEM_STATUS_ACCEPTED\x10\x01\x12\x1f\n" +
+	"\x1bBATCH_ITEM_STATUS_DUPLICATE\x10\x02\x12\x1e\n" +
+	"\x1aBATCH_ITEM_STATUS_REJECTED\x10\x03*\xc6\x01\n" +
+	"\n" +
+	"OrderState\x12\x1b\n" +
+	"\x17ORDER_STATE_UNSPECIFIED\x10\x00\x12\x18\n" +
+	"\x14ORDER_STATE_ACCEPTED\x10\x01\x12\x1a\n" +
+	"\x16ORDER_STATE_PROCESSING\x10\x02\x12\x18\n" +
+	"\x14ORDER_STATE_RETRYING\x10\x03\x12\x14\n" +
+	"\x10ORDER_STATE_DONE\x10\x04\x12\x16\n" +
+	"\x12ORDER_STATE_FAILED\x10\x05\x12\x1d\n" +
+	"\x19ORDER_STATE_DEAD_LETTERED\x10\x062\x92\x01\n" +
 	"\fOrderService\x128\n" +
-	"\vCreateOrder\x12\x13.order.OrderRequest\x1a\x14.order.OrderResponse2M\n" +
+	"\vCreateOrder\x12\x13.order.OrderRequest\x1a\x14.order.OrderResponse\x12H\n" +
+	"\x11CreateOrdersBatch\x12\x18.order.BatchOrderRequest\x1a\x19.order.BatchOrderResponse2\x86\x01\n" +
 	"\fCacheService\x12=\n" +
-	"\x0eGetOrderResult\x12\x14.order.ResultRequest\x1a\x15.order.ResultResponseB7Z5github.com/go-portfolio/order-pipeline/internal/pb;pbb\x06proto3"
+	"\x0eGetOrderResult\x12\x14.order.ResultRequest\x1a\x15.order.ResultResponse\x127\n" +
+	"\n" +
+	"WatchOrder\x12\x14.order.ResultRequest\x1a\x11.order.OrderEvent0\x01B7Z5github.com/go-portfolio/order-pipeline/internal/pb;pbb\x06proto3"
 
 var (
 	file_proto_order_proto_rawDescOnce sync.Once
@@ -263,23 +1098,56 @@ func file_proto_order_proto_rawDescGZIP() []byte {
 	return file_proto_order_proto_rawDescData
 }
 
-var file_proto_order_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_proto_order_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_proto_order_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_proto_order_proto_goTypes = []any{
-	(*OrderRequest)(nil),   // 0: order.OrderRequest
-	(*OrderResponse)(nil),  // 1: order.OrderResponse
-	(*ResultRequest)(nil),  // 2: order.ResultRequest
-	(*ResultResponse)(nil), // 3: order.ResultResponse
+	(BatchItemStatus)(0),       // 0: order.BatchItemStatus
+	(OrderState)(0),            // 1: order.OrderState
+	(*OrderRequest)(nil),       // 2: order.OrderRequest
+	(*Money)(nil),              // 3: order.Money
+	(*LineItem)(nil),           // 4: order.LineItem
+	(*Address)(nil),            // 5: order.Address
+	(*OrderTotals)(nil),        // 6: order.OrderTotals
+	(*LineTotal)(nil),          // 7: order.LineTotal
+	(*OrderResponse)(nil),      // 8: order.OrderResponse
+	(*BatchOrderRequest)(nil),  // 9: order.BatchOrderRequest
+	(*BatchItemResult)(nil),    // 10: order.BatchItemResult
+	(*BatchOrderResponse)(nil), // 11: order.BatchOrderResponse
+	(*ResultRequest)(nil),      // 12: order.ResultRequest
+	(*ResultResponse)(nil),     // 13: order.ResultResponse
+	(*OrderEvent)(nil),         // 14: order.OrderEvent
+	nil,                        // 15: order.OrderRequest.MetadataEntry
 }
 var file_proto_order_proto_depIdxs = []int32{
-	0, // 0: order.OrderService.CreateOrder:input_type -> order.OrderRequest
-	2, // 1: order.CacheService.GetOrderResult:input_type -> order.ResultRequest
-	1, // 2: order.OrderService.CreateOrder:output_type -> order.OrderResponse
-	3, // 3: order.CacheService.GetOrderResult:output_type -> order.ResultResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	4,  // 0: order.OrderRequest.line_items:type_name -> order.LineItem
+	5,  // 1: order.OrderRequest.shipping_address:type_name -> order.Address
+	15, // 2: order.OrderRequest.metadata:type_name -> order.OrderRequest.MetadataEntry
+	3,  // 3: order.LineItem.unit_price:type_name -> order.Money
+	7,  // 4: order.OrderTotals.lines:type_name -> order.LineTotal
+	3,  // 5: order.OrderTotals.total:type_name -> order.Money
+	3,  // 6: order.OrderTotals.tax:type_name -> order.Money
+	3,  // 7: order.OrderTotals.grand_total:type_name -> order.Money
+	3,  // 8: order.LineTotal.unit_price:type_name -> order.Money
+	3,  // 9: order.LineTotal.subtotal:type_name -> order.Money
+	2,  // 10: order.BatchOrderRequest.orders:type_name -> order.OrderRequest
+	0,  // 11: order.BatchItemResult.status:type_name -> order.BatchItemStatus
+	10, // 12: order.BatchOrderResponse.results:type_name -> order.BatchItemResult
+	1,  // 13: order.ResultResponse.state:type_name -> order.OrderState
+	6,  // 14: order.ResultResponse.totals:type_name -> order.OrderTotals
+	13, // 15: order.OrderEvent.result:type_name -> order.ResultResponse
+	2,  // 16: order.OrderService.CreateOrder:input_type -> order.OrderRequest
+	9,  // 17: order.OrderService.CreateOrdersBatch:input_type -> order.BatchOrderRequest
+	12, // 18: order.CacheService.GetOrderResult:input_type -> order.ResultRequest
+	12, // 19: order.CacheService.WatchOrder:input_type -> order.ResultRequest
+	8,  // 20: order.OrderService.CreateOrder:output_type -> order.OrderResponse
+	11, // 21: order.OrderService.CreateOrdersBatch:output_type -> order.BatchOrderResponse
+	13, // 22: order.CacheService.GetOrderResult:output_type -> order.ResultResponse
+	14, // 23: order.CacheService.WatchOrder:output_type -> order.OrderEvent
+	20, // [20:24] is the sub-list for method output_type
+	16, // [16:20] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
 }
 
 func init() { file_proto_order_proto_init() }
@@ -292,13 +1160,14 @@ func file_proto_order_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_order_proto_rawDesc), len(file_proto_order_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      2,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   2,
 		},
 		GoTypes:           file_proto_order_proto_goTypes,
 		DependencyIndexes: file_proto_order_proto_depIdxs,
+		EnumInfos:         file_proto_order_proto_enumTypes,
 		MessageInfos:      file_proto_order_proto_msgTypes,
 	}.Build()
 	File_proto_order_proto = out.File
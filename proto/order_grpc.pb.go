@@ -19,7 +19,8 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	OrderService_CreateOrder_FullMethodName = "/order.OrderService/CreateOrder"
+	OrderService_CreateOrder_FullMethodName       = "/order.OrderService/CreateOrder"
+	OrderService_CreateOrdersBatch_FullMethodName = "/order.OrderService/CreateOrdersBatch"
 )
 
 // OrderServiceClient is the client API for OrderService service.
@@ -27,6 +28,8 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type OrderServiceClient interface {
 	CreateOrder(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*OrderResponse, error)
+	// CreateOrdersBatch публикует заказы одной записью в Kafka и возвращает итог по каждому
+	CreateOrdersBatch(ctx context.Context, in *BatchOrderRequest, opts ...grpc.CallOption) (*BatchOrderResponse, error)
 }
 
 type orderServiceClient struct {
@@ -47,11 +50,23 @@ func (c *orderServiceClient) CreateOrder(ctx context.Context, in *OrderRequest,
 	return out, nil
 }
 
+func (c *orderServiceClient) CreateOrdersBatch(ctx context.Context, in *BatchOrderRequest, opts ...grpc.CallOption) (*BatchOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchOrderResponse)
+	err := c.cc.Invoke(ctx, OrderService_CreateOrdersBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // OrderServiceServer is the server API for OrderService service.
 // All implementations must embed UnimplementedOrderServiceServer
 // for forward compatibility.
 type OrderServiceServer interface {
 	CreateOrder(context.Context, *OrderRequest) (*OrderResponse, error)
+	// CreateOrdersBatch публикует заказы одной записью в Kafka и возвращает итог по каждому
+	CreateOrdersBatch(context.Context, *BatchOrderRequest) (*BatchOrderResponse, error)
 	mustEmbedUnimplementedOrderServiceServer()
 }
 
@@ -65,6 +80,9 @@ type UnimplementedOrderServiceServer struct{}
 func (UnimplementedOrderServiceServer) CreateOrder(context.Context, *OrderRequest) (*OrderResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateOrder not implemented")
 }
+func (UnimplementedOrderServiceServer) CreateOrdersBatch(context.Context, *BatchOrderRequest) (*BatchOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateOrdersBatch not implemented")
+}
 func (UnimplementedOrderServiceServer) mustEmbedUnimplementedOrderServiceServer() {}
 func (UnimplementedOrderServiceServer) testEmbeddedByValue()                      {}
 
@@ -104,6 +122,24 @@ func _OrderService_CreateOrder_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OrderService_CreateOrdersBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).CreateOrdersBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_CreateOrdersBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).CreateOrdersBatch(ctx, req.(*BatchOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // OrderService_ServiceDesc is the grpc.ServiceDesc for OrderService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,6 +151,10 @@ var OrderService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateOrder",
 			Handler:    _OrderService_CreateOrder_Handler,
 		},
+		{
+			MethodName: "CreateOrdersBatch",
+			Handler:    _OrderService_CreateOrdersBatch_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/order.proto",
@@ -122,6 +162,7 @@ var OrderService_ServiceDesc = grpc.ServiceDesc{
 
 const (
 	CacheService_GetOrderResult_FullMethodName = "/order.CacheService/GetOrderResult"
+	CacheService_WatchOrder_FullMethodName     = "/order.CacheService/WatchOrder"
 )
 
 // CacheServiceClient is the client API for CacheService service.
@@ -129,6 +170,9 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type CacheServiceClient interface {
 	GetOrderResult(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (*ResultResponse, error)
+	// WatchOrder сразу отправляет текущее состояние заказа, затем каждое изменение
+	// до завершающего состояния или дедлайна клиента
+	WatchOrder(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OrderEvent], error)
 }
 
 type cacheServiceClient struct {
@@ -149,11 +193,33 @@ func (c *cacheServiceClient) GetOrderResult(ctx context.Context, in *ResultReque
 	return out, nil
 }
 
+func (c *cacheServiceClient) WatchOrder(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[OrderEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CacheService_ServiceDesc.Streams[0], CacheService_WatchOrder_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ResultRequest, OrderEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CacheService_WatchOrderClient = grpc.ServerStreamingClient[OrderEvent]
+
 // CacheServiceServer is the server API for CacheService service.
 // All implementations must embed UnimplementedCacheServiceServer
 // for forward compatibility.
 type CacheServiceServer interface {
 	GetOrderResult(context.Context, *ResultRequest) (*ResultResponse, error)
+	// WatchOrder сразу отправляет текущее состояние заказа, затем каждое изменение
+	// до завершающего состояния или дедлайна клиента
+	WatchOrder(*ResultRequest, grpc.ServerStreamingServer[OrderEvent]) error
 	mustEmbedUnimplementedCacheServiceServer()
 }
 
@@ -167,6 +233,9 @@ type UnimplementedCacheServiceServer struct{}
 func (UnimplementedCacheServiceServer) GetOrderResult(context.Context, *ResultRequest) (*ResultResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetOrderResult not implemented")
 }
+func (UnimplementedCacheServiceServer) WatchOrder(*ResultRequest, grpc.ServerStreamingServer[OrderEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchOrder not implemented")
+}
 func (UnimplementedCacheServiceServer) mustEmbedUnimplementedCacheServiceServer() {}
 func (UnimplementedCacheServiceServer) testEmbeddedByValue()                      {}
 
@@ -206,6 +275,17 @@ func _CacheService_GetOrderResult_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CacheService_WatchOrder_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ResultRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CacheServiceServer).WatchOrder(m, &grpc.GenericServerStream[ResultRequest, OrderEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CacheService_WatchOrderServer = grpc.ServerStreamingServer[OrderEvent]
+
 // CacheService_ServiceDesc is the grpc.ServiceDesc for CacheService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -218,6 +298,12 @@ var CacheService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _CacheService_GetOrderResult_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchOrder",
+			Handler:       _CacheService_WatchOrder_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "proto/order.proto",
 }
@@ -2,6 +2,7 @@ package e2e
 
 import (
 	"context"
+	"io"
 	"testing"
 	"time"
 
@@ -45,30 +46,31 @@ func TestFullPipeline(t *testing.T) {
 	// -----------------------------
 	// 2️⃣ Ждем обработки worker и получения результата из CacheService
 	// -----------------------------
-	var cacheResp *pb.ResultResponse
-	retries := 5 // количество попыток опроса CacheService
-	for i := 0; i < retries; i++ {
-		// Подключаемся к CacheService
-		connCache, err := grpc.Dial(cacheServiceAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-		require.NoError(t, err)
-		cacheClient := pb.NewCacheServiceClient(connCache)
+	connCache, err := grpc.Dial(cacheServiceAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer connCache.Close()
+	cacheClient := pb.NewCacheServiceClient(connCache)
 
-		// Запрашиваем результат заказа по ID
-		cacheResp, err = cacheClient.GetOrderResult(ctx, &pb.ResultRequest{Id: testOrderID})
-		connCache.Close() // закрываем соединение после каждого запроса
+	// Подписываемся на изменения заказа: поток закрывается на терминальном состоянии
+	stream, err := cacheClient.WatchOrder(ctx, &pb.ResultRequest{Id: testOrderID})
+	require.NoError(t, err)
 
-		if err == nil {
-			break // если результат получен, выходим из цикла
+	var cacheResp *pb.ResultResponse
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
 		}
-		time.Sleep(1 * time.Second) // ждем перед следующей попыткой
+		require.NoError(t, err, "cache service did not return result in time")
+		cacheResp = ev.Result
+		t.Logf("Order state: %s", cacheResp.Status)
 	}
-
-	// Проверяем, что CacheService вернул результат
-	require.NoError(t, err, "cache service did not return result in time")
+	require.NotNil(t, cacheResp)
 
 	// Проверяем, что данные из CacheService соответствуют отправленному заказу
 	require.Equal(t, orderReq.Item, cacheResp.Item)
 	require.Equal(t, orderReq.Price, cacheResp.Price)
 	require.Equal(t, "done", cacheResp.Status)
+	require.Equal(t, pb.OrderState_ORDER_STATE_DONE, cacheResp.State)
 	t.Logf("Order processed successfully: %v", cacheResp) // логируем успешную обработку
 }
@@ -2,20 +2,15 @@
 
 set -e
 
-# Ждем, пока OrderService и CacheService будут доступны
+# Ждём готовности сервисов: gRPC health (Redis и Kafka доступны) и /readyz воркера
 echo "Waiting for services..."
 
-while ! nc -z orderservice 50051; do
-  echo "Waiting for orderservice..."
-  sleep 1
-done
+go run ./cmd/orderctl health --wait 2m \
+  orderreceiver:50051 \
+  ordercache:50052 \
+  http://orderprocessor:9090/readyz
 
-while ! nc -z cacheservice 50052; do
-  echo "Waiting for cacheservice..."
-  sleep 1
-done
-
-echo "Services are up. Running e2e tests..."
+echo "Services are ready. Running e2e tests..."
 
 # Запускаем e2e тесты
 go test ./tests/e2e -v